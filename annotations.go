@@ -2,8 +2,13 @@ package panurge
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/aws/aws-xray-sdk-go/xray"
 	"github.com/google/uuid"
@@ -11,6 +16,112 @@ import (
 
 var annotationsKey struct{}
 
+var (
+	idGeneratorMutex sync.RWMutex
+	idGenerator      = defaultIDGenerator
+)
+
+func defaultIDGenerator() string {
+	return uuid.New().String()
+}
+
+// SetIDGenerator overrides the function used to generate IDs for
+// standalone annotations (when we don't have a XRay segment). Passing
+// nil restores the default UUID generator.
+func SetIDGenerator(fn func() string) {
+	idGeneratorMutex.Lock()
+	defer idGeneratorMutex.Unlock()
+
+	if fn == nil {
+		fn = defaultIDGenerator
+	}
+
+	idGenerator = fn
+}
+
+func generateID() string {
+	idGeneratorMutex.RLock()
+	defer idGeneratorMutex.RUnlock()
+
+	return idGenerator()
+}
+
+// AnnotationsTruncatedKey is the annotation that's set when
+// annotations or metadata have been dropped or truncated because they
+// exceeded the annotation limits.
+const AnnotationsTruncatedKey = "annotations_truncated"
+
+// AnnotationLimits caps the annotations and metadata that are kept
+// for a request when we don't have a XRay segment. Zero values mean
+// no limit.
+type AnnotationLimits struct {
+	// MaxEntries is the maximum number of annotations, and the
+	// maximum number of metadata entries.
+	MaxEntries int
+	// MaxValueLength is the maximum length of values in bytes.
+	// Longer string values are truncated at a character boundary,
+	// other values are dropped if their JSON encoding is longer.
+	MaxValueLength int
+}
+
+// DefaultAnnotationLimits are the annotation limits used unless
+// SetAnnotationLimits has been called. No limits are applied by
+// default.
+var DefaultAnnotationLimits = AnnotationLimits{}
+
+var (
+	annotationLimitsMutex sync.RWMutex
+	annotationLimits      = DefaultAnnotationLimits
+)
+
+// SetAnnotationLimits sets the limits for annotations and metadata
+// for contexts that are created after the call. Entries that are
+// added after the limit has been reached are dropped, and the
+// AnnotationsTruncatedKey annotation is set.
+func SetAnnotationLimits(limits AnnotationLimits) {
+	annotationLimitsMutex.Lock()
+	defer annotationLimitsMutex.Unlock()
+
+	annotationLimits = limits
+}
+
+func getAnnotationLimits() AnnotationLimits {
+	annotationLimitsMutex.RLock()
+	defer annotationLimitsMutex.RUnlock()
+
+	return annotationLimits
+}
+
+var (
+	xrayErrorHandlerMutex sync.RWMutex
+	xrayErrorHandler      func(op string, err error)
+)
+
+// SetXRayErrorHandler sets a function that gets called when an XRay
+// segment operation fails, f.ex. when an annotation value has an
+// unsupported type. Passing nil removes the handler, and the errors
+// are then ignored.
+func SetXRayErrorHandler(fn func(op string, err error)) {
+	xrayErrorHandlerMutex.Lock()
+	defer xrayErrorHandlerMutex.Unlock()
+
+	xrayErrorHandler = fn
+}
+
+func handleXRayError(op string, err error) {
+	if err == nil {
+		return
+	}
+
+	xrayErrorHandlerMutex.RLock()
+	fn := xrayErrorHandler
+	xrayErrorHandlerMutex.RUnlock()
+
+	if fn != nil {
+		fn(op, err)
+	}
+}
+
 // AnnotationMiddleware adds annotation support to the request
 // context.
 func AnnotationMiddleware(handler http.Handler) http.Handler {
@@ -21,6 +132,72 @@ func AnnotationMiddleware(handler http.Handler) http.Handler {
 	})
 }
 
+// RequestSummaryMiddleware logs a single summary entry at the end of
+// every request with the method, path, status and duration. When used
+// inside AnnotationMiddleware the entry also gets the final set of
+// annotations, which is useful when XRay is disabled.
+func RequestSummaryMiddleware(logger *slog.Logger, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		handler.ServeHTTP(&rec, r)
+
+		logger.LogAttrs(r.Context(), slog.LevelInfo, "request completed",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+		)
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+
+	status      int
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.status = code
+		rec.wroteHeader = true
+	}
+
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(data []byte) (int, error) {
+	rec.wroteHeader = true
+
+	n, err := rec.ResponseWriter.Write(data)
+	if err != nil {
+		return n, fmt.Errorf("%w", err)
+	}
+
+	return n, nil
+}
+
+// Flush sends any buffered data to the client if the underlying
+// response writer supports flushing.
+func (rec *statusRecorder) Flush() {
+	flusher, ok := rec.ResponseWriter.(http.Flusher)
+	if !ok {
+		return
+	}
+
+	rec.wroteHeader = true
+
+	flusher.Flush()
+}
+
+// Unwrap allows http.ResponseController to reach the underlying
+// response writer.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
 // ContextWithAnnotations allows us to annotate the request context
 // independently of the XRay instrumentation.
 func ContextWithAnnotations(ctx context.Context) context.Context {
@@ -32,7 +209,8 @@ func ContextWithAnnotations(ctx context.Context) context.Context {
 	}
 
 	if annotations.standalone {
-		annotations.id = uuid.New().String()
+		annotations.id = generateID()
+		annotations.limits = getAnnotationLimits()
 		annotations.annotations = make(map[string]interface{})
 		annotations.metadata = make(map[string]interface{})
 	}
@@ -67,6 +245,42 @@ func AddMetadata(ctx context.Context, key string, value interface{}) {
 	ann.AddMetadata(key, value)
 }
 
+// GetAnnotationValue returns the annotation with the given key if
+// it's set and has the type T.
+func GetAnnotationValue[T AllowedAnnotationTypes](ctx context.Context, key string) (T, bool) {
+	var zero T
+
+	ann := GetContextAnnotations(ctx)
+	if ann == nil {
+		return zero, false
+	}
+
+	v, ok := ann.GetAnnotations()[key].(T)
+	if !ok {
+		return zero, false
+	}
+
+	return v, true
+}
+
+// GetMetadataValue returns the metadata with the given key if it's
+// set and has the type T.
+func GetMetadataValue[T any](ctx context.Context, key string) (T, bool) {
+	var zero T
+
+	ann := GetContextAnnotations(ctx)
+	if ann == nil {
+		return zero, false
+	}
+
+	v, ok := ann.GetMetadata()[key].(T)
+	if !ok {
+		return zero, false
+	}
+
+	return v, true
+}
+
 func GetContextAnnotations(ctx context.Context) *ContextAnnotations {
 	if ctx == nil {
 		return nil
@@ -81,8 +295,9 @@ type ContextAnnotations struct {
 	standalone bool
 	segment    *xray.Segment
 
-	id   string
-	user string
+	id     string
+	user   string
+	limits AnnotationLimits
 
 	m           sync.Mutex
 	annotations map[string]interface{}
@@ -91,7 +306,7 @@ type ContextAnnotations struct {
 
 func (a *ContextAnnotations) AddAnnotation(key string, value interface{}) {
 	if !a.standalone {
-		_ = a.segment.AddAnnotation(key, value)
+		handleXRayError("add annotation", a.segment.AddAnnotation(key, value))
 
 		return
 	}
@@ -101,13 +316,81 @@ func (a *ContextAnnotations) AddAnnotation(key string, value interface{}) {
 
 	switch value.(type) {
 	case bool, int, uint, float32, float64, string:
-		a.annotations[key] = value
+		a.setLimited(true, key, value)
+	}
+}
+
+// setLimited sets the value in the annotations or metadata unless
+// that would exceed the annotation limits. Must be called with the
+// lock held.
+func (a *ContextAnnotations) setLimited(annotation bool, key string, value interface{}) {
+	m := a.metadata
+	if annotation {
+		m = a.annotations
+	}
+
+	if a.limits.MaxValueLength > 0 {
+		limited, truncated, dropped := limitValue(value, a.limits.MaxValueLength)
+		if truncated || dropped {
+			a.annotations[AnnotationsTruncatedKey] = true
+		}
+
+		if dropped {
+			return
+		}
+
+		value = limited
+	}
+
+	_, exists := m[key]
+
+	entries := len(m)
+	if _, marked := a.annotations[AnnotationsTruncatedKey]; marked && annotation {
+		// The truncation marker doesn't count against the limit.
+		entries--
+	}
+
+	if !exists && a.limits.MaxEntries > 0 && entries >= a.limits.MaxEntries {
+		a.annotations[AnnotationsTruncatedKey] = true
+
+		return
 	}
+
+	m[key] = value
+}
+
+// limitValue truncates string values to the max length, and drops
+// other values if their JSON encoding exceeds the max length.
+func limitValue(value interface{}, maxLength int) (limited interface{}, truncated bool, dropped bool) {
+	switch v := value.(type) {
+	case nil, bool, int, uint, float32, float64:
+		return value, false, false
+	case string:
+		if len(v) <= maxLength {
+			return value, false, false
+		}
+
+		cut := maxLength
+		for cut > 0 && !utf8.RuneStart(v[cut]) {
+			cut--
+		}
+
+		return v[:cut], true, false
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		// Leave it to the log handler to deal with values that
+		// can't be encoded.
+		return value, false, false
+	}
+
+	return value, false, len(data) > maxLength
 }
 
 func (a *ContextAnnotations) AddMetadata(key string, value interface{}) {
 	if !a.standalone {
-		_ = a.segment.AddMetadata(key, value)
+		handleXRayError("add metadata", a.segment.AddMetadata(key, value))
 
 		return
 	}
@@ -115,7 +398,7 @@ func (a *ContextAnnotations) AddMetadata(key string, value interface{}) {
 	a.m.Lock()
 	defer a.m.Unlock()
 
-	a.metadata[key] = value
+	a.setLimited(false, key, value)
 }
 
 func (a *ContextAnnotations) GetID() string {
@@ -0,0 +1,338 @@
+package panurge_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-xray-sdk-go/strategy/ctxmissing"
+	"github.com/aws/aws-xray-sdk-go/xray"
+	panurge "github.com/navigacontentlab/panurge/v2"
+	"github.com/navigacontentlab/panurge/v2/pt"
+)
+
+func TestSetIDGenerator(t *testing.T) {
+	panurge.SetIDGenerator(func() string {
+		return "request-123"
+	})
+
+	t.Cleanup(func() {
+		panurge.SetIDGenerator(nil)
+	})
+
+	ctx := panurge.ContextWithAnnotations(context.Background())
+
+	got := panurge.GetContextAnnotations(ctx).GetID()
+	if got != "request-123" {
+		t.Fatalf("wanted the id %q, got %q", "request-123", got)
+	}
+
+	panurge.SetIDGenerator(nil)
+
+	ctx = panurge.ContextWithAnnotations(context.Background())
+
+	got = panurge.GetContextAnnotations(ctx).GetID()
+	if got == "" || got == "request-123" {
+		t.Fatalf("expected the default generator to be restored, got %q", got)
+	}
+}
+
+func TestSetAnnotationLimits(t *testing.T) {
+	panurge.SetAnnotationLimits(panurge.AnnotationLimits{
+		MaxEntries:     2,
+		MaxValueLength: 5,
+	})
+
+	t.Cleanup(func() {
+		panurge.SetAnnotationLimits(panurge.DefaultAnnotationLimits)
+	})
+
+	ctx := panurge.ContextWithAnnotations(context.Background())
+
+	panurge.AddAnnotation(ctx, "one", 1)
+	panurge.AddAnnotation(ctx, "two", 2)
+	panurge.AddAnnotation(ctx, "three", 3)
+	panurge.AddAnnotation(ctx, "two", 22)
+
+	panurge.AddMetadata(ctx, "short", "abc")
+	panurge.AddMetadata(ctx, "long", "abcdefgh")
+	panurge.AddMetadata(ctx, "dropped", "abc")
+
+	ann := panurge.GetContextAnnotations(ctx)
+
+	annotations := ann.GetAnnotations()
+	if len(annotations) != 3 {
+		t.Errorf("expected two annotations and the marker, got %v", annotations)
+	}
+
+	if annotations["two"] != 22 {
+		t.Errorf("expected existing annotations to be replaceable, got %v", annotations["two"])
+	}
+
+	truncated, _ := panurge.GetAnnotationValue[bool](ctx, panurge.AnnotationsTruncatedKey)
+	if !truncated {
+		t.Error("expected the truncation marker to be set")
+	}
+
+	metadata := ann.GetMetadata()
+	if len(metadata) != 2 || metadata["long"] != "abcde" {
+		t.Errorf("expected truncated metadata, got %v", metadata)
+	}
+}
+
+func TestSetAnnotationLimits__Values(t *testing.T) {
+	panurge.SetAnnotationLimits(panurge.AnnotationLimits{
+		MaxValueLength: 10,
+	})
+
+	t.Cleanup(func() {
+		panurge.SetAnnotationLimits(panurge.DefaultAnnotationLimits)
+	})
+
+	samples := map[string]struct {
+		Value         interface{}
+		Want          interface{}
+		WantTruncated bool
+	}{
+		"ShortString": {Value: "abc", Want: "abc"},
+		"LongString": {
+			Value: "abcdefghijkl", Want: "abcdefghij", WantTruncated: true,
+		},
+		"MultiByteString": {
+			// The letters are two bytes each, so the limit falls
+			// in the middle of the last character.
+			Value: "aåäöåä", Want: "aåäöå", WantTruncated: true,
+		},
+		"Number":   {Value: 1234567890123, Want: 1234567890123},
+		"SmallMap": {Value: map[string]int{"a": 1}, Want: map[string]int{"a": 1}},
+		"LargeMap": {
+			Value:         map[string]string{"key": "a long value"},
+			WantTruncated: true,
+		},
+		"LargeStruct": {
+			Value: struct {
+				Name string
+			}{Name: "a long name"},
+			WantTruncated: true,
+		},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			ctx := panurge.ContextWithAnnotations(context.Background())
+
+			panurge.AddMetadata(ctx, "value", tc.Value)
+
+			got, ok := panurge.GetMetadataValue[interface{}](ctx, "value")
+
+			if tc.Want == nil && ok {
+				t.Errorf("expected the value to be dropped, got %v", got)
+			}
+
+			if tc.Want != nil && !reflect.DeepEqual(got, tc.Want) {
+				t.Errorf("wanted %#v, got %#v", tc.Want, got)
+			}
+
+			truncated, _ := panurge.GetAnnotationValue[bool](ctx, panurge.AnnotationsTruncatedKey)
+			if truncated != tc.WantTruncated {
+				t.Errorf("wanted truncation marker %v, got %v", tc.WantTruncated, truncated)
+			}
+		})
+	}
+}
+
+func TestDefaultAnnotationLimits(t *testing.T) {
+	ctx := panurge.ContextWithAnnotations(context.Background())
+
+	long := strings.Repeat("a", 100000)
+
+	for i := 0; i < 1000; i++ {
+		panurge.AddAnnotation(ctx, fmt.Sprintf("key%d", i), i)
+	}
+
+	panurge.AddMetadata(ctx, "long", long)
+
+	annotations := panurge.GetContextAnnotations(ctx).GetAnnotations()
+	if len(annotations) != 1000 {
+		t.Errorf("expected no annotations to be dropped by default, got %d", len(annotations))
+	}
+
+	value, _ := panurge.GetMetadataValue[string](ctx, "long")
+	if value != long {
+		t.Errorf("expected no values to be truncated by default, got %d bytes", len(value))
+	}
+}
+
+func TestSetXRayErrorHandler(t *testing.T) {
+	err := xray.Configure(xray.Config{
+		SamplingStrategy: SamplingStrategy(true),
+		Emitter:          DummyEmitter{},
+	})
+	pt.Must(t, err, "failed to configure XRay to sample all requests")
+
+	t.Cleanup(pt.DisableXRay)
+
+	var failed []string
+
+	panurge.SetXRayErrorHandler(func(op string, _ error) {
+		failed = append(failed, op)
+	})
+
+	t.Cleanup(func() {
+		panurge.SetXRayErrorHandler(nil)
+	})
+
+	ctx, seg := xray.BeginSegment(context.Background(), "testSeg")
+	ctx = panurge.ContextWithAnnotations(ctx)
+
+	t.Cleanup(func() {
+		seg.Close(nil)
+	})
+
+	panurge.AddAnnotation(ctx, "document", "abc123")
+
+	// Annotations must be strings, numbers or booleans.
+	panurge.GetContextAnnotations(ctx).AddAnnotation("document", struct{}{})
+
+	if len(failed) != 1 || failed[0] != "add annotation" {
+		t.Fatalf("expected the failed annotation to be reported, got %v", failed)
+	}
+}
+
+func TestRequestSummaryMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := panurge.Logger("info", &buf)
+
+	handler := panurge.AnnotationMiddleware(panurge.RequestSummaryMiddleware(logger,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panurge.AddAnnotation(r.Context(), "document", "abc")
+			w.WriteHeader(http.StatusTeapot)
+		})))
+
+	handler.ServeHTTP(httptest.NewRecorder(),
+		httptest.NewRequest(http.MethodPost, "/twirp/Test/DoThing", nil))
+
+	var entry struct {
+		Msg         string                 `json:"msg"`
+		Path        string                 `json:"path"`
+		Status      int                    `json:"status"`
+		Annotations map[string]interface{} `json:"annotations"`
+	}
+
+	err := json.Unmarshal(buf.Bytes(), &entry)
+	pt.Must(t, err, "failed to decode the summary log entry")
+
+	if entry.Msg != "request completed" || entry.Path != "/twirp/Test/DoThing" {
+		t.Errorf("unexpected summary entry: %s", buf.String())
+	}
+
+	if entry.Status != http.StatusTeapot {
+		t.Errorf("expected the status %d to be logged, got %d",
+			http.StatusTeapot, entry.Status)
+	}
+
+	if entry.Annotations["document"] != "abc" {
+		t.Errorf("expected the final annotations to be logged, got %v",
+			entry.Annotations)
+	}
+}
+
+func TestRequestSummaryMiddleware__Flush(t *testing.T) {
+	logger := panurge.Logger("error", io.Discard)
+
+	handler := panurge.RequestSummaryMiddleware(logger,
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			flusher, ok := w.(http.Flusher)
+			if !ok {
+				t.Fatal("expected the response writer to be a http.Flusher")
+			}
+
+			_, _ = w.Write([]byte("data: event\n\n"))
+
+			flusher.Flush()
+		}))
+
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events", nil))
+
+	if !rec.Flushed {
+		t.Error("expected the flush to reach the underlying response writer")
+	}
+}
+
+func TestGetMetadataValue(t *testing.T) {
+	ctx := panurge.ContextWithAnnotations(context.Background())
+
+	panurge.AddMetadata(ctx, "ids", []string{"a", "b"})
+	panurge.AddAnnotation(ctx, "count", 3)
+
+	ids, ok := panurge.GetMetadataValue[[]string](ctx, "ids")
+	if !ok || len(ids) != 2 {
+		t.Errorf("expected to get the ids metadata, got %v, %v", ids, ok)
+	}
+
+	if _, ok := panurge.GetMetadataValue[string](ctx, "ids"); ok {
+		t.Error("expected a type mismatch to be reported")
+	}
+
+	count, ok := panurge.GetAnnotationValue[int](ctx, "count")
+	if !ok || count != 3 {
+		t.Errorf("expected to get the count annotation, got %v, %v", count, ok)
+	}
+
+	if _, ok := panurge.GetAnnotationValue[string](context.Background(), "count"); ok {
+		t.Error("expected a missing annotation context to be reported")
+	}
+}
+
+func TestConfigureXRay__Idempotent(t *testing.T) {
+	logger := panurge.Logger("warning", pt.NewTestLogWriter(t))
+
+	err := panurge.ConfigureXRay(logger, "v1.0.0")
+	pt.Must(t, err, "failed to configure XRay")
+
+	err = panurge.ConfigureXRay(logger, "v1.0.0")
+	pt.Must(t, err, "failed to configure XRay a second time")
+}
+
+func TestConfigureXRay__ReplacesLogger(t *testing.T) {
+	err := panurge.ConfigureXRay(
+		panurge.Logger("error", pt.NewTestLogWriter(t)), "v1.0.0")
+	pt.Must(t, err, "failed to configure XRay")
+
+	var buf bytes.Buffer
+
+	err = panurge.ConfigureXRay(panurge.Logger("error", &buf), "v1.0.0")
+	pt.Must(t, err, "failed to configure XRay a second time")
+
+	t.Cleanup(func() {
+		_ = panurge.ConfigureXRay(panurge.Logger("error", io.Discard), "v1.0.0")
+	})
+
+	err = xray.Configure(xray.Config{
+		ContextMissingStrategy: ctxmissing.NewDefaultLogErrorStrategy(),
+	})
+	pt.Must(t, err, "failed to configure the context missing strategy")
+
+	// Starting a subsegment without a segment makes XRay log an
+	// error.
+	_, seg := xray.BeginSubsegment(context.Background(), "orphan")
+	if seg != nil {
+		seg.Close(nil)
+	}
+
+	if !strings.Contains(buf.String(), "context missing") {
+		t.Fatalf("expected XRay to log through the latest logger, got %q", buf.String())
+	}
+}
@@ -0,0 +1,93 @@
+package panurge
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIPOption controls how the client IP is resolved.
+type ClientIPOption func(o *clientIPOptions)
+
+type clientIPOptions struct {
+	trustRealIP bool
+}
+
+// WithTrustedRealIP uses the X-Real-IP header when there are trusted
+// proxies but no X-Forwarded-For header. Only use this when the
+// proxies in front of the application always set or strip
+// X-Real-IP, otherwise clients can spoof their IP by sending the
+// header themselves.
+func WithTrustedRealIP() ClientIPOption {
+	return func(o *clientIPOptions) {
+		o.trustRealIP = true
+	}
+}
+
+// ClientIP returns the IP of the client that made the request. The
+// X-Forwarded-For header is only trusted for the given number of
+// proxy hops in front of the application, so that clients can't
+// spoof their IP by sending the header themselves. If trustedProxies
+// is zero, or the request has passed through fewer proxies than
+// that, the remote address of the connection is used.
+func ClientIP(r *http.Request, trustedProxies int, opts ...ClientIPOption) string {
+	var o clientIPOptions
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+
+	if trustedProxies <= 0 {
+		return remote
+	}
+
+	var chain []string
+
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, ip := range strings.Split(header, ",") {
+			if ip = strings.TrimSpace(ip); ip != "" {
+				chain = append(chain, ip)
+			}
+		}
+	}
+
+	if len(chain) == 0 {
+		realIP := strings.TrimSpace(r.Header.Get("X-Real-IP"))
+		if o.trustRealIP && realIP != "" {
+			return realIP
+		}
+
+		return remote
+	}
+
+	// The remote address is the last hop, every trusted proxy has
+	// appended the address it got the request from.
+	chain = append(chain, remote)
+
+	// With fewer hops than trusted proxies the leftmost entries
+	// weren't added by a trusted proxy, and can't be trusted.
+	idx := len(chain) - 1 - trustedProxies
+	if idx < 0 {
+		return remote
+	}
+
+	return chain[idx]
+}
+
+// ClientIPMiddleware adds the client IP of the request as a
+// "client_ip" annotation. It must be used inside AnnotationMiddleware.
+// See ClientIP for how trustedProxies and the options are used.
+func ClientIPMiddleware(
+	trustedProxies int, next http.Handler, opts ...ClientIPOption,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AddAnnotation(r.Context(), "client_ip", ClientIP(r, trustedProxies, opts...))
+
+		next.ServeHTTP(w, r)
+	})
+}
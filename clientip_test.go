@@ -0,0 +1,61 @@
+package panurge_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	panurge "github.com/navigacontentlab/panurge/v2"
+)
+
+func TestClientIP(t *testing.T) {
+	samples := map[string]struct {
+		ForwardedFor string
+		RealIP       string
+		Trusted      int
+		TrustRealIP  bool
+		Want         string
+	}{
+		"NoProxies":        {ForwardedFor: "1.1.1.1", Want: "10.0.0.1"},
+		"OneProxy":         {ForwardedFor: "1.1.1.1", Trusted: 1, Want: "1.1.1.1"},
+		"SpoofedHeader":    {ForwardedFor: "6.6.6.6, 1.1.1.1", Trusted: 1, Want: "1.1.1.1"},
+		"TwoProxies":       {ForwardedFor: "6.6.6.6, 1.1.1.1, 10.0.0.2", Trusted: 2, Want: "1.1.1.1"},
+		"FewerHopsThanSet": {ForwardedFor: "1.1.1.1", Trusted: 3, Want: "10.0.0.1"},
+		"SpoofedShortChain": {
+			ForwardedFor: "6.6.6.6, 1.1.1.1", Trusted: 3, Want: "10.0.0.1",
+		},
+		"ExactHops":       {ForwardedFor: "1.1.1.1, 10.0.0.2", Trusted: 2, Want: "1.1.1.1"},
+		"RealIPTrusted":   {RealIP: "2.2.2.2", Trusted: 1, TrustRealIP: true, Want: "2.2.2.2"},
+		"SpoofedRealIP":   {RealIP: "6.6.6.6", Trusted: 1, Want: "10.0.0.1"},
+		"RealIPNoProxies": {RealIP: "6.6.6.6", TrustRealIP: true, Want: "10.0.0.1"},
+		"NoHeaders":       {Trusted: 1, Want: "10.0.0.1"},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.RemoteAddr = "10.0.0.1:4711"
+
+			if tc.ForwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tc.ForwardedFor)
+			}
+
+			if tc.RealIP != "" {
+				req.Header.Set("X-Real-IP", tc.RealIP)
+			}
+
+			var opts []panurge.ClientIPOption
+
+			if tc.TrustRealIP {
+				opts = append(opts, panurge.WithTrustedRealIP())
+			}
+
+			got := panurge.ClientIP(req, tc.Trusted, opts...)
+			if got != tc.Want {
+				t.Fatalf("expected the client IP %q, got %q", tc.Want, got)
+			}
+		})
+	}
+}
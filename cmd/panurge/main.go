@@ -1,10 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/navigacontentlab/panurge/v2/navigaid"
 	"github.com/urfave/cli/v2"
@@ -21,6 +24,9 @@ func main() {
 
 func NewCLIApplication() cli.App {
 	return cli.App{
+		// Claim values can be JSON lists, so slice flags must
+		// only be split by repeating the flag.
+		DisableSliceFlagSeparator: true,
 		Commands: []*cli.Command{
 			{
 				Name:        "navigaid-mock",
@@ -31,8 +37,13 @@ func NewCLIApplication() cli.App {
 						Name:  "addr",
 						Value: ":1066",
 					},
-					&cli.PathFlag{
-						Name: "config",
+					&cli.StringSliceFlag{
+						Name:  "config",
+						Usage: "JSON config `FILE`, can be repeated to layer configs that are merged in order",
+					},
+					&cli.StringSliceFlag{
+						Name:  "claim",
+						Usage: "a `KEY=VALUE` claim that overrides the config, values that are valid JSON are used as JSON",
 					},
 				},
 			},
@@ -42,8 +53,34 @@ func NewCLIApplication() cli.App {
 
 func navigaIDMock(c *cli.Context) error {
 	addr := c.String("addr")
-	confPath := c.Path("config")
 
+	opts, err := loadMockOptions(c.StringSlice("config"), c.StringSlice("claim"))
+	if err != nil {
+		return err
+	}
+
+	mockService, err := navigaid.NewMockService(opts)
+	if err != nil {
+		return fmt.Errorf("failed to create mock service: %w", err)
+	}
+
+	var server http.Server
+
+	server.Addr = addr
+	server.Handler = mockService
+
+	err = server.ListenAndServe()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// loadMockOptions reads the config files in order on top of the
+// default options, so that later files override the fields they
+// set, and then applies the claim flags.
+func loadMockOptions(configPaths []string, claims []string) (navigaid.MockServerOptions, error) {
 	opts := navigaid.MockServerOptions{
 		Claims: navigaid.Claims{
 			Org: "testorg",
@@ -51,32 +88,115 @@ func navigaIDMock(c *cli.Context) error {
 		TTL: 600,
 	}
 
-	if confPath != "" {
+	for _, confPath := range configPaths {
 		conf, err := os.ReadFile(confPath)
 		if err != nil {
-			return fmt.Errorf("failed to read config: %w", err)
+			return opts, fmt.Errorf("failed to read config: %w", err)
 		}
 
-		err = json.Unmarshal(conf, &opts)
+		dec := json.NewDecoder(bytes.NewReader(conf))
+		dec.DisallowUnknownFields()
+
+		err = dec.Decode(&opts)
 		if err != nil {
-			return fmt.Errorf("failed to parse config: %w", err)
+			return opts, fmt.Errorf("failed to parse config %q: %w", confPath, err)
 		}
 	}
 
-	mockService, err := navigaid.NewMockService(opts)
+	for _, claim := range claims {
+		err := applyClaimFlag(&opts, claim)
+		if err != nil {
+			return opts, fmt.Errorf("invalid claim %q: %w", claim, err)
+		}
+	}
+
+	if len(configPaths) == 0 && len(claims) == 0 {
+		return opts, nil
+	}
 
+	err := validateMockConfig(opts)
 	if err != nil {
-		return fmt.Errorf("failed to create mock service: %w", err)
+		return opts, fmt.Errorf("invalid config: %w", err)
 	}
 
-	var server http.Server
+	return opts, nil
+}
 
-	server.Addr = addr
-	server.Handler = mockService
+// applyClaimFlag sets a claim from a key=value flag. The claim is
+// added to the extra claims so that it overrides any standard claim,
+// and is also applied to the standard claims so that they are
+// validated with the override. Values are used as JSON if they are
+// valid JSON for the claim, and as strings otherwise, so
+// "groups=[\"a\"]" sets a list while "org=2024" sets a string.
+func applyClaimFlag(opts *navigaid.MockServerOptions, claim string) error {
+	key, raw, ok := strings.Cut(claim, "=")
+	if !ok || key == "" {
+		return errors.New("claims must have the format key=value")
+	}
 
-	err = server.ListenAndServe()
+	var value interface{}
+
+	err := json.Unmarshal([]byte(raw), &value)
 	if err != nil {
-		return fmt.Errorf("%w", err)
+		value = raw
+	}
+
+	claims, err := withClaim(opts.Claims, key, value)
+	if err != nil && value != raw {
+		// Numeric-looking and other JSON values are used as
+		// strings for string claims.
+		value = raw
+		claims, err = withClaim(opts.Claims, key, value)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	opts.Claims = claims
+
+	if opts.ExtraClaims == nil {
+		opts.ExtraClaims = make(map[string]interface{})
+	}
+
+	opts.ExtraClaims[key] = value
+
+	return nil
+}
+
+// withClaim returns a copy of the claims with the claim applied.
+// Claims that aren't standard claims are ignored.
+func withClaim(
+	claims navigaid.Claims, key string, value interface{},
+) (navigaid.Claims, error) {
+	data, err := json.Marshal(map[string]interface{}{key: value})
+	if err != nil {
+		return claims, fmt.Errorf("failed to marshal claim: %w", err)
+	}
+
+	err = json.Unmarshal(data, &claims)
+	if err != nil {
+		return claims, fmt.Errorf("failed to apply claim: %w", err)
+	}
+
+	return claims, nil
+}
+
+func validateMockConfig(opts navigaid.MockServerOptions) error {
+	if opts.Claims.Org == "" {
+		return errors.New("the org claim must not be empty")
+	}
+
+	if opts.Claims.Subject == "" {
+		return errors.New("the sub claim must not be empty")
+	}
+
+	if opts.TTL < 0 {
+		return errors.New("the ttl must not be negative")
+	}
+
+	if opts.PrivatePemKey != "" && opts.PrivatePemKeyID == "" {
+		return errors.New("a private_pem_key_id is required when a private_pem_key is given")
 	}
 
 	return nil
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/navigacontentlab/panurge/v2/navigaid"
+	"github.com/navigacontentlab/panurge/v2/pt"
+)
+
+func TestLoadMockOptions(t *testing.T) {
+	dir := t.TempDir()
+
+	writeConfig := func(name string, data string) string {
+		path := filepath.Join(dir, name)
+
+		err := os.WriteFile(path, []byte(data), 0o600)
+		pt.Mustf(t, err, "failed to write config %q", name)
+
+		return path
+	}
+
+	base := writeConfig("base.json",
+		`{"claims":{"org":"base","sub":"core://user/1","groups":["a"]},"ttl":300}`)
+	override := writeConfig("override.json", `{"claims":{"org":"override"}}`)
+	unknownField := writeConfig("unknown.json",
+		`{"claims":{"org":"base","sub":"core://user/1"},"time_to_live":300}`)
+	negativeTTL := writeConfig("negative.json",
+		`{"claims":{"org":"base","sub":"core://user/1"},"ttl":-1}`)
+	stringTTL := writeConfig("string.json",
+		`{"claims":{"org":"base","sub":"core://user/1"},"ttl":"5m"}`)
+	noSubject := writeConfig("nosubject.json", `{"claims":{"org":"base"}}`)
+
+	samples := map[string]struct {
+		Configs     []string
+		Claims      []string
+		WantOrg     string
+		WantSubject string
+		WantGroups  []string
+		WantTTL     int
+		WantExtra   map[string]interface{}
+		Fail        bool
+	}{
+		"Defaults": {
+			WantOrg: "testorg",
+			WantTTL: 600,
+		},
+		"Layered": {
+			Configs:     []string{base, override},
+			WantOrg:     "override",
+			WantSubject: "core://user/1",
+			WantGroups:  []string{"a"},
+			WantTTL:     300,
+		},
+		"ClaimOverrides": {
+			Configs:     []string{base},
+			Claims:      []string{`groups=["x","y"]`, "custom=hello", "org=flag"},
+			WantOrg:     "flag",
+			WantSubject: "core://user/1",
+			WantGroups:  []string{"x", "y"},
+			WantTTL:     300,
+			WantExtra: map[string]interface{}{
+				"groups": []interface{}{"x", "y"},
+				"custom": "hello",
+				"org":    "flag",
+			},
+		},
+		"NumericStrings": {
+			Claims:      []string{"org=2024", "sub=12345", "level=3"},
+			WantOrg:     "2024",
+			WantSubject: "12345",
+			WantTTL:     600,
+			WantExtra: map[string]interface{}{
+				"org":   "2024",
+				"sub":   "12345",
+				"level": float64(3),
+			},
+		},
+		"ClaimWithoutValue": {
+			Claims: []string{"org"},
+			Fail:   true,
+		},
+		"ClaimWithoutKey": {
+			Claims: []string{"=value"},
+			Fail:   true,
+		},
+		"InvalidClaimType": {
+			Claims: []string{"sub=core://user/1", `groups={"a":1}`},
+			Fail:   true,
+		},
+		"MissingConfig": {
+			Configs: []string{filepath.Join(dir, "missing.json")},
+			Fail:    true,
+		},
+		"UnknownField": {
+			Configs: []string{unknownField},
+			Fail:    true,
+		},
+		"NegativeTTL": {
+			Configs: []string{negativeTTL},
+			Fail:    true,
+		},
+		"InvalidTTLType": {
+			Configs: []string{stringTTL},
+			Fail:    true,
+		},
+		"MissingSubject": {
+			Configs: []string{noSubject},
+			Fail:    true,
+		},
+		"EmptyOrgClaim": {
+			Configs: []string{base},
+			Claims:  []string{"org="},
+			Fail:    true,
+		},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			opts, err := loadMockOptions(tc.Configs, tc.Claims)
+			if tc.Fail {
+				if err == nil {
+					t.Fatal("expected loading the options to fail")
+				}
+
+				return
+			}
+
+			pt.Must(t, err, "failed to load options")
+
+			want := navigaid.MockServerOptions{
+				Claims:      opts.Claims,
+				TTL:         tc.WantTTL,
+				ExtraClaims: tc.WantExtra,
+			}
+
+			want.Claims.Org = tc.WantOrg
+			want.Claims.Subject = tc.WantSubject
+			want.Claims.Groups = tc.WantGroups
+
+			if !reflect.DeepEqual(opts, want) {
+				t.Errorf("wanted %#v, got %#v", want, opts)
+			}
+		})
+	}
+}
+
+func TestValidateMockConfig(t *testing.T) {
+	valid := navigaid.MockServerOptions{
+		Claims: navigaid.Claims{
+			Org: "testorg",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject: "core://user/1",
+			},
+		},
+		TTL: 600,
+	}
+
+	samples := map[string]struct {
+		Modify func(opts *navigaid.MockServerOptions)
+		Fail   bool
+	}{
+		"Valid": {},
+		"ZeroTTL": {
+			Modify: func(opts *navigaid.MockServerOptions) { opts.TTL = 0 },
+		},
+		"EmptyOrg": {
+			Modify: func(opts *navigaid.MockServerOptions) { opts.Claims.Org = "" },
+			Fail:   true,
+		},
+		"EmptySubject": {
+			Modify: func(opts *navigaid.MockServerOptions) { opts.Claims.Subject = "" },
+			Fail:   true,
+		},
+		"NegativeTTL": {
+			Modify: func(opts *navigaid.MockServerOptions) { opts.TTL = -10 },
+			Fail:   true,
+		},
+		"KeyWithoutID": {
+			Modify: func(opts *navigaid.MockServerOptions) { opts.PrivatePemKey = "key" },
+			Fail:   true,
+		},
+		"KeyWithID": {
+			Modify: func(opts *navigaid.MockServerOptions) {
+				opts.PrivatePemKey = "key"
+				opts.PrivatePemKeyID = "kid"
+			},
+		},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			opts := valid
+
+			if tc.Modify != nil {
+				tc.Modify(&opts)
+			}
+
+			err := validateMockConfig(opts)
+
+			switch {
+			case tc.Fail && err == nil:
+				t.Fatal("expected the config to be invalid")
+			case !tc.Fail && err != nil:
+				t.Fatalf("expected the config to be valid, got: %v", err)
+			}
+		})
+	}
+}
@@ -3,17 +3,19 @@ package cockroach
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ssm"
-	_ "github.com/lib/pq" //nolint:nolintlint
+	"github.com/lib/pq"
 )
 
 const (
@@ -38,13 +40,100 @@ func DefaultConnection(ctx context.Context, host, application string) (*sql.DB,
 	return Connect(ctx, cc, application)
 }
 
+func defaultDatabaseParameters(user string) url.Values {
+	return url.Values{
+		"connect_timeout":  []string{"5"},
+		"application_name": []string{user},
+	}
+}
+
+// RetryOptions controls how connection attempts are retried.
+type RetryOptions struct {
+	// InitialBackoff is the delay before the first retry, it's
+	// doubled for every subsequent retry. Defaults to 500ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts. Defaults to 10s.
+	MaxBackoff time.Duration
+	// MaxAttempts is the maximum number of connection attempts.
+	// Defaults to no limit, the attempts are only bounded by the
+	// context.
+	MaxAttempts int
+}
+
+// DefaultConnectionWithRetry works like DefaultConnection, but
+// retries connecting to the cluster with backoff until it succeeds,
+// the context is cancelled, or the maximum number of attempts have
+// been made. This lets services survive brief cluster unavailability
+// at startup.
+//
+// Note that it will retry forever if the context has no deadline and
+// RetryOptions.MaxAttempts isn't set.
+func DefaultConnectionWithRetry(
+	ctx context.Context, host, application string, retry RetryOptions,
+) (*sql.DB, error) {
+	cc, err := NewConnectionConfig(
+		ctx,
+		application,
+		ConnectionOptions{
+			Host: host,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to set up database connection configuration: %w", err)
+	}
+
+	return connectWithRetry(ctx, cc, application, retry)
+}
+
+func connectWithRetry(
+	ctx context.Context, cc *ConnectionConfig, database string, retry RetryOptions,
+) (*sql.DB, error) {
+	backoff := retry.InitialBackoff
+	if backoff <= 0 {
+		backoff = 500 * time.Millisecond
+	}
+
+	maxBackoff := retry.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = 10 * time.Second
+	}
+
+	for attempt := 1; ; attempt++ {
+		db, err := Connect(ctx, cc, database)
+		if err == nil {
+			return db, nil
+		}
+
+		if retry.MaxAttempts > 0 && attempt >= retry.MaxAttempts {
+			return nil, fmt.Errorf(
+				"gave up connecting to the database after %d attempts: %w",
+				attempt, err)
+		}
+
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return nil, fmt.Errorf("gave up connecting to the database: %w", err)
+		case <-timer.C:
+		}
+
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
 // ConnectionOptions are used to control how we connect to the
 // cluster.
 type ConnectionOptions struct {
 	SSM                  *ssm.SSM
 	CertificateDirectory string
-	DatabaseParameters   url.Values
-	Host                 string
+	// DatabaseParameters are added to the database URL query, see
+	// ConnectionConfig.DatabaseURL for how they're resolved.
+	DatabaseParameters url.Values
+	Host               string
 }
 
 // ConnectionConfig is a database configuration that can be used to
@@ -117,13 +206,22 @@ func NewConnectionConfig(
 }
 
 // DatabaseURL creates a database URL for use with sql.Open.
+//
+// The query parameters are resolved in order of precedence: the
+// defaults (connect_timeout=5 and application_name set to the user)
+// are overridden by the DatabaseParameters from the connection
+// options, and the TLS parameters (sslmode, sslcert, sslkey, and
+// sslrootcert) always point to the fetched certificates and cannot be
+// overridden.
 func (cc *ConnectionConfig) DatabaseURL(database string) string {
 	dbValues := make(url.Values)
 
-	dbValues.Set("connect_timeout", "5")
+	for k, v := range defaultDatabaseParameters(cc.user) {
+		dbValues[k] = v
+	}
 
 	for k, v := range cc.dbParams {
-		dbValues[k] = v
+		dbValues[k] = append([]string(nil), v...)
 	}
 
 	dbValues.Set("sslmode", "verify-full")
@@ -148,6 +246,41 @@ func (cc *ConnectionConfig) DatabaseURL(database string) string {
 	return dbURL.String()
 }
 
+// SafeURL creates a database URL that is suitable for logging. Only
+// the sslmode and application_name query parameters are kept.
+func (cc *ConnectionConfig) SafeURL(database string) string {
+	dbURL, err := url.Parse(cc.DatabaseURL(database))
+	if err != nil {
+		return ""
+	}
+
+	query := dbURL.Query()
+	safe := make(url.Values)
+
+	for _, name := range []string{"sslmode", "application_name"} {
+		if v, ok := query[name]; ok {
+			safe[name] = v
+		}
+	}
+
+	dbURL.RawQuery = safe.Encode()
+
+	return dbURL.String()
+}
+
+// Connector creates a database connector for use with sql.OpenDB,
+// this allows callers to control the connection pool settings of the
+// resulting database handle.
+func (cc *ConnectionConfig) Connector(database string) (driver.Connector, error) {
+	connector, err := pq.NewConnector(cc.DatabaseURL(database))
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to create database connector: %w", err)
+	}
+
+	return connector, nil
+}
+
 // CertificateDir returns the directory used for storing certificates.
 func (cc *ConnectionConfig) CertificateDir() string {
 	return cc.certDir
@@ -209,7 +342,7 @@ func Connect(
 	ctx aws.Context,
 	cc *ConnectionConfig, database string,
 ) (*sql.DB, error) {
-	db, err := sql.Open("postgres", cc.DatabaseURL(database))
+	connector, err := cc.Connector(database)
 	if err != nil {
 		return nil, fmt.Errorf(
 			"failed to configure database connection: %w",
@@ -217,7 +350,11 @@ func Connect(
 		)
 	}
 
+	db := sql.OpenDB(connector)
+
 	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+
 		return nil, fmt.Errorf(
 			"failed to connect to database: %w", err)
 	}
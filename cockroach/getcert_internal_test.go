@@ -0,0 +1,207 @@
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestDatabaseURL(t *testing.T) {
+	samples := map[string]struct {
+		Params url.Values
+		Want   string
+	}{
+		"Defaults": {
+			Want: "application_name=svc&connect_timeout=5&sslcert=%2Fcerts%2Fclient.svc.crt" +
+				"&sslkey=%2Fcerts%2Fclient.svc.key&sslmode=verify-full" +
+				"&sslrootcert=%2Fcerts%2Fca.crt",
+		},
+		"ConnectTimeout": {
+			Params: url.Values{"connect_timeout": {"30"}},
+			Want: "application_name=svc&connect_timeout=30&sslcert=%2Fcerts%2Fclient.svc.crt" +
+				"&sslkey=%2Fcerts%2Fclient.svc.key&sslmode=verify-full" +
+				"&sslrootcert=%2Fcerts%2Fca.crt",
+		},
+		"ApplicationName": {
+			Params: url.Values{"application_name": {"svc-worker"}},
+			Want: "application_name=svc-worker&connect_timeout=5" +
+				"&sslcert=%2Fcerts%2Fclient.svc.crt" +
+				"&sslkey=%2Fcerts%2Fclient.svc.key&sslmode=verify-full" +
+				"&sslrootcert=%2Fcerts%2Fca.crt",
+		},
+		"ExtraParam": {
+			Params: url.Values{"statement_timeout": {"1000"}},
+			Want: "application_name=svc&connect_timeout=5&sslcert=%2Fcerts%2Fclient.svc.crt" +
+				"&sslkey=%2Fcerts%2Fclient.svc.key&sslmode=verify-full" +
+				"&sslrootcert=%2Fcerts%2Fca.crt&statement_timeout=1000",
+		},
+		"FixedTLSParams": {
+			Params: url.Values{
+				"sslmode": {"disable"},
+				"sslcert": {"/tmp/other.crt"},
+			},
+			Want: "application_name=svc&connect_timeout=5&sslcert=%2Fcerts%2Fclient.svc.crt" +
+				"&sslkey=%2Fcerts%2Fclient.svc.key&sslmode=verify-full" +
+				"&sslrootcert=%2Fcerts%2Fca.crt",
+		},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			cc := ConnectionConfig{
+				certDir:  "/certs",
+				user:     "svc",
+				host:     "db.example.com:26257",
+				dbParams: tc.Params,
+			}
+
+			u, err := url.Parse(cc.DatabaseURL("svc"))
+			if err != nil {
+				t.Fatalf("failed to parse database URL: %v", err)
+			}
+
+			if u.RawQuery != tc.Want {
+				t.Fatalf("wanted the query %q, got %q", tc.Want, u.RawQuery)
+			}
+		})
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	cc := ConnectionConfig{
+		certDir: "/certs",
+		user:    "svc",
+		host:    "db.example.com:26257",
+		dbParams: url.Values{
+			"password": {"hunter2"},
+		},
+	}
+
+	want := "postgresql://svc@db.example.com:26257/svc" +
+		"?application_name=svc&sslmode=verify-full"
+
+	got := cc.SafeURL("svc")
+	if got != want {
+		t.Fatalf("wanted the URL %q, got %q", want, got)
+	}
+}
+
+func TestConnectWithRetry(t *testing.T) {
+	// Find a port that nothing is listening on.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	addr := lis.Addr().String()
+
+	_ = lis.Close()
+
+	cc := ConnectionConfig{
+		certDir: t.TempDir(),
+		user:    "svc",
+		host:    addr,
+		dbParams: url.Values{
+			"connect_timeout": {"1"},
+		},
+	}
+
+	samples := map[string]struct {
+		Timeout time.Duration
+		Retry   RetryOptions
+		WantErr string
+	}{
+		"Deadline": {
+			Timeout: 200 * time.Millisecond,
+			Retry:   RetryOptions{InitialBackoff: 10 * time.Millisecond},
+			WantErr: "gave up connecting to the database:",
+		},
+		"MaxAttempts": {
+			Timeout: time.Minute,
+			Retry: RetryOptions{
+				InitialBackoff: 10 * time.Millisecond,
+				MaxAttempts:    3,
+			},
+			WantErr: "after 3 attempts",
+		},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tc.Timeout)
+			defer cancel()
+
+			start := time.Now()
+
+			db, err := connectWithRetry(ctx, &cc, "svc", tc.Retry)
+			if err == nil {
+				_ = db.Close()
+
+				t.Fatal("expected connecting to a closed port to fail")
+			}
+
+			if !strings.Contains(err.Error(), tc.WantErr) {
+				t.Errorf("expected an error containing %q, got: %v", tc.WantErr, err)
+			}
+
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("expected the retries to stop quickly, took %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestConnector(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	addr := lis.Addr().String()
+
+	_ = lis.Close()
+
+	cc := ConnectionConfig{
+		certDir: t.TempDir(),
+		user:    "svc",
+		host:    addr,
+	}
+
+	connector, err := cc.Connector("svc")
+	if err != nil {
+		t.Fatalf("failed to create connector: %v", err)
+	}
+
+	if _, ok := connector.Driver().(*pq.Driver); !ok {
+		t.Fatalf("expected a pq driver, got %T", connector.Driver())
+	}
+
+	db := sql.OpenDB(connector)
+
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	db.SetMaxOpenConns(2)
+
+	if stats := db.Stats(); stats.MaxOpenConnections != 2 {
+		t.Errorf("expected the pool settings to apply, got %d max open connections",
+			stats.MaxOpenConnections)
+	}
+
+	// The connector should dial the configured host.
+	err = db.PingContext(context.Background())
+	if err == nil || !strings.Contains(err.Error(), addr) {
+		t.Fatalf("expected connecting to %s to fail, got: %v", addr, err)
+	}
+}
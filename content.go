@@ -0,0 +1,161 @@
+package panurge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Content types supported by WriteResponse and DecodeRequest.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/protobuf"
+)
+
+// ErrUnsupportedContentType is returned when a request or response
+// can't be handled in the requested content type.
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+// ErrRequestTooLarge is returned by DecodeRequest when the request
+// body exceeds the maximum size.
+var ErrRequestTooLarge = errors.New("request body too large")
+
+// DefaultMaxRequestSize is the maximum request body size in bytes
+// that DecodeRequest reads unless WithMaxRequestSize is used.
+const DefaultMaxRequestSize = 4 << 20
+
+// DecodeOption controls the behaviour of DecodeRequest.
+type DecodeOption func(opts *decodeOptions)
+
+type decodeOptions struct {
+	maxSize int64
+}
+
+// WithMaxRequestSize sets the maximum request body size in bytes.
+func WithMaxRequestSize(size int64) DecodeOption {
+	return func(opts *decodeOptions) {
+		opts.maxSize = size
+	}
+}
+
+// WriteResponse writes v as protobuf if the Accept header of the
+// request asks for it, otherwise as JSON. Protobuf messages are
+// marshalled to JSON the same way as Twirp does it. Responding with
+// protobuf requires v to be a proto.Message.
+func WriteResponse(w http.ResponseWriter, r *http.Request, v any) error {
+	msg, isProto := v.(proto.Message)
+
+	var (
+		data        []byte
+		contentType string
+		err         error
+	)
+
+	switch {
+	case acceptsProtobuf(r.Header.Get("Accept")):
+		if !isProto {
+			return fmt.Errorf("%w: %T is not a protobuf message",
+				ErrUnsupportedContentType, v)
+		}
+
+		contentType = ContentTypeProtobuf
+		data, err = proto.Marshal(msg)
+	case isProto:
+		contentType = ContentTypeJSON
+		data, err = protojson.MarshalOptions{
+			UseProtoNames:   true,
+			EmitUnpopulated: true,
+		}.Marshal(msg)
+	default:
+		contentType = ContentTypeJSON
+		data, err = json.Marshal(v)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal response: %w", err)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+
+	_, err = w.Write(data)
+	if err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+
+	return nil
+}
+
+// DecodeRequest decodes the request body into v based on the
+// Content-Type of the request. Protobuf requests require v to be a
+// proto.Message. Bodies larger than DefaultMaxRequestSize are
+// rejected with ErrRequestTooLarge, see WithMaxRequestSize.
+func DecodeRequest(r *http.Request, v any, options ...DecodeOption) error {
+	opts := decodeOptions{
+		maxSize: DefaultMaxRequestSize,
+	}
+
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnsupportedContentType, err)
+	}
+
+	msg, isProto := v.(proto.Message)
+
+	if mediaType != ContentTypeJSON && mediaType != ContentTypeProtobuf {
+		return fmt.Errorf("%w: %q", ErrUnsupportedContentType, mediaType)
+	}
+
+	if mediaType == ContentTypeProtobuf && !isProto {
+		return fmt.Errorf("%w: %T is not a protobuf message",
+			ErrUnsupportedContentType, v)
+	}
+
+	// Read one byte more than the limit so that we can tell if the
+	// body was too large.
+	data, err := io.ReadAll(io.LimitReader(r.Body, opts.maxSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	if int64(len(data)) > opts.maxSize {
+		return fmt.Errorf("%w: the limit is %d bytes",
+			ErrRequestTooLarge, opts.maxSize)
+	}
+
+	switch {
+	case mediaType == ContentTypeProtobuf:
+		err = proto.Unmarshal(data, msg)
+	case isProto:
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, msg)
+	default:
+		err = json.Unmarshal(data, v)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to decode request: %w", err)
+	}
+
+	return nil
+}
+
+func acceptsProtobuf(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && mediaType == ContentTypeProtobuf {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,112 @@
+package panurge_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	panurge "github.com/navigacontentlab/panurge/v2"
+	"github.com/navigacontentlab/panurge/v2/internal/rpc/testservice"
+	"github.com/navigacontentlab/panurge/v2/pt"
+)
+
+func TestWriteResponseAndDecodeRequest(t *testing.T) {
+	for _, contentType := range []string{
+		panurge.ContentTypeJSON, panurge.ContentTypeProtobuf,
+	} {
+		t.Run(contentType, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept", contentType)
+
+			rec := httptest.NewRecorder()
+
+			err := panurge.WriteResponse(rec, req, &testservice.ThingReq{
+				Name: "Horatio Hornblower",
+			})
+			pt.Must(t, err, "failed to write response")
+
+			if got := rec.Header().Get("Content-Type"); got != contentType {
+				t.Fatalf("expected the content type %q, got %q", contentType, got)
+			}
+
+			req = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(rec.Body.Bytes()))
+			req.Header.Set("Content-Type", contentType)
+
+			var decoded testservice.ThingReq
+
+			err = panurge.DecodeRequest(req, &decoded)
+			pt.Must(t, err, "failed to decode request")
+
+			if decoded.Name != "Horatio Hornblower" {
+				t.Fatalf("expected the name to survive the round trip, got %q", decoded.Name)
+			}
+		})
+	}
+}
+
+func TestWriteResponse__PlainValues(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	err := panurge.WriteResponse(rec, req, map[string]string{"status": "ok"})
+	pt.Must(t, err, "failed to write JSON response")
+
+	if got := rec.Body.String(); got != `{"status":"ok"}` {
+		t.Errorf("unexpected JSON response: %s", got)
+	}
+
+	req.Header.Set("Accept", panurge.ContentTypeProtobuf)
+
+	err = panurge.WriteResponse(httptest.NewRecorder(), req, map[string]string{})
+	if !errors.Is(err, panurge.ErrUnsupportedContentType) {
+		t.Errorf("expected protobuf responses to require a message, got %v", err)
+	}
+}
+
+func TestDecodeRequest__MaxSize(t *testing.T) {
+	body := `{"name":"Horatio Hornblower"}`
+
+	samples := map[string]struct {
+		Options []panurge.DecodeOption
+		Body    string
+		Fail    bool
+	}{
+		"Default": {Body: body},
+		"AtLimit": {
+			Options: []panurge.DecodeOption{panurge.WithMaxRequestSize(int64(len(body)))},
+			Body:    body,
+		},
+		"OverLimit": {
+			Options: []panurge.DecodeOption{panurge.WithMaxRequestSize(int64(len(body) - 1))},
+			Body:    body,
+			Fail:    true,
+		},
+		"OverDefaultLimit": {
+			Body: `{"name":"` + strings.Repeat("a", panurge.DefaultMaxRequestSize) + `"}`,
+			Fail: true,
+		},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.Body))
+			req.Header.Set("Content-Type", panurge.ContentTypeJSON)
+
+			var decoded testservice.ThingReq
+
+			err := panurge.DecodeRequest(req, &decoded, tc.Options...)
+
+			switch {
+			case tc.Fail && !errors.Is(err, panurge.ErrRequestTooLarge):
+				t.Fatalf("expected the request to be too large, got: %v", err)
+			case !tc.Fail && err != nil:
+				t.Fatalf("failed to decode request: %v", err)
+			}
+		})
+	}
+}
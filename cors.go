@@ -1,7 +1,9 @@
 package panurge
 
 import (
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/rs/cors"
@@ -14,9 +16,19 @@ func DefaultCORSDomains() []string {
 
 // CORSOptions controls the behaviour of the CORS middleware.
 type CORSOptions struct {
-	AllowHTTP      bool
+	AllowHTTP bool
+	// AllowedDomains are the domains that origins are allowed
+	// from. Entries with a leading dot, like ".infomaker.io", only
+	// allow subdomains, other entries, like "example.com", also
+	// allow the host itself. An entry with a port, like
+	// "localhost:3000", only allows that port.
 	AllowedDomains []string
-	Custom         cors.Options
+	// MaxSubdomainDepth limits how many subdomain levels an origin
+	// can have below an allowed domain, a depth of 1 allows
+	// "https://app.infomaker.io" but not
+	// "https://a.app.infomaker.io". Zero means no limit.
+	MaxSubdomainDepth int
+	Custom            cors.Options
 }
 
 // DefaultCorsMiddleware creates a middleware with the default
@@ -26,7 +38,9 @@ func DefaultCORSMiddleware() *cors.Cors {
 }
 
 // NewCORSMiddleware creates a CORS middleware suitable for our
-// editorial application APIs.
+// editorial application APIs. Requests without an Origin header, f.ex.
+// from server-to-server clients, aren't CORS requests and are passed
+// through to the handler without any CORS checks.
 func NewCORSMiddleware(opts CORSOptions) *cors.Cors {
 	if len(opts.AllowedDomains) == 0 {
 		opts.AllowedDomains = DefaultCORSDomains()
@@ -39,7 +53,7 @@ func NewCORSMiddleware(opts CORSOptions) *cors.Cors {
 	}
 
 	allowFn := standardAllowOriginFunc(
-		opts.AllowHTTP, opts.AllowedDomains,
+		opts.AllowHTTP, opts.AllowedDomains, opts.MaxSubdomainDepth,
 	)
 
 	if coreOpts.AllowOriginFunc != nil {
@@ -51,16 +65,55 @@ func NewCORSMiddleware(opts CORSOptions) *cors.Cors {
 	return cors.New(coreOpts)
 }
 
+// corsHandler wraps the handler with CORS handling, limited to the
+// paths that filter returns true for if filter is non-nil.
+func corsHandler(c *cors.Cors, filter func(path string) bool, next http.Handler) http.Handler {
+	withCORS := c.Handler(next)
+
+	if filter == nil {
+		return withCORS
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if filter(r.URL.Path) {
+			withCORS.ServeHTTP(w, r)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func standardAllowOriginFunc(
-	allowHTTP bool, allowedDomains []string,
+	allowHTTP bool, allowedDomains []string, maxDepth int,
 ) func(origin string) bool {
 	return func(origin string) bool {
-		if !allowHTTP && !strings.HasPrefix(origin, "https://") {
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+
+		switch {
+		case u.Scheme == "https":
+		case u.Scheme == "http" && allowHTTP:
+		default:
+			return false
+		}
+
+		// An origin is only scheme, host and an optional port.
+		if u.User != nil || (u.Path != "" && u.Path != "/") ||
+			u.RawQuery != "" || u.Fragment != "" {
+			return false
+		}
+
+		host := strings.ToLower(u.Hostname())
+		if host == "" {
 			return false
 		}
 
 		for _, domain := range allowedDomains {
-			if strings.HasSuffix(origin, domain) {
+			if allowedDomain(domain, host, u.Port(), maxDepth) {
 				return true
 			}
 		}
@@ -69,6 +122,57 @@ func standardAllowOriginFunc(
 	}
 }
 
+// allowedDomain checks a host and port against an allowed domain
+// entry. Entries with a leading dot, like ".infomaker.io", only match
+// subdomains. Other entries, like "localhost" or "example.com",
+// match the host itself as well as its subdomains. If the entry has a
+// port, like "localhost:3000", the origin must use the same port.
+func allowedDomain(domain string, host string, port string, maxDepth int) bool {
+	domain = strings.ToLower(domain)
+
+	if h, p, err := net.SplitHostPort(domain); err == nil {
+		if p != port {
+			return false
+		}
+
+		domain = h
+	}
+
+	onlySubdomains := strings.HasPrefix(domain, ".")
+	domain = strings.TrimPrefix(domain, ".")
+
+	if domain == "" {
+		return false
+	}
+
+	if host == domain {
+		return !onlySubdomains
+	}
+
+	subdomain, ok := strings.CutSuffix(host, "."+domain)
+	if !ok || !validSubdomain(subdomain) {
+		return false
+	}
+
+	return maxDepth <= 0 || strings.Count(subdomain, ".")+1 <= maxDepth
+}
+
+// validSubdomain checks that the subdomain part of a host consists
+// of non-empty labels.
+func validSubdomain(subdomain string) bool {
+	if subdomain == "" {
+		return false
+	}
+
+	for _, label := range strings.Split(subdomain, ".") {
+		if label == "" {
+			return false
+		}
+	}
+
+	return true
+}
+
 func anyOfAllowOriginFuncs(funcs ...func(string) bool) func(string) bool {
 	return func(s string) bool {
 		for _, fn := range funcs {
@@ -0,0 +1,76 @@
+package panurge
+
+import "testing"
+
+func TestStandardAllowOriginFunc(t *testing.T) {
+	samples := map[string]struct {
+		Origin    string
+		Domains   []string
+		AllowHTTP bool
+		MaxDepth  int
+		Allowed   bool
+	}{
+		"subdomain":         {Origin: "https://app.infomaker.io", Allowed: true},
+		"with port":         {Origin: "https://app.infomaker.io:8443", Allowed: true},
+		"deep subdomain":    {Origin: "https://a.b.infomaker.io", Allowed: true},
+		"depth limited":     {Origin: "https://a.b.infomaker.io", MaxDepth: 1},
+		"within depth":      {Origin: "https://app.infomaker.io", MaxDepth: 1, Allowed: true},
+		"bare domain":       {Origin: "https://infomaker.io"},
+		"lookalike domain":  {Origin: "https://evil-infomaker.io"},
+		"suffix in path":    {Origin: "https://evil.com/x.infomaker.io"},
+		"suffix in user":    {Origin: "https://app.infomaker.io@evil.com"},
+		"suffix in query":   {Origin: "https://evil.com?.infomaker.io"},
+		"empty label":       {Origin: "https://.infomaker.io"},
+		"http not allowed":  {Origin: "http://app.infomaker.io"},
+		"http allowed":      {Origin: "http://app.infomaker.io", AllowHTTP: true, Allowed: true},
+		"other scheme":      {Origin: "ftp://app.infomaker.io", AllowHTTP: true},
+		"case insensitive":  {Origin: "https://App.Infomaker.IO", Allowed: true},
+		"unparseable":       {Origin: "https://app.infomaker.io:port"},
+		"other allowed one": {Origin: "https://app.navigacloud.com", Allowed: true},
+		"localhost port": {
+			Origin: "http://localhost:3000", Domains: []string{"localhost:3000"},
+			AllowHTTP: true, Allowed: true,
+		},
+		"localhost other port": {
+			Origin: "http://localhost:4000", Domains: []string{"localhost:3000"},
+			AllowHTTP: true,
+		},
+		"localhost without port": {
+			Origin: "http://localhost", Domains: []string{"localhost:3000"},
+			AllowHTTP: true,
+		},
+		"bare localhost": {
+			Origin: "http://localhost:3000", Domains: []string{"localhost"},
+			AllowHTTP: true, Allowed: true,
+		},
+		"apex domain": {
+			Origin: "https://example.com", Domains: []string{"example.com"}, Allowed: true,
+		},
+		"apex subdomain": {
+			Origin: "https://app.example.com", Domains: []string{"example.com"}, Allowed: true,
+		},
+		"apex lookalike": {
+			Origin: "https://evil-example.com", Domains: []string{"example.com"},
+		},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			domains := tc.Domains
+			if domains == nil {
+				domains = DefaultCORSDomains()
+			}
+
+			allow := standardAllowOriginFunc(
+				tc.AllowHTTP, domains, tc.MaxDepth,
+			)
+
+			if got := allow(tc.Origin); got != tc.Allowed {
+				t.Fatalf("expected allowed to be %v for %q, got %v",
+					tc.Allowed, tc.Origin, got)
+			}
+		})
+	}
+}
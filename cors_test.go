@@ -0,0 +1,82 @@
+package panurge_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	panurge "github.com/navigacontentlab/panurge/v2"
+)
+
+func TestCORSMiddleware__NoOrigin(t *testing.T) {
+	var called bool
+
+	handler := panurge.NewCORSMiddleware(panurge.CORSOptions{}).Handler(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			called = true
+
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/twirp/Test/DoThing", nil))
+
+	if !called || rec.Code != http.StatusNoContent {
+		t.Fatalf("expected a request without an origin to reach the handler, got status %d", rec.Code)
+	}
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no CORS headers for a request without an origin, got %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+
+	req := httptest.NewRequest(http.MethodPost, "/twirp/Test/DoThing", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected a disallowed origin to get no CORS headers, got %q", got)
+	}
+}
+
+func TestCORSMiddleware__AllowedDomains(t *testing.T) {
+	handler := panurge.NewCORSMiddleware(panurge.CORSOptions{
+		AllowHTTP:      true,
+		AllowedDomains: []string{"localhost:3000", "example.com"},
+	}).Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	samples := map[string]struct {
+		Origin  string
+		Allowed bool
+	}{
+		"LocalhostPort":  {Origin: "http://localhost:3000", Allowed: true},
+		"LocalhostOther": {Origin: "http://localhost:8080"},
+		"ApexDomain":     {Origin: "https://example.com", Allowed: true},
+		"Subdomain":      {Origin: "https://app.example.com", Allowed: true},
+		"Lookalike":      {Origin: "https://evilexample.com"},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+
+			req := httptest.NewRequest(http.MethodPost, "/twirp/Test/DoThing", nil)
+			req.Header.Set("Origin", tc.Origin)
+
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get("Access-Control-Allow-Origin") == tc.Origin
+			if got != tc.Allowed {
+				t.Fatalf("expected allowed to be %v for %q, got %v",
+					tc.Allowed, tc.Origin, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package panurge
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Environment variables read by OptionsFromEnv and LoggerFromEnv.
+const (
+	EnvPort         = "PORT"
+	EnvInternalPort = "INTERNAL_PORT"
+	EnvImasURL      = "IMAS_URL"
+	EnvVersion      = "VERSION"
+	EnvCORSDomains  = "CORS_DOMAINS"
+	EnvLogLevel     = "LOG_LEVEL"
+)
+
+// OptionsFromEnv creates standard application options from the
+// environment:
+//
+//   - PORT and INTERNAL_PORT: the public and internal ports.
+//   - IMAS_URL: the IMAS URL used for token validation.
+//   - VERSION: the application version.
+//   - CORS_DOMAINS: a comma separated list of allowed CORS domain
+//     suffixes, f.ex. ".infomaker.io,.navigacloud.com".
+//
+// Unset variables are ignored so that the application defaults
+// apply. Note that the CORS option replaces any CORS options set
+// before it.
+//
+// The logger is passed to NewStandardApp rather than set through an
+// option, use LoggerFromEnv to create one that respects LOG_LEVEL.
+func OptionsFromEnv() ([]StandardAppOption, error) {
+	var opts []StandardAppOption
+
+	port, ok, err := portFromEnv(EnvPort)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		opts = append(opts, WithAppPort(port))
+	}
+
+	internalPort, ok, err := portFromEnv(EnvInternalPort)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		opts = append(opts, WithAppInternalPort(internalPort))
+	}
+
+	if imasURL := os.Getenv(EnvImasURL); imasURL != "" {
+		opts = append(opts, WithImasURL(imasURL))
+	}
+
+	if version := os.Getenv(EnvVersion); version != "" {
+		opts = append(opts, WithAppVersion(version))
+	}
+
+	if domains := os.Getenv(EnvCORSDomains); domains != "" {
+		var allowed []string
+
+		for _, d := range strings.Split(domains, ",") {
+			if d = strings.TrimSpace(d); d != "" {
+				allowed = append(allowed, d)
+			}
+		}
+
+		opts = append(opts, WithTwirpCORSOptions(CORSOptions{
+			AllowedDomains: allowed,
+		}))
+	}
+
+	return opts, nil
+}
+
+// LoggerFromEnv creates a logger using the log level from LOG_LEVEL,
+// see Logger.
+func LoggerFromEnv(writer io.Writer, more ...io.Writer) *slog.Logger {
+	return Logger(os.Getenv(EnvLogLevel), writer, more...)
+}
+
+func portFromEnv(name string) (int, bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, false, nil
+	}
+
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 0 || port > 65535 {
+		return 0, false, fmt.Errorf("invalid port %q in %s", value, name)
+	}
+
+	return port, true, nil
+}
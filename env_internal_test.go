@@ -0,0 +1,70 @@
+package panurge
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOptionsFromEnv__Values(t *testing.T) {
+	samples := map[string]struct {
+		Env  map[string]string
+		Want StandardApp
+	}{
+		"Empty": {
+			Want: StandardApp{port: 8081, internalPort: 8090, version: "dev"},
+		},
+		"PublicPort": {
+			Env:  map[string]string{EnvPort: "8080"},
+			Want: StandardApp{port: 8080, internalPort: 8090, version: "dev"},
+		},
+		"InternalPort": {
+			Env:  map[string]string{EnvInternalPort: "9090"},
+			Want: StandardApp{port: 8081, internalPort: 9090, version: "dev"},
+		},
+		"All": {
+			Env: map[string]string{
+				EnvPort:         "8080",
+				EnvInternalPort: "9090",
+				EnvImasURL:      "https://imas.example.com",
+				EnvVersion:      "v1.2.3",
+				EnvCORSDomains:  ".example.com, ,.example.org",
+			},
+			Want: StandardApp{
+				port:         8080,
+				internalPort: 9090,
+				imasURL:      "https://imas.example.com",
+				version:      "v1.2.3",
+				cors: CORSOptions{
+					AllowedDomains: []string{".example.com", ".example.org"},
+				},
+			},
+		},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			for _, name := range []string{
+				EnvPort, EnvInternalPort, EnvImasURL, EnvVersion, EnvCORSDomains,
+			} {
+				t.Setenv(name, tc.Env[name])
+			}
+
+			opts, err := OptionsFromEnv()
+			if err != nil {
+				t.Fatalf("failed to read options from the environment: %v", err)
+			}
+
+			app := StandardApp{port: 8081, internalPort: 8090, version: "dev"}
+
+			for _, opt := range opts {
+				opt(&app)
+			}
+
+			if !reflect.DeepEqual(app, tc.Want) {
+				t.Fatalf("wanted %#v, got %#v", tc.Want, app)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package panurge_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	panurge "github.com/navigacontentlab/panurge/v2"
+	"github.com/navigacontentlab/panurge/v2/pt"
+)
+
+func TestOptionsFromEnv(t *testing.T) {
+	var env pt.TestEnv
+
+	t.Cleanup(env.Cleanup)
+
+	env.SetAll(map[string]string{
+		panurge.EnvPort:         "",
+		panurge.EnvInternalPort: "",
+		panurge.EnvImasURL:      "",
+		panurge.EnvVersion:      "",
+		panurge.EnvCORSDomains:  "",
+	})
+
+	opts, err := panurge.OptionsFromEnv()
+	pt.Must(t, err, "failed to read options from an empty environment")
+
+	if len(opts) != 0 {
+		t.Errorf("expected no options from an empty environment, got %d", len(opts))
+	}
+
+	env.Set(panurge.EnvInternalPort, "http")
+
+	_, err = panurge.OptionsFromEnv()
+	if err == nil {
+		t.Error("expected an invalid port to fail")
+	}
+}
+
+func TestLoggerFromEnv(t *testing.T) {
+	var env pt.TestEnv
+
+	t.Cleanup(env.Cleanup)
+
+	env.Set(panurge.EnvLogLevel, "debug")
+
+	logger := panurge.LoggerFromEnv(io.Discard)
+
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("expected debug logging to be enabled")
+	}
+
+	env.Set(panurge.EnvLogLevel, "")
+
+	logger = panurge.LoggerFromEnv(io.Discard)
+
+	if logger.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("expected the default level to be warn")
+	}
+}
@@ -2,26 +2,93 @@ package panurge
 
 import (
 	"context"
+	"encoding/json"
 	"expvar"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/pprof"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type HealthcheckFunc func(ctx context.Context) error
 
+// InternalMuxOption controls which endpoints StandardInternalMux
+// mounts.
+type InternalMuxOption func(o *internalMuxOptions)
+
+type internalMuxOptions struct {
+	noProfiling bool
+	noDetails   bool
+	metricsPath string
+	healthPath  string
+}
+
+// WithoutHealthcheckDetails makes the health check respond with a
+// generic failure without the error message.
+func WithoutHealthcheckDetails() InternalMuxOption {
+	return func(o *internalMuxOptions) {
+		o.noDetails = true
+	}
+}
+
+// WithMetricsPath mounts the Prometheus metrics at the path instead
+// of "/metrics".
+func WithMetricsPath(path string) InternalMuxOption {
+	return func(o *internalMuxOptions) {
+		o.metricsPath = path
+	}
+}
+
+// WithHealthPath mounts the health check at the path instead of
+// "/health".
+func WithHealthPath(path string) InternalMuxOption {
+	return func(o *internalMuxOptions) {
+		o.healthPath = path
+	}
+}
+
+// WithoutProfiling omits the pprof and expvar endpoints.
+func WithoutProfiling() InternalMuxOption {
+	return func(o *internalMuxOptions) {
+		o.noProfiling = true
+	}
+}
+
+// StandardInternalMux creates a mux with the Prometheus metrics,
+// health check, pprof and expvar endpoints. As the mux is meant for
+// the internal port the health check includes the error message in
+// failure responses, unless WithoutHealthcheckDetails is used.
 func StandardInternalMux(
-	logger *slog.Logger, test HealthcheckFunc,
+	logger *slog.Logger, test HealthcheckFunc, options ...InternalMuxOption,
 ) *http.ServeMux {
+	opts := internalMuxOptions{
+		metricsPath: "/metrics",
+		healthPath:  "/health",
+	}
+
+	for _, o := range options {
+		o(&opts)
+	}
+
 	mux := http.NewServeMux()
 
 	// Prometheus metrics
-	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle(opts.metricsPath, promhttp.Handler())
+
+	var healthOpts []HealthcheckOption
+
+	if !opts.noDetails {
+		healthOpts = append(healthOpts, WithHealthcheckErrorDetails())
+	}
 
-	mux.Handle("/health", HealthcheckHandler(logger, test))
+	mux.Handle(opts.healthPath, HealthcheckHandler(logger, test, healthOpts...))
+
+	if opts.noProfiling {
+		return mux
+	}
 
 	// PPROF endpoints for live profiles
 	mux.HandleFunc("/debug/pprof/", pprof.Index)
@@ -39,9 +106,39 @@ func StandardInternalMux(
 	return mux
 }
 
+// HealthcheckOption controls the behaviour of the health check
+// handler.
+type HealthcheckOption func(o *healthcheckOptions)
+
+type healthcheckOptions struct {
+	details bool
+}
+
+// WithHealthcheckErrorDetails includes the error message in failure
+// responses. Only use it for handlers that aren't publicly exposed,
+// as the message can contain sensitive details.
+func WithHealthcheckErrorDetails() HealthcheckOption {
+	return func(o *healthcheckOptions) {
+		o.details = true
+	}
+}
+
+type healthcheckResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HealthcheckHandler responds with the status "pass" if the test
+// succeeds, and "fail" otherwise.
 func HealthcheckHandler(
-	logger *slog.Logger, test HealthcheckFunc,
+	logger *slog.Logger, test HealthcheckFunc, options ...HealthcheckOption,
 ) http.Handler {
+	var opts healthcheckOptions
+
+	for _, o := range options {
+		o(&opts)
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Content-Type", "application/json")
 
@@ -49,13 +146,86 @@ func HealthcheckHandler(
 		if err != nil {
 			logger.Error(fmt.Sprintf("healthcheck failed. %v", err))
 
+			resp := healthcheckResponse{Status: "fail"}
+
+			if opts.details {
+				resp.Error = err.Error()
+			}
+
 			w.WriteHeader(http.StatusInternalServerError)
-			_, _ = fmt.Fprintln(w, `{"status": "fail"}`)
+			_ = json.NewEncoder(w).Encode(resp)
 
 			return
 		}
 
-		_, _ = fmt.Fprintln(w, `{"status": "pass"}`)
+		_ = json.NewEncoder(w).Encode(healthcheckResponse{Status: "pass"})
+	})
+}
+
+// ReadinessOptions controls the behaviour of the readiness handler.
+type ReadinessOptions struct {
+	// Tracker is used to report the number of requests in flight
+	// and the drain status.
+	Tracker *InFlightTracker
+	// DrainTimeout is how long readiness reports "draining"
+	// while there are requests in flight, after that it reports
+	// "drained". Readiness fails during the whole drain regardless
+	// of the timeout. When zero readiness reports "drained" as
+	// soon as the drain starts.
+	DrainTimeout time.Duration
+}
+
+type readinessResponse struct {
+	Status   string `json:"status"`
+	InFlight int64  `json:"in_flight"` //nolint:tagliatelle
+}
+
+// ReadinessHandler reports whether the application is ready to serve
+// traffic together with the number of requests in flight. During
+// drain it always responds with 503 Service Unavailable so that load
+// balancers stop sending traffic, with the status "draining" while
+// requests are in flight, and "drained" once they have finished.
+func ReadinessHandler(
+	logger *slog.Logger, test HealthcheckFunc, opts ReadinessOptions,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		var resp readinessResponse
+
+		var (
+			drainStart time.Time
+			draining   bool
+		)
+
+		status := http.StatusOK
+
+		if opts.Tracker != nil {
+			resp.InFlight = opts.Tracker.InFlight()
+			drainStart, draining = opts.Tracker.Draining()
+		}
+
+		switch {
+		case draining && resp.InFlight > 0 && time.Since(drainStart) < opts.DrainTimeout:
+			resp.Status = "draining"
+			status = http.StatusServiceUnavailable
+		case draining:
+			resp.Status = "drained"
+			status = http.StatusServiceUnavailable
+		default:
+			resp.Status = "pass"
+
+			if err := test(r.Context()); err != nil {
+				logger.Error(fmt.Sprintf("readiness check failed. %v", err))
+
+				resp.Status = "fail"
+				status = http.StatusInternalServerError
+			}
+		}
+
+		w.WriteHeader(status)
+
+		_ = json.NewEncoder(w).Encode(resp)
 	})
 }
 
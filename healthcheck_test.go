@@ -0,0 +1,130 @@
+package panurge_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	panurge "github.com/navigacontentlab/panurge/v2"
+	"github.com/navigacontentlab/panurge/v2/pt"
+)
+
+func TestHealthcheckHandler(t *testing.T) {
+	logger := panurge.Logger("error", pt.NewTestLogWriter(t))
+
+	failing := func(_ context.Context) error {
+		return errors.New("db ping timeout")
+	}
+
+	samples := map[string]struct {
+		Options   []panurge.HealthcheckOption
+		WantError string
+	}{
+		"Generic": {},
+		"Detailed": {
+			Options:   []panurge.HealthcheckOption{panurge.WithHealthcheckErrorDetails()},
+			WantError: "db ping timeout",
+		},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			handler := panurge.HealthcheckHandler(logger, failing, tc.Options...)
+
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+			if rec.Code != http.StatusInternalServerError {
+				t.Fatalf("expected a 500 response, got %d", rec.Code)
+			}
+
+			var body struct {
+				Status string `json:"status"`
+				Error  string `json:"error"`
+			}
+
+			err := json.Unmarshal(rec.Body.Bytes(), &body)
+			pt.Must(t, err, "failed to decode response")
+
+			if body.Status != "fail" || body.Error != tc.WantError {
+				t.Fatalf("unexpected response body %q", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestReadinessHandler__DrainTimeout(t *testing.T) {
+	logger := panurge.Logger("warning", pt.NewTestLogWriter(t))
+
+	samples := map[string]struct {
+		InFlight     bool
+		DrainTimeout time.Duration
+		WantStatus   string
+	}{
+		"InFlight":        {InFlight: true, DrainTimeout: time.Minute, WantStatus: "draining"},
+		"TimeoutPassed":   {InFlight: true, DrainTimeout: time.Nanosecond, WantStatus: "drained"},
+		"NothingInFlight": {DrainTimeout: time.Minute, WantStatus: "drained"},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			tracker := panurge.NewInFlightTracker()
+
+			if tc.InFlight {
+				release := make(chan struct{})
+				started := make(chan struct{})
+
+				t.Cleanup(func() {
+					close(release)
+				})
+
+				handler := tracker.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+					close(started)
+					<-release
+				}))
+
+				go handler.ServeHTTP(httptest.NewRecorder(),
+					httptest.NewRequest(http.MethodPost, "/", nil))
+
+				<-started
+			}
+
+			readiness := panurge.ReadinessHandler(logger, panurge.NoopHealthcheck,
+				panurge.ReadinessOptions{
+					Tracker:      tracker,
+					DrainTimeout: tc.DrainTimeout,
+				})
+
+			tracker.StartDrain()
+			time.Sleep(time.Millisecond)
+
+			rec := httptest.NewRecorder()
+
+			readiness.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+			if rec.Code != http.StatusServiceUnavailable {
+				t.Fatalf("expected a 503 response during drain, got %d", rec.Code)
+			}
+
+			var body struct {
+				Status string `json:"status"`
+			}
+
+			err := json.Unmarshal(rec.Body.Bytes(), &body)
+			pt.Must(t, err, "failed to decode response")
+
+			if body.Status != tc.WantStatus {
+				t.Fatalf("wanted the status %q, got %q", tc.WantStatus, body.Status)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"time"
+
+	"github.com/navigacontentlab/panurge/v2/navigaid"
 )
 
 func StandardServer(port int, handler http.Handler) *http.Server {
@@ -27,13 +29,19 @@ func StandardServer(port int, handler http.Handler) *http.Server {
 }
 
 type TestServers struct {
+	// MockNavigaID makes the application set up a NavigaID mock
+	// server that it trusts for access token validation.
+	MockNavigaID *navigaid.MockServerOptions
+
 	public   *httptest.Server
 	internal *httptest.Server
+	navigaID *navigaid.MockServer
 }
 
 func (ts *TestServers) Close() {
 	ts.public.Close()
 	ts.internal.Close()
+	ts.stopNavigaID()
 }
 
 func (ts *TestServers) GetPublic() *httptest.Server {
@@ -44,6 +52,46 @@ func (ts *TestServers) GetInternal() *httptest.Server {
 	return ts.internal
 }
 
+// NavigaID returns the NavigaID mock server, or nil if MockNavigaID
+// wasn't set.
+func (ts *TestServers) NavigaID() *navigaid.MockServer {
+	return ts.navigaID
+}
+
+// AccessTokenService returns an access token service that creates
+// tokens using the NavigaID mock server, or nil if MockNavigaID
+// wasn't set.
+func (ts *TestServers) AccessTokenService() *navigaid.AccessTokenService {
+	if ts.navigaID == nil {
+		return nil
+	}
+
+	return navigaid.New(
+		navigaid.AccessTokenEndpoint(ts.navigaID.Server.URL),
+		navigaid.WithAccessTokenClient(ts.navigaID.Client),
+	)
+}
+
+func (ts *TestServers) startNavigaID() (navigaid.Validator, error) {
+	mock, err := navigaid.NewMockServer(*ts.MockNavigaID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start NavigaID mock server: %w", err)
+	}
+
+	ts.navigaID = mock
+
+	return navigaid.NewJWKS(
+		navigaid.ImasJWKSEndpoint(mock.Server.URL),
+		navigaid.WithJwksClient(mock.Client),
+	), nil
+}
+
+func (ts *TestServers) stopNavigaID() {
+	if ts.navigaID != nil {
+		ts.navigaID.Server.Close()
+	}
+}
+
 func WithAppTestServers(ts *TestServers) StandardAppOption {
 	return func(app *StandardApp) {
 		app.testServers = ts
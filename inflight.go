@@ -0,0 +1,102 @@
+package panurge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InFlightTracker keeps track of the number of requests that are
+// currently being handled, and whether the application is draining.
+type InFlightTracker struct {
+	count      atomic.Int64
+	drainStart atomic.Pointer[time.Time]
+}
+
+// NewInFlightTracker creates a new in-flight request tracker.
+func NewInFlightTracker() *InFlightTracker {
+	return &InFlightTracker{}
+}
+
+// Middleware counts the requests handled by the next handler.
+func (t *InFlightTracker) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.count.Add(1)
+		defer t.count.Add(-1)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// InFlight returns the number of requests that are currently being
+// handled.
+func (t *InFlightTracker) InFlight() int64 {
+	return t.count.Load()
+}
+
+// StartDrain marks the application as draining. Calling it more than
+// once has no effect.
+func (t *InFlightTracker) StartDrain() {
+	now := time.Now()
+
+	t.drainStart.CompareAndSwap(nil, &now)
+}
+
+// Draining returns true and the time the drain started if the
+// application is draining.
+func (t *InFlightTracker) Draining() (time.Time, bool) {
+	start := t.drainStart.Load()
+	if start == nil {
+		return time.Time{}, false
+	}
+
+	return *start, true
+}
+
+// WaitForDrain blocks until there are no requests in flight or the
+// context is cancelled.
+func (t *InFlightTracker) WaitForDrain(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for t.InFlight() > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d requests still in flight: %w",
+				t.InFlight(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+// RegisterGauge registers a gauge that reports the number of
+// requests in flight, using the registerer and namespace from the
+// metrics options. Nothing is registered if the metrics have been
+// disabled with a nil registerer.
+func (t *InFlightTracker) RegisterGauge(opts ...TwirpMetricOptionFunc) error {
+	opt := newTwirpMetricsOptions(opts)
+	if opt.reg == nil {
+		return nil
+	}
+
+	gauge := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: opt.namespace,
+		Subsystem: opt.subsystem,
+		Name:      "http_requests_in_flight",
+		Help:      "Number of HTTP requests currently being handled.",
+	}, func() float64 {
+		return float64(t.InFlight())
+	})
+
+	if err := opt.reg.Register(gauge); err != nil {
+		return fmt.Errorf("failed to register metric: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package panurge_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	panurge "github.com/navigacontentlab/panurge/v2"
+	"github.com/navigacontentlab/panurge/v2/pt"
+)
+
+type readinessResult struct {
+	Status   string `json:"status"`
+	InFlight int64  `json:"in_flight"` //nolint:tagliatelle
+}
+
+func TestReadinessHandler_Drain(t *testing.T) {
+	logger := panurge.Logger("warning", pt.NewTestLogWriter(t))
+	tracker := panurge.NewInFlightTracker()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	handler := tracker.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	go handler.ServeHTTP(httptest.NewRecorder(),
+		httptest.NewRequest(http.MethodPost, "/", nil))
+
+	<-started
+
+	readiness := panurge.ReadinessHandler(logger, panurge.NoopHealthcheck,
+		panurge.ReadinessOptions{
+			Tracker:      tracker,
+			DrainTimeout: time.Minute,
+		})
+
+	check := func(wantCode int, want readinessResult) {
+		t.Helper()
+
+		rec := httptest.NewRecorder()
+
+		readiness.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+		var got readinessResult
+
+		err := json.Unmarshal(rec.Body.Bytes(), &got)
+		pt.Must(t, err, "failed to parse readiness response")
+
+		if rec.Code != wantCode || got != want {
+			t.Fatalf("wanted %d %+v, got %d %+v", wantCode, want, rec.Code, got)
+		}
+	}
+
+	check(http.StatusOK, readinessResult{Status: "pass", InFlight: 1})
+
+	tracker.StartDrain()
+
+	check(http.StatusServiceUnavailable, readinessResult{Status: "draining", InFlight: 1})
+
+	close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := tracker.WaitForDrain(ctx)
+	pt.Must(t, err, "failed to wait for drain")
+
+	check(http.StatusServiceUnavailable, readinessResult{Status: "drained", InFlight: 0})
+}
+
+func TestReadinessHandler_DrainWithoutTimeout(t *testing.T) {
+	logger := panurge.Logger("warning", pt.NewTestLogWriter(t))
+	tracker := panurge.NewInFlightTracker()
+
+	readiness := panurge.ReadinessHandler(logger, panurge.NoopHealthcheck,
+		panurge.ReadinessOptions{
+			Tracker: tracker,
+		})
+
+	tracker.StartDrain()
+
+	rec := httptest.NewRecorder()
+
+	readiness.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+	if rec.Code >= 200 && rec.Code < 300 {
+		t.Fatalf("expected readiness to fail as soon as the drain starts, got %d", rec.Code)
+	}
+}
@@ -1,8 +1,10 @@
 package panurge
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"sync"
 )
 
 type ErrorLogger interface {
@@ -35,3 +37,47 @@ func SafeFailingClose(logger ErrorLogger, outErr *error, name string, c io.Close
 		logger.Errorf("failed to close %s: %v", name, err)
 	}
 }
+
+// TeeWriter is an io.Writer that writes everything to a set of
+// writers. Unlike io.MultiWriter it keeps writing to the remaining
+// writers when one fails, so that a broken log file doesn't stop
+// console logging. Writes are serialised, so that every writer gets
+// whole log entries in the same order.
+type TeeWriter struct {
+	m       sync.Mutex
+	writers []io.Writer
+}
+
+// NewTeeWriter creates a writer that writes to all the given writers.
+func NewTeeWriter(writers ...io.Writer) *TeeWriter {
+	return &TeeWriter{
+		writers: writers,
+	}
+}
+
+// Write writes p to all writers. The returned error joins the errors
+// of the writers that failed.
+func (w *TeeWriter) Write(p []byte) (int, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	var errs []error
+
+	for _, writer := range w.writers {
+		n, err := writer.Write(p)
+		if err == nil && n != len(p) {
+			err = io.ErrShortWrite
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return len(p), fmt.Errorf("failed to write to all writers: %w",
+			errors.Join(errs...))
+	}
+
+	return len(p), nil
+}
@@ -1,6 +1,7 @@
 package panurge_test
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -79,6 +80,36 @@ func Test_SafeFailingClose__NoopOnNoErr(t *testing.T) {
 	}
 }
 
+func TestTeeWriter(t *testing.T) {
+	var first, second bytes.Buffer
+
+	writeErr := testDummyErr("disk full")
+
+	logger := panurge.Logger("info", &first, failingWriter{Err: writeErr}, &second)
+
+	logger.Info("hello")
+
+	if first.Len() == 0 || first.String() != second.String() {
+		t.Fatalf("expected both writers to get the same log entry, got %q and %q",
+			first.String(), second.String())
+	}
+
+	tee := panurge.NewTeeWriter(failingWriter{Err: writeErr})
+
+	_, err := tee.Write([]byte("test"))
+	if !errors.Is(err, writeErr) {
+		t.Fatalf("expected the write error to be returned, got: %v", err)
+	}
+}
+
+type failingWriter struct {
+	Err error
+}
+
+func (fw failingWriter) Write(_ []byte) (int, error) {
+	return 0, fw.Err
+}
+
 func testDummyErr(msg string) error {
 	return fmt.Errorf("%s [%d]", msg, time.Now().Unix())
 }
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 )
@@ -49,14 +50,170 @@ type HandlerFunc func(
 	ctx context.Context, event Request,
 ) (Response, error)
 
-func Handler(handler http.Handler, logger *slog.Logger) HandlerFunc {
+type handlerOptions struct {
+	accessLogLevel     slog.Level
+	requestMiddleware  RequestMiddleware
+	responseMiddleware ResponseMiddleware
+	responseLimits     *ResponseLimitOptions
+	healthCheckPaths   map[string]bool
+}
+
+// ResponseLimitOptions controls the validation of response sizes. A
+// zero value for the limits means that the platform defaults are
+// used.
+type ResponseLimitOptions struct {
+	ALB        ResponseLimits
+	APIGateway ResponseLimits
+	// Fail makes the handler return an error for responses that
+	// exceed the limits, instead of logging a warning.
+	Fail bool
+}
+
+func (o ResponseLimitOptions) limitsFor(event Request) ResponseLimits {
+	if event.RequestContext.ELB.TargetGroupArn != "" {
+		if o.ALB == (ResponseLimits{}) {
+			return DefaultALBResponseLimits()
+		}
+
+		return o.ALB
+	}
+
+	if o.APIGateway == (ResponseLimits{}) {
+		return DefaultAPIGatewayResponseLimits()
+	}
+
+	return o.APIGateway
+}
+
+// RequestMiddleware can modify or replace the HTTP request that was
+// created from the lambda event before it's passed to the handler.
+type RequestMiddleware func(req *http.Request) (*http.Request, error)
+
+// ResponseMiddleware can post-process the lambda response before
+// it's returned.
+type ResponseMiddleware func(req *http.Request, res Response) (Response, error)
+
+// HandlerOption controls the behaviour of the lambda handler.
+type HandlerOption func(opts *handlerOptions)
+
+// WithAccessLogLevel sets the level used for the access log entries,
+// defaults to info. Use a level that the logger doesn't have enabled
+// to silence the access log.
+func WithAccessLogLevel(level slog.Level) HandlerOption {
+	return func(opts *handlerOptions) {
+		opts.accessLogLevel = level
+	}
+}
+
+// WithRequestMiddleware sets a middleware that's applied to the HTTP
+// request before it's passed to the handler.
+func WithRequestMiddleware(fn RequestMiddleware) HandlerOption {
+	return func(opts *handlerOptions) {
+		opts.requestMiddleware = fn
+	}
+}
+
+// WithResponseMiddleware sets a middleware that's applied to the
+// lambda response before it's returned.
+func WithResponseMiddleware(fn ResponseMiddleware) HandlerOption {
+	return func(opts *handlerOptions) {
+		opts.responseMiddleware = fn
+	}
+}
+
+// WithResponseLimits enables validation of the response size against
+// the limits of the platform that invoked the lambda function.
+func WithResponseLimits(limits ResponseLimitOptions) HandlerOption {
+	return func(opts *handlerOptions) {
+		opts.responseLimits = &limits
+	}
+}
+
+// DefaultHealthCheckPath is the health check path used by
+// WithHealthCheckPaths when no paths are given.
+const DefaultHealthCheckPath = "/health"
+
+// WithHealthCheckPaths makes the handler respond to requests for the
+// given paths, to requests with an empty path, and to requests from
+// the ALB health checker, with a 200 OK without invoking the HTTP
+// handler or writing an access log entry. DefaultHealthCheckPath is
+// used if no paths are given.
+func WithHealthCheckPaths(paths ...string) HandlerOption {
+	return func(opts *handlerOptions) {
+		if opts.healthCheckPaths == nil {
+			opts.healthCheckPaths = make(map[string]bool)
+		}
+
+		if len(paths) == 0 {
+			paths = []string{DefaultHealthCheckPath}
+		}
+
+		for _, p := range paths {
+			opts.healthCheckPaths[p] = true
+		}
+	}
+}
+
+// albHealthCheckerAgent is the user agent prefix used by ALB health
+// checks.
+const albHealthCheckerAgent = "ELB-HealthChecker"
+
+func (opts handlerOptions) isHealthCheck(req *http.Request) bool {
+	if opts.healthCheckPaths == nil {
+		return false
+	}
+
+	// ALB health checks can be sent without a path.
+	return req.URL.Path == "" || opts.healthCheckPaths[req.URL.Path] ||
+		strings.HasPrefix(req.UserAgent(), albHealthCheckerAgent)
+}
+
+func healthCheckResponse() Response {
+	return Response{
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type": "text/plain; charset=utf-8",
+		},
+		Body: "OK",
+	}
+}
+
+func Handler(handler http.Handler, logger *slog.Logger, options ...HandlerOption) HandlerFunc {
+	opts := handlerOptions{
+		accessLogLevel: slog.LevelInfo,
+	}
+
+	for _, o := range options {
+		o(&opts)
+	}
+
 	return func(ctx context.Context, event Request) (Response, error) {
 		req, err := AWSRequestToHTTPRequest(ctx, event)
+		if err != nil {
+			logger.Error(fmt.Sprintf("failed to convert event to request. %v", err))
+
+			return Response{}, fmt.Errorf(
+				"failed to convert event to a request: %w", err)
+		}
+
+		if opts.isHealthCheck(req) {
+			return healthCheckResponse(), nil
+		}
+
+		if opts.requestMiddleware != nil {
+			req, err = opts.requestMiddleware(req)
+			if err != nil {
+				return Response{}, fmt.Errorf(
+					"request middleware failed: %w", err)
+			}
+		}
 
 		var attr []slog.Attr
 		attr = append(attr, slog.String("Method", req.Method))
 		attr = append(attr, slog.String("host", req.Host))
-		attr = append(attr, slog.String("URI", req.RequestURI))
+		// Only log the path, the query string can contain
+		// tokens, see navigaid.WithQueryToken.
+		attr = append(attr, slog.String("URI", req.URL.Path))
 		attr = append(attr, slog.Any("Headers", req.Header))
 
 		args := make([]any, 0, len(attr)*2)
@@ -66,21 +223,77 @@ func Handler(handler http.Handler, logger *slog.Logger) HandlerFunc {
 
 		logger.Debug("GeneratedHTTPRequest", args...)
 
+		w := NewProxyResponseWriter()
+
+		start := time.Now()
+
+		handler.ServeHTTP(w, req)
+
+		duration := time.Since(start)
+
+		logger.Log(ctx, opts.accessLogLevel, "access",
+			"method", req.Method,
+			"path", req.URL.Path,
+			"status", w.Status(),
+			"duration_ms", duration.Milliseconds(),
+			"latency_bucket", latencyBucket(duration),
+			"bytes", w.body.Len(),
+		)
+
+		res, err := w.GetLambdaResponse()
 		if err != nil {
-			logger.Error(fmt.Sprintf("failed to convert event to request. %v", err))
+			return Response{}, err
+		}
 
-			return Response{}, fmt.Errorf(
-				"failed to convert event to a request: %w", err)
+		if opts.responseMiddleware != nil {
+			res, err = opts.responseMiddleware(req, res)
+			if err != nil {
+				return Response{}, fmt.Errorf(
+					"response middleware failed: %w", err)
+			}
 		}
 
-		w := NewProxyResponseWriter()
+		if opts.responseLimits != nil {
+			err := res.CheckLimits(opts.responseLimits.limitsFor(event))
 
-		handler.ServeHTTP(w, req)
+			switch {
+			case err != nil && opts.responseLimits.Fail:
+				return Response{}, fmt.Errorf(
+					"response exceeds platform limits: %w", err)
+			case err != nil:
+				logger.WarnContext(ctx, "response exceeds platform limits",
+					"err", err,
+					"path", req.URL.Path,
+				)
+			}
+		}
 
-		return w.GetLambdaResponse()
+		return res, nil
 	}
 }
 
+// latencyBuckets are the upper bounds used to classify request
+// latency in the access log.
+var latencyBuckets = []struct {
+	limit time.Duration
+	name  string
+}{
+	{limit: 100 * time.Millisecond, name: "<100ms"},
+	{limit: 500 * time.Millisecond, name: "100ms-500ms"},
+	{limit: time.Second, name: "500ms-1s"},
+	{limit: 5 * time.Second, name: "1s-5s"},
+}
+
+func latencyBucket(d time.Duration) string {
+	for _, b := range latencyBuckets {
+		if d < b.limit {
+			return b.name
+		}
+	}
+
+	return ">5s"
+}
+
 func AWSRequestToHTTPRequest(ctx context.Context, event Request) (*http.Request, error) {
 	HTTPMethod := event.HTTPMethod
 	if event.Version == "2.0" {
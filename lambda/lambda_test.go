@@ -0,0 +1,223 @@
+package lambda_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	panurge "github.com/navigacontentlab/panurge/v2"
+	"github.com/navigacontentlab/panurge/v2/lambda"
+	"github.com/navigacontentlab/panurge/v2/pt"
+)
+
+func albEvent(method string, path string) lambda.Request {
+	event := lambda.Request{
+		Headers: map[string]string{
+			"Host": "example.com",
+		},
+	}
+
+	event.HTTPMethod = method
+	event.Path = path
+
+	return event
+}
+
+func TestHandler__QueryTokenNotLogged(t *testing.T) {
+	var logs bytes.Buffer
+
+	logger := panurge.Logger("debug", &logs)
+
+	handler := lambda.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), logger)
+
+	event := albEvent(http.MethodGet, "/download")
+	event.QueryStringParameters = map[string]string{
+		"access_token": "secret-token",
+	}
+
+	_, err := handler(pt.TestContext(t), event)
+	pt.Must(t, err, "failed to handle event")
+
+	if !strings.Contains(logs.String(), "/download") {
+		t.Fatalf("expected the path to be logged, got %q", logs.String())
+	}
+
+	if strings.Contains(logs.String(), "secret-token") {
+		t.Fatalf("expected the query token to not be logged, got %q", logs.String())
+	}
+}
+
+func TestHandler__HealthCheckPaths(t *testing.T) {
+	samples := map[string]struct {
+		Disabled    bool
+		Paths       []string
+		Path        string
+		UserAgent   string
+		WantHandled bool
+	}{
+		"ConfiguredPath": {Paths: []string{"/ping"}, Path: "/ping"},
+		"DefaultPath":    {Path: "/health"},
+		"EmptyPath":      {Paths: []string{"/ping"}, Path: ""},
+		"HealthChecker":  {Path: "/", UserAgent: "ELB-HealthChecker/2.0"},
+		"OtherPath":      {Paths: []string{"/ping"}, Path: "/health", WantHandled: true},
+		"Disabled":       {Disabled: true, Path: "", WantHandled: true},
+		"RegularRequest": {Paths: []string{"/ping"}, Path: "/api", WantHandled: true},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			var handled bool
+
+			var opts []lambda.HandlerOption
+
+			if !tc.Disabled {
+				opts = append(opts, lambda.WithHealthCheckPaths(tc.Paths...))
+			}
+
+			handler := lambda.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				handled = true
+
+				w.WriteHeader(http.StatusNoContent)
+			}), panurge.Logger("warning", pt.NewTestLogWriter(t)), opts...)
+
+			event := albEvent(http.MethodGet, tc.Path)
+
+			if tc.UserAgent != "" {
+				event.Headers["User-Agent"] = tc.UserAgent
+			}
+
+			res, err := handler(pt.TestContext(t), event)
+			pt.Must(t, err, "failed to handle event")
+
+			if handled != tc.WantHandled {
+				t.Fatalf("expected the handler to be called to be %v, got %v",
+					tc.WantHandled, handled)
+			}
+
+			if !tc.WantHandled && (res.StatusCode != http.StatusOK || res.Body != "OK") {
+				t.Fatalf("unexpected health check response %d %q", res.StatusCode, res.Body)
+			}
+		})
+	}
+}
+
+func TestHandler__AccessLog(t *testing.T) {
+	samples := map[string]struct {
+		Status     int
+		Body       string
+		WantStatus float64
+		WantBytes  float64
+	}{
+		"NothingWritten": {WantStatus: http.StatusOK},
+		"Status":         {Status: http.StatusNotFound, WantStatus: http.StatusNotFound},
+		"Body":           {Body: "hello", WantStatus: http.StatusOK, WantBytes: 5},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			var logs bytes.Buffer
+
+			handler := lambda.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if tc.Status != 0 {
+					w.WriteHeader(tc.Status)
+				}
+
+				if tc.Body != "" {
+					_, _ = w.Write([]byte(tc.Body))
+				}
+			}), panurge.Logger("info", &logs))
+
+			_, _ = handler(pt.TestContext(t), albEvent(http.MethodGet, "/api"))
+
+			var entry map[string]interface{}
+
+			err := json.Unmarshal(logs.Bytes(), &entry)
+			pt.Must(t, err, "failed to parse the access log entry")
+
+			if entry["msg"] != "access" || entry["path"] != "/api" {
+				t.Fatalf("unexpected access log entry %v", entry)
+			}
+
+			if entry["status"] != tc.WantStatus || entry["bytes"] != tc.WantBytes {
+				t.Fatalf("wanted status %v and %v bytes, got %v",
+					tc.WantStatus, tc.WantBytes, entry)
+			}
+		})
+	}
+}
+
+func TestHandler__Middleware(t *testing.T) {
+	var calls []string
+
+	handler := lambda.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+
+		w.Header().Set("X-Request-Marker", r.Header.Get("X-Request-Marker"))
+		w.WriteHeader(http.StatusNoContent)
+	}), panurge.Logger("error", io.Discard),
+		lambda.WithRequestMiddleware(func(req *http.Request) (*http.Request, error) {
+			calls = append(calls, "request")
+
+			req.Header.Set("X-Request-Marker", "set")
+
+			return req, nil
+		}),
+		lambda.WithResponseMiddleware(func(req *http.Request, res lambda.Response) (lambda.Response, error) {
+			calls = append(calls, "response")
+
+			if req.Header.Get("X-Request-Marker") != "set" {
+				t.Error("expected the response middleware to get the modified request")
+			}
+
+			res.Headers["X-Response-Marker"] = "set"
+
+			return res, nil
+		}),
+	)
+
+	res, err := handler(pt.TestContext(t), albEvent(http.MethodGet, "/api"))
+	pt.Must(t, err, "failed to handle event")
+
+	if strings.Join(calls, ",") != "request,handler,response" {
+		t.Fatalf("unexpected call order %v", calls)
+	}
+
+	if res.Headers["X-Request-Marker"] != "set" || res.Headers["X-Response-Marker"] != "set" {
+		t.Fatalf("expected both markers to be set, got %v", res.Headers)
+	}
+}
+
+func TestHandler__RequestMiddlewareError(t *testing.T) {
+	errRejected := errors.New("rejected")
+
+	var handlerCalled bool
+
+	handler := lambda.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		handlerCalled = true
+
+		w.WriteHeader(http.StatusNoContent)
+	}), panurge.Logger("error", io.Discard),
+		lambda.WithRequestMiddleware(func(_ *http.Request) (*http.Request, error) {
+			return nil, errRejected
+		}),
+	)
+
+	_, err := handler(pt.TestContext(t), albEvent(http.MethodGet, "/api"))
+	if !errors.Is(err, errRejected) {
+		t.Fatalf("expected the middleware error, got %v", err)
+	}
+
+	if handlerCalled {
+		t.Fatal("expected the handler to not be called")
+	}
+}
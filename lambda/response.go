@@ -81,6 +81,16 @@ func (r *ProxyResponseWriter) WriteHeader(status int) {
 	r.status = status
 }
 
+// Status returns the status code of the response. Like net/http it
+// defaults to 200 OK if the handler hasn't written anything.
+func (r *ProxyResponseWriter) Status() int {
+	if r.status == defaultStatusCode {
+		return http.StatusOK
+	}
+
+	return r.status
+}
+
 // GetLambdaResponse converts the data passed to the response writer into
 // an Response object.
 // Returns a populated lambda response object. If the response is invalid, for example
@@ -122,3 +132,76 @@ func (r *ProxyResponseWriter) GetLambdaResponse() (Response, error) {
 		Cookies:           []string{},
 	}, nil
 }
+
+// ResponseLimits are the size limits that a platform puts on lambda
+// responses.
+type ResponseLimits struct {
+	// MaxBodySize is the maximum size of the encoded body in bytes.
+	MaxBodySize int
+	// MaxHeaderSize is the maximum total size of the response
+	// header names and values in bytes.
+	MaxHeaderSize int
+}
+
+// DefaultALBResponseLimits returns the response limits for lambda
+// functions that are invoked by an application load balancer.
+func DefaultALBResponseLimits() ResponseLimits {
+	return ResponseLimits{
+		MaxBodySize:   1024 * 1024,
+		MaxHeaderSize: 32 * 1024,
+	}
+}
+
+// DefaultAPIGatewayResponseLimits returns the response limits for
+// lambda functions that are invoked by API Gateway.
+func DefaultAPIGatewayResponseLimits() ResponseLimits {
+	return ResponseLimits{
+		MaxBodySize:   6 * 1024 * 1024,
+		MaxHeaderSize: 10 * 1024,
+	}
+}
+
+// CheckLimits verifies that the response fits within the given
+// limits. A zero limit is not checked. Entries in Headers are counted
+// unless they're duplicated in MultiValueHeaders.
+func (res Response) CheckLimits(limits ResponseLimits) error {
+	if limits.MaxBodySize > 0 && len(res.Body) > limits.MaxBodySize {
+		return fmt.Errorf(
+			"encoded response body is %d bytes, the limit is %d bytes",
+			len(res.Body), limits.MaxBodySize)
+	}
+
+	var headerSize int
+
+	for k, values := range res.MultiValueHeaders {
+		for _, v := range values {
+			headerSize += len(k) + len(v)
+		}
+	}
+
+	for k, v := range res.Headers {
+		if containsValue(res.MultiValueHeaders[k], v) {
+			continue
+		}
+
+		headerSize += len(k) + len(v)
+	}
+
+	if limits.MaxHeaderSize > 0 && headerSize > limits.MaxHeaderSize {
+		return fmt.Errorf(
+			"response headers are %d bytes, the limit is %d bytes",
+			headerSize, limits.MaxHeaderSize)
+	}
+
+	return nil
+}
+
+func containsValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
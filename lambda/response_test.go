@@ -0,0 +1,86 @@
+package lambda_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/navigacontentlab/panurge/v2/lambda"
+)
+
+func TestResponse_CheckLimits(t *testing.T) {
+	limits := lambda.ResponseLimits{
+		MaxBodySize:   10,
+		MaxHeaderSize: 20,
+	}
+
+	samples := map[string]struct {
+		Body         string
+		Headers      map[string][]string
+		SingleHeader map[string]string
+		Limits       lambda.ResponseLimits
+		WantErr      string
+	}{
+		"WithinLimits": {
+			Body:    "0123456789",
+			Headers: map[string][]string{"X-Header": {"0123456789ab"}},
+			Limits:  limits,
+		},
+		"BodyTooLarge": {
+			Body:    "0123456789a",
+			Limits:  limits,
+			WantErr: "response body is 11 bytes",
+		},
+		"HeadersTooLarge": {
+			Headers: map[string][]string{"X-Header": {"0123456789", "abc"}},
+			Limits:  limits,
+			WantErr: "response headers are 29 bytes",
+		},
+		"SingleValueHeadersTooLarge": {
+			SingleHeader: map[string]string{"X-Header": "0123456789abcd"},
+			Limits:       limits,
+			WantErr:      "response headers are 22 bytes",
+		},
+		"SingleAndMultiValueHeadersTooLarge": {
+			Headers:      map[string][]string{"X-Header": {"0123456789"}},
+			SingleHeader: map[string]string{"X-Other": "ab"},
+			Limits:       limits,
+			WantErr:      "response headers are 27 bytes",
+		},
+		"DuplicatedHeaders": {
+			Headers:      map[string][]string{"X-Header": {"0123456789ab"}},
+			SingleHeader: map[string]string{"X-Header": "0123456789ab"},
+			Limits:       limits,
+		},
+		"NoLimits": {
+			Body:    strings.Repeat("a", 1024),
+			Headers: map[string][]string{"X-Header": {strings.Repeat("a", 1024)}},
+		},
+		"OnlyBodyLimit": {
+			Headers: map[string][]string{"X-Header": {strings.Repeat("a", 1024)}},
+			Limits:  lambda.ResponseLimits{MaxBodySize: 10},
+		},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			res := lambda.Response{
+				Body:              tc.Body,
+				Headers:           tc.SingleHeader,
+				MultiValueHeaders: tc.Headers,
+			}
+
+			err := res.CheckLimits(tc.Limits)
+
+			switch {
+			case tc.WantErr == "" && err != nil:
+				t.Fatalf("expected the response to be within limits, got: %v", err)
+			case tc.WantErr != "" && err == nil:
+				t.Fatalf("expected an error containing %q", tc.WantErr)
+			case tc.WantErr != "" && !strings.Contains(err.Error(), tc.WantErr):
+				t.Fatalf("expected an error containing %q, got: %v", tc.WantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package panurge
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/twitchtv/twirp"
+)
+
+// ConcurrencyLimiter caps the number of requests that are handled
+// concurrently.
+type ConcurrencyLimiter struct {
+	sem      chan struct{}
+	rejected prometheus.Counter
+}
+
+// NewConcurrencyLimiter creates a limiter that allows limit concurrent
+// requests, the limit must be positive. Rejected requests are counted by the
+// http_requests_rejected_total metric, which is registered using the
+// registerer and namespace from the metrics options. The counter isn't
+// registered if the metrics have been disabled with a nil registerer.
+func NewConcurrencyLimiter(
+	limit int, opts ...TwirpMetricOptionFunc,
+) (*ConcurrencyLimiter, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf(
+			"the concurrency limit must be positive, got %d", limit)
+	}
+
+	opt := newTwirpMetricsOptions(opts)
+
+	rejected := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: opt.namespace,
+		Subsystem: opt.subsystem,
+		Name:      "http_requests_rejected_total",
+		Help:      "Number of HTTP requests rejected because of the concurrency limit.",
+	})
+	if opt.reg != nil {
+		if err := opt.reg.Register(rejected); err != nil {
+			return nil, fmt.Errorf("failed to register metric: %w", err)
+		}
+	}
+
+	return &ConcurrencyLimiter{
+		sem:      make(chan struct{}, limit),
+		rejected: rejected,
+	}, nil
+}
+
+// Middleware rejects requests when the limit has been reached. Twirp
+// requests get a twirp.ResourceExhausted error and other requests a
+// 503 Service Unavailable response.
+func (l *ConcurrencyLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case l.sem <- struct{}{}:
+		default:
+			l.rejected.Inc()
+			l.reject(w, r)
+
+			return
+		}
+
+		defer func() { <-l.sem }()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *ConcurrencyLimiter) reject(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", "1")
+
+	if !isTwirpRequest(r) {
+		http.Error(w, "too many concurrent requests",
+			http.StatusServiceUnavailable)
+
+		return
+	}
+
+	_ = twirp.WriteError(w, twirp.NewError(
+		twirp.ResourceExhausted, "too many concurrent requests"))
+}
+
+func isTwirpRequest(r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+
+	return mediaType == ContentTypeJSON || mediaType == ContentTypeProtobuf
+}
@@ -0,0 +1,99 @@
+package panurge_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	panurge "github.com/navigacontentlab/panurge/v2"
+	"github.com/navigacontentlab/panurge/v2/pt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+
+	limiter, err := panurge.NewConcurrencyLimiter(1,
+		panurge.WithTwirpMetricsRegisterer(reg))
+	pt.Must(t, err, "failed to create limiter")
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+
+	handler := limiter.Middleware(http.HandlerFunc(
+		func(w http.ResponseWriter, _ *http.Request) {
+			entered <- struct{}{}
+			<-release
+
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+	done := make(chan int)
+
+	go func() {
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		done <- rec.Code
+	}()
+
+	<-entered
+
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected a 503 response, got %d", rec.Code)
+	}
+
+	twirpReq := httptest.NewRequest(http.MethodPost,
+		"/twirp/testservice.Testing/DoThing", strings.NewReader("{}"))
+	twirpReq.Header.Set("Content-Type", "application/json")
+
+	rec = httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, twirpReq)
+
+	if !strings.Contains(rec.Body.String(), `"resource_exhausted"`) {
+		t.Errorf("expected a resource exhausted Twirp error, got %q",
+			rec.Body.String())
+	}
+
+	close(release)
+
+	if code := <-done; code != http.StatusNoContent {
+		t.Errorf("expected the first request to succeed, got %d", code)
+	}
+
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP http_requests_rejected_total Number of HTTP requests rejected because of the concurrency limit.
+# TYPE http_requests_rejected_total counter
+http_requests_rejected_total 2
+`), "http_requests_rejected_total")
+	pt.Must(t, err, "unexpected metrics")
+}
+
+func TestConcurrencyLimiter__InvalidLimit(t *testing.T) {
+	for _, limit := range []int{0, -1} {
+		_, err := panurge.NewConcurrencyLimiter(limit,
+			panurge.WithTwirpMetricsRegisterer(prometheus.NewPedanticRegistry()))
+		if err == nil {
+			t.Errorf("expected the limit %d to be rejected", limit)
+		}
+
+		_, err = panurge.NewStandardApp(
+			panurge.Logger("error", pt.NewTestLogWriter(t)), "testservice",
+			panurge.WithAppTestServers(&panurge.TestServers{}),
+			panurge.WithAppMaxConcurrency(limit),
+			panurge.WithTwirpMetricsOptions(panurge.WithTwirpMetricsRegisterer(
+				prometheus.NewPedanticRegistry())),
+		)
+		if err == nil {
+			t.Errorf("expected the app to reject the limit %d", limit)
+		}
+	}
+}
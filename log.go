@@ -14,15 +14,97 @@ import (
 
 type AnnotationHandler struct {
 	handler slog.Handler
+	opts    annotationHandlerOptions
 }
 
-func NewAnnotationHandler(opts *slog.HandlerOptions, writer io.Writer) *AnnotationHandler {
+type annotationHandlerOptions struct {
+	keys logKeys
+	// annotationLevel is the minimum level for including the
+	// annotations, used when levelAnnotations is set.
+	annotationLevel  slog.Level
+	levelAnnotations bool
+}
+
+// logKeys are the field names used for the standard log fields.
+type logKeys struct {
+	Time        string
+	Level       string
+	Message     string
+	TraceID     string
+	User        string
+	Annotations string
+	Metadata    string
+	Segment     string
+}
+
+var standardLogKeys = logKeys{
+	Time:        "time",
+	Level:       "level",
+	Message:     "msg",
+	TraceID:     "trace_id",
+	User:        "user",
+	Annotations: "annotations",
+	Metadata:    "metadata",
+	Segment:     "segment",
+}
+
+// AnnotationHandlerOption controls the output of the annotation
+// handler.
+type AnnotationHandlerOption func(o *annotationHandlerOptions)
+
+// WithAnnotationsLevel only includes the annotations on entries at
+// or above the level, so that high volume info logs stay lean. By
+// default the annotations are included on all levels.
+func WithAnnotationsLevel(level slog.Level) AnnotationHandlerOption {
+	return func(o *annotationHandlerOptions) {
+		o.annotationLevel = level
+		o.levelAnnotations = true
+	}
+}
+
+// WithECSFormat makes the handler use Elastic Common Schema field
+// names: "@timestamp", "log.level", "message", "trace.id" and
+// "user.name". The annotations are nested under annotationsKey,
+// which defaults to "labels".
+func WithECSFormat(annotationsKey string) AnnotationHandlerOption {
+	return func(o *annotationHandlerOptions) {
+		keys := &o.keys
+
+		if annotationsKey == "" {
+			annotationsKey = "labels"
+		}
+
+		keys.Time = "@timestamp"
+		keys.Level = "log.level"
+		keys.Message = "message"
+		keys.TraceID = "trace.id"
+		keys.User = "user.name"
+		keys.Annotations = annotationsKey
+	}
+}
+
+// NewAnnotationHandler creates a JSON log handler that adds the
+// context annotations to log entries. A ReplaceAttr function in opts
+// is called after the standard attribute normalisation.
+func NewAnnotationHandler(
+	opts *slog.HandlerOptions, writer io.Writer, options ...AnnotationHandlerOption,
+) *AnnotationHandler {
+	handlerOpts := annotationHandlerOptions{
+		keys: standardLogKeys,
+	}
+
+	for _, o := range options {
+		o(&handlerOpts)
+	}
+
+	keys := handlerOpts.keys
+
 	jsonOpts := &slog.HandlerOptions{
 		Level: opts.Level,
 		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
 			if a.Key == slog.TimeKey {
 				return slog.Attr{
-					Key:   "time",
+					Key:   keys.Time,
 					Value: a.Value,
 				}
 			}
@@ -30,13 +112,13 @@ func NewAnnotationHandler(opts *slog.HandlerOptions, writer io.Writer) *Annotati
 				level := a.Value.Any().(slog.Level)
 
 				return slog.Attr{
-					Key:   "level",
+					Key:   keys.Level,
 					Value: slog.StringValue(strings.ToLower(level.String())),
 				}
 			}
 			if a.Key == slog.MessageKey {
 				return slog.Attr{
-					Key:   "msg",
+					Key:   keys.Message,
 					Value: a.Value,
 				}
 			}
@@ -45,12 +127,22 @@ func NewAnnotationHandler(opts *slog.HandlerOptions, writer io.Writer) *Annotati
 		},
 	}
 
+	if opts.ReplaceAttr != nil {
+		standard := jsonOpts.ReplaceAttr
+		custom := opts.ReplaceAttr
+
+		jsonOpts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			return custom(groups, standard(groups, a))
+		}
+	}
+
 	if writer == nil {
 		writer = os.Stdout
 	}
 
 	return &AnnotationHandler{
 		handler: slog.NewJSONHandler(writer, jsonOpts),
+		opts:    handlerOpts,
 	}
 }
 
@@ -63,21 +155,26 @@ func (h *AnnotationHandler) Handle(ctx context.Context, r slog.Record) error {
 
 	ann := GetContextAnnotations(ctx)
 	if ann != nil {
+		keys := h.opts.keys
+
 		r.Add(
-			slog.String("trace_id", ann.GetID()),
-			slog.String("user", ann.GetUser()),
-			slog.Any("annotations", ann.GetAnnotations()),
+			slog.String(keys.TraceID, ann.GetID()),
+			slog.String(keys.User, ann.GetUser()),
 		)
 
+		if !h.opts.levelAnnotations || r.Level >= h.opts.annotationLevel {
+			r.Add(slog.Any(keys.Annotations, ann.GetAnnotations()))
+		}
+
 		// Lägg till metadata endast för warn och error levels
 		if r.Level >= slog.LevelWarn {
-			r.Add(slog.Any("metadata", ann.GetMetadata()))
+			r.Add(slog.Any(keys.Metadata, ann.GetMetadata()))
 		}
 	}
 
 	// Lägg till X-Ray segment information
 	if seg := xray.GetSegment(ctx); seg != nil {
-		r.Add(slog.String("segment", seg.Name))
+		r.Add(slog.String(h.opts.keys.Segment, seg.Name))
 	}
 
 	err := h.handler.Handle(ctx, r)
@@ -91,16 +188,21 @@ func (h *AnnotationHandler) Handle(ctx context.Context, r slog.Record) error {
 func (h *AnnotationHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	return &AnnotationHandler{
 		handler: h.handler.WithAttrs(attrs),
+		opts:    h.opts,
 	}
 }
 
 func (h *AnnotationHandler) WithGroup(name string) slog.Handler {
 	return &AnnotationHandler{
 		handler: h.handler.WithGroup(name),
+		opts:    h.opts,
 	}
 }
 
-func Logger(logLevel string, writer io.Writer) *slog.Logger {
+// Logger creates a JSON logger with annotation support. If more than
+// one writer is given the log entries are written to all of them, see
+// TeeWriter.
+func Logger(logLevel string, writer io.Writer, more ...io.Writer) *slog.Logger {
 	level := slog.LevelWarn
 
 	if logLevel != "" {
@@ -118,6 +220,10 @@ func Logger(logLevel string, writer io.Writer) *slog.Logger {
 		Level: level,
 	}
 
+	if len(more) > 0 {
+		writer = NewTeeWriter(append([]io.Writer{writer}, more...)...)
+	}
+
 	handler := NewAnnotationHandler(opts, writer)
 	logger := slog.New(handler)
 
@@ -165,3 +165,109 @@ func verifyLogEntries(t *testing.T, dummy bool) {
 		})
 	}
 }
+
+func TestNewAnnotationHandler__ReplaceAttr(t *testing.T) {
+	var buf bytes.Buffer
+
+	handler := panurge.NewAnnotationHandler(&slog.HandlerOptions{
+		Level: slog.LevelInfo,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == "msg" {
+				a.Key = "message"
+			}
+
+			return a
+		},
+	}, &buf)
+
+	slog.New(handler).Info("hello")
+
+	var entry map[string]interface{}
+
+	err := json.Unmarshal(buf.Bytes(), &entry)
+	pt.Must(t, err, "failed to decode log entry")
+
+	if entry["message"] != "hello" {
+		t.Errorf("expected the message to be renamed, got %v", entry)
+	}
+
+	if entry["level"] != "info" {
+		t.Errorf("expected the standard level normalisation to apply, got %v",
+			entry["level"])
+	}
+}
+
+func TestNewAnnotationHandler__ECS(t *testing.T) {
+	var buf bytes.Buffer
+
+	handler := panurge.NewAnnotationHandler(&slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}, &buf, panurge.WithECSFormat(""))
+
+	ctx := panurge.ContextWithAnnotations(context.Background())
+
+	panurge.AddUserAnnotation(ctx, "some-individual")
+	panurge.AddAnnotation(ctx, "document", "abc123")
+
+	slog.New(handler).InfoContext(ctx, "hello")
+
+	var entry map[string]interface{}
+
+	err := json.Unmarshal(buf.Bytes(), &entry)
+	pt.Must(t, err, "failed to decode log entry")
+
+	for key, want := range map[string]interface{}{
+		"message":   "hello",
+		"log.level": "info",
+		"user.name": "some-individual",
+		"labels":    map[string]interface{}{"document": "abc123"},
+	} {
+		if diff := cmp.Diff(want, entry[key]); diff != "" {
+			t.Errorf("unexpected value for %q (-want +got):\n%s", key, diff)
+		}
+	}
+
+	for _, key := range []string{"@timestamp", "trace.id"} {
+		if _, ok := entry[key]; !ok {
+			t.Errorf("expected the entry to have a %q field, got %v", key, entry)
+		}
+	}
+
+	for _, key := range []string{"time", "level", "msg", "trace_id", "annotations"} {
+		if _, ok := entry[key]; ok {
+			t.Errorf("expected the entry to not have a %q field", key)
+		}
+	}
+}
+
+func TestNewAnnotationHandler__AnnotationsLevel(t *testing.T) {
+	var buf bytes.Buffer
+
+	handler := panurge.NewAnnotationHandler(&slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}, &buf, panurge.WithAnnotationsLevel(slog.LevelWarn))
+
+	ctx := panurge.ContextWithAnnotations(context.Background())
+
+	panurge.AddAnnotation(ctx, "document", "abc123")
+
+	logger := slog.New(handler)
+
+	logger.InfoContext(ctx, "lean")
+	logger.WarnContext(ctx, "full")
+
+	dec := json.NewDecoder(&buf)
+
+	for _, wantAnnotations := range []bool{false, true} {
+		var entry map[string]interface{}
+
+		err := dec.Decode(&entry)
+		pt.Must(t, err, "failed to decode log entry")
+
+		_, gotAnnotations := entry["annotations"]
+		if gotAnnotations != wantAnnotations {
+			t.Errorf("expected annotations to be included (%v) for %q, got %v",
+				wantAnnotations, entry["msg"], entry)
+		}
+	}
+}
@@ -1,11 +1,16 @@
 package navigaid
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+
+	"github.com/twitchtv/twirp"
 )
 
 // ImasJWKSEndpoint is a helper function that returns the v1 token
@@ -24,11 +29,148 @@ func WithAccessTokenClient(client *http.Client) AccessTokenServiceOption {
 	}
 }
 
+// WithAccessTokenRequestHeaders sets static headers that should be
+// added to every access token request.
+func WithAccessTokenRequestHeaders(headers http.Header) AccessTokenServiceOption {
+	return func(ats *AccessTokenService) {
+		ats.headers = headers.Clone()
+	}
+}
+
+// AccessTokenDecoder decodes the body of a token endpoint response.
+type AccessTokenDecoder func(body []byte) (*AccessTokenResponse, error)
+
+// WithAccessTokenDecoder sets a custom decoder for token endpoint
+// responses, for endpoints that don't use the standard response
+// format.
+func WithAccessTokenDecoder(decoder AccessTokenDecoder) AccessTokenServiceOption {
+	return func(ats *AccessTokenService) {
+		ats.decoder = decoder
+	}
+}
+
+// AccessTokenFields maps the fields of a token endpoint response to
+// the AccessTokenResponse fields. A field can be a dot separated path
+// for responses where the token is wrapped in an object,
+// f.ex. "data.token". Empty fields use the standard field names.
+type AccessTokenFields struct {
+	AccessToken string
+	TokenType   string
+	ExpiresIn   string
+}
+
+// WithAccessTokenFields decodes token endpoint responses using a
+// custom field mapping, see NewFieldsAccessTokenDecoder.
+func WithAccessTokenFields(fields AccessTokenFields) AccessTokenServiceOption {
+	return WithAccessTokenDecoder(NewFieldsAccessTokenDecoder(fields))
+}
+
+// NewFieldsAccessTokenDecoder creates a decoder that reads the token
+// response fields from the given fields. It's an error if the access
+// token field is missing, the token type and expiry are optional.
+// The expiry can be a number or a numeric string.
+func NewFieldsAccessTokenDecoder(fields AccessTokenFields) AccessTokenDecoder {
+	if fields.AccessToken == "" {
+		fields.AccessToken = "access_token"
+	}
+
+	if fields.TokenType == "" {
+		fields.TokenType = "token_type"
+	}
+
+	if fields.ExpiresIn == "" {
+		fields.ExpiresIn = "expires_in"
+	}
+
+	return func(body []byte) (*AccessTokenResponse, error) {
+		var data map[string]interface{}
+
+		err := json.Unmarshal(body, &data)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		var atr AccessTokenResponse
+
+		token, ok := lookupField(data, fields.AccessToken).(string)
+		if !ok {
+			return nil, fmt.Errorf(
+				"no access token in the field %q of the response",
+				fields.AccessToken)
+		}
+
+		atr.AccessToken = token
+
+		if tokenType, ok := lookupField(data, fields.TokenType).(string); ok {
+			atr.TokenType = tokenType
+		}
+
+		expiresIn, err := expiresInValue(lookupField(data, fields.ExpiresIn))
+		if err != nil {
+			return nil, fmt.Errorf("invalid expiry in the field %q of the response: %w",
+				fields.ExpiresIn, err)
+		}
+
+		atr.ExpiresIn = expiresIn
+
+		return &atr, nil
+	}
+}
+
+// expiresInValue reads an expiry in seconds from a number or a
+// numeric string. A missing expiry is returned as zero.
+func expiresInValue(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return int(v), nil
+	case string:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number of seconds", v)
+		}
+
+		return n, nil
+	default:
+		return 0, fmt.Errorf("unexpected value %v", v)
+	}
+}
+
+// lookupField follows a dot separated path through nested objects.
+func lookupField(data map[string]interface{}, path string) interface{} {
+	var value interface{} = data
+
+	for _, name := range strings.Split(path, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		value = obj[name]
+	}
+
+	return value
+}
+
+func decodeAccessTokenResponse(body []byte) (*AccessTokenResponse, error) {
+	var atr AccessTokenResponse
+
+	err := json.Unmarshal(body, &atr)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return &atr, nil
+}
+
 // AccessTokenService can validate access tokens and create access tokens from
 // naviga-id tokens.
 type AccessTokenService struct {
 	client        *http.Client
 	tokenEndpoint string
+	headers       http.Header
+	decoder       AccessTokenDecoder
 }
 
 // New creates a new access token service with given options.
@@ -45,6 +187,10 @@ func New(tokenEndpoint string, options ...AccessTokenServiceOption) *AccessToken
 		ats.client = http.DefaultClient
 	}
 
+	if ats.decoder == nil {
+		ats.decoder = decodeAccessTokenResponse
+	}
+
 	return &ats
 }
 
@@ -62,30 +208,41 @@ func (ats *AccessTokenService) NewAccessToken(navigaIDToken string) (*AccessToke
 		return nil, fmt.Errorf("%w", err)
 	}
 
+	addHeaders(req.Header, ats.headers)
 	req.Header.Add("Authorization", "Bearer "+navigaIDToken)
 	res, err := ats.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
 
 	defer func() {
 		_ = res.Body.Close()
 	}()
 
+	bytes, err := io.ReadAll(res.Body)
 	if err != nil {
 		return nil, fmt.Errorf("%w", err)
 	}
 
-	bytes, err := io.ReadAll(res.Body)
+	atr, err := ats.decoder(bytes)
 	if err != nil {
-		return nil, fmt.Errorf("%w", err)
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
 	}
 
-	var atr AccessTokenResponse
-	err = json.Unmarshal(bytes, &atr)
-
-	if err != nil {
-		return nil, fmt.Errorf("%w", err)
+	if atr == nil {
+		return nil, errors.New("the token response decoder returned no response")
 	}
 
-	return &atr, nil
+	return atr, nil
+}
+
+// addHeaders adds all values in the source header to the destination.
+func addHeaders(dst http.Header, src http.Header) {
+	for name, values := range src {
+		for _, v := range values {
+			dst.Add(name, v)
+		}
+	}
 }
 
 // ErrNoToken is used to communicate that no bearer token was included
@@ -96,17 +253,206 @@ func (err ErrNoToken) Error() string {
 	return "no token found"
 }
 
-func getAuthToken(header http.Header) (string, error) {
-	auth := header.Get("Authorization")
+// LegacyTokenHeader is the header that legacy clients use to send
+// the raw token in.
+const LegacyTokenHeader = "x-imid-token"
+
+type authOptions struct {
+	legacyTokenHeader bool
+	audience          string
+	tokenHeaders      []TokenHeader
+	queryTokenParam   string
+	claimsAnnotation  ClaimsAnnotationFunc
+	metrics           *AuthMetrics
+}
+
+func newAuthOptions(options []AuthOption) authOptions {
+	var opts authOptions
+
+	for _, o := range options {
+		o(&opts)
+	}
+
+	return opts
+}
+
+// AuthOption controls how tokens are extracted from requests.
+type AuthOption func(o *authOptions)
+
+// WithLegacyTokenHeader makes token extraction fall back to the raw
+// token in the x-imid-token header when there's no Authorization
+// header.
+func WithLegacyTokenHeader() AuthOption {
+	return func(o *authOptions) {
+		o.legacyTokenHeader = true
+	}
+}
+
+// TokenHeader describes a header that a token can be read from.
+type TokenHeader struct {
+	Name string
+	// Bearer is true if the token is sent with a "Bearer" prefix,
+	// as in the Authorization header.
+	Bearer bool
+}
+
+// WithTokenHeaders makes token extraction check the headers in order
+// and use the first token that's present, instead of only reading
+// the Authorization header. Include the Authorization header in the
+// list if it should still be accepted.
+func WithTokenHeaders(headers ...TokenHeader) AuthOption {
+	return func(o *authOptions) {
+		o.tokenHeaders = headers
+	}
+}
+
+// DefaultQueryTokenParam is the query parameter that's used for
+// tokens by WithQueryToken if no parameter name is given, as
+// described in RFC 6750, section 2.3.
+const DefaultQueryTokenParam = "access_token"
+
+// WithQueryToken makes HTTP token extraction fall back to reading the
+// token from a query parameter for GET requests that don't have a
+// token in the headers. This is meant for pre-signed download links,
+// which can't carry headers. An empty param means
+// DefaultQueryTokenParam.
+//
+// Tokens in URLs end up in access logs, proxy and CDN caches, and
+// browser history, so only use this for short-lived tokens, and make
+// sure that the URLs aren't logged.
+func WithQueryToken(param string) AuthOption {
+	return func(o *authOptions) {
+		if param == "" {
+			param = DefaultQueryTokenParam
+		}
+
+		o.queryTokenParam = param
+	}
+}
+
+// WithClaimsAnnotation adds a function that is called with the
+// claims of successfully authenticated requests, after the standard
+// annotation function, so that services can annotate the context
+// with the claims they care about.
+func WithClaimsAnnotation(fn ClaimsAnnotationFunc) AuthOption {
+	return func(o *authOptions) {
+		o.claimsAnnotation = fn
+	}
+}
+
+func (o authOptions) annotateClaims(ctx context.Context, claims Claims) {
+	if o.claimsAnnotation != nil {
+		o.claimsAnnotation(ctx, claims)
+	}
+}
+
+// ErrInvalidAudience is returned when a token hasn't been issued
+// for the expected audience.
+var ErrInvalidAudience = errors.New("token is not valid for this audience")
+
+// WithAudience requires the "aud" claim of the token to include the
+// given audience, so that a token issued for one service can't be
+// used with another.
+func WithAudience(audience string) AuthOption {
+	return func(o *authOptions) {
+		o.audience = audience
+	}
+}
 
-	authType, token, _ := strings.Cut(auth, " ")
-	if token == "" {
+// checkClaims verifies the claims against the authentication options.
+func (o authOptions) checkClaims(claims Claims) error {
+	if o.audience != "" && !claims.VerifyAudience(o.audience, true) {
+		return ErrInvalidAudience
+	}
+
+	return nil
+}
+
+func tokenFromHeader(header http.Header, options []AuthOption) (string, error) {
+	opts := newAuthOptions(options)
+
+	if opts.legacyTokenHeader && header.Get("Authorization") == "" {
+		if token := header.Get(LegacyTokenHeader); token != "" {
+			return token, nil
+		}
+	}
+
+	if len(opts.tokenHeaders) > 0 {
+		return tokenFromHeaders(header, opts.tokenHeaders)
+	}
+
+	return getAuthToken(header)
+}
+
+func tokenFromHeaders(header http.Header, headers []TokenHeader) (string, error) {
+	for _, th := range headers {
+		value := header.Get(th.Name)
+		if value == "" {
+			continue
+		}
+
+		if !th.Bearer {
+			return value, nil
+		}
+
+		token, ok := bearerToken(value)
+		if ok {
+			return token, nil
+		}
+	}
+
+	return "", ErrNoToken{}
+}
+
+// TokenFromRequest extracts the bearer token from the Authorization
+// header of a HTTP request, or from the query string if WithQueryToken
+// is used. Returns ErrNoToken if no bearer token was included.
+func TokenFromRequest(r *http.Request, options ...AuthOption) (string, error) {
+	token, err := tokenFromHeader(r.Header, options)
+
+	var noToken ErrNoToken
+	if !errors.As(err, &noToken) || r.Method != http.MethodGet {
+		return token, err
+	}
+
+	opts := newAuthOptions(options)
+	if opts.queryTokenParam == "" {
+		return token, err
+	}
+
+	if token := r.URL.Query().Get(opts.queryTokenParam); token != "" {
+		return token, nil
+	}
+
+	return "", ErrNoToken{}
+}
+
+// TokenFromTwirpContext extracts the bearer token from the HTTP
+// request headers that have been added to a Twirp context. Returns
+// ErrNoToken if no bearer token was included.
+func TokenFromTwirpContext(ctx context.Context, options ...AuthOption) (string, error) {
+	headers, ok := twirp.HTTPRequestHeaders(ctx)
+	if !ok {
 		return "", ErrNoToken{}
 	}
 
-	if strings.ToLower(authType) != "bearer" {
+	return tokenFromHeader(headers, options)
+}
+
+func getAuthToken(header http.Header) (string, error) {
+	token, ok := bearerToken(header.Get("Authorization"))
+	if !ok {
 		return "", ErrNoToken{}
 	}
 
 	return token, nil
 }
+
+func bearerToken(value string) (string, bool) {
+	authType, token, _ := strings.Cut(value, " ")
+	if token == "" || strings.ToLower(authType) != "bearer" {
+		return "", false
+	}
+
+	return token, true
+}
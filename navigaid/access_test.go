@@ -1,9 +1,14 @@
 package navigaid_test
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -51,6 +56,16 @@ func TestAccessTokenService(t *testing.T) {
 		t.Errorf("expected token to be valid, was invalid: %v", err)
 	}
 
+	_, parsed, err := jwks.ValidateDetailed(resp.AccessToken)
+	if err != nil {
+		t.Errorf("expected detailed validation to succeed: %v", err)
+	} else if parsed.Header["kid"] != mockServer.PrivateKeyID {
+		t.Errorf("expected the token key ID to be %q, got %v",
+			mockServer.PrivateKeyID, parsed.Header["kid"])
+	} else if !parsed.Valid {
+		t.Error("expected the returned token to be marked as valid")
+	}
+
 	var claims jwt.RegisteredClaims
 
 	_, _, err = new(jwt.Parser).ParseUnverified(resp.AccessToken, &claims)
@@ -140,3 +155,613 @@ func ExampleAccessTokenService() {
 
 	fmt.Printf("%#v\n", claims)
 }
+
+func TestAccessTokenService_Fields(t *testing.T) {
+	samples := map[string]struct {
+		Body   string
+		Fields navigaid.AccessTokenFields
+		Want   navigaid.AccessTokenResponse
+		Fail   bool
+	}{
+		"Default": {
+			Body: `{"access_token":"abc","token_type":"Bearer","expires_in":60}`,
+			Want: navigaid.AccessTokenResponse{
+				AccessToken: "abc", TokenType: "Bearer", ExpiresIn: 60,
+			},
+		},
+		"Wrapped": {
+			Body: `{"data":{"token":"abc","ttl":60}}`,
+			Fields: navigaid.AccessTokenFields{
+				AccessToken: "data.token",
+				ExpiresIn:   "data.ttl",
+			},
+			Want: navigaid.AccessTokenResponse{
+				AccessToken: "abc", ExpiresIn: 60,
+			},
+		},
+		"MissingToken": {
+			Body:   `{"data":{"ttl":60}}`,
+			Fields: navigaid.AccessTokenFields{AccessToken: "data.token"},
+			Fail:   true,
+		},
+		"StringExpiry": {
+			Body: `{"access_token":"abc","expires_in":"60"}`,
+			Want: navigaid.AccessTokenResponse{
+				AccessToken: "abc", ExpiresIn: 60,
+			},
+		},
+		"InvalidStringExpiry": {
+			Body: `{"access_token":"abc","expires_in":"1h"}`,
+			Fail: true,
+		},
+		"InvalidExpiryType": {
+			Body: `{"access_token":"abc","expires_in":true}`,
+			Fail: true,
+		},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(tc.Body))
+			}))
+
+			t.Cleanup(server.Close)
+
+			service := navigaid.New(server.URL,
+				navigaid.WithAccessTokenClient(server.Client()),
+				navigaid.WithAccessTokenFields(tc.Fields),
+			)
+
+			resp, err := service.NewAccessToken("testNavigaIDToken")
+			if tc.Fail {
+				if err == nil {
+					t.Fatal("expected the token request to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to get access token: %v", err)
+			}
+
+			if *resp != tc.Want {
+				t.Errorf("wanted %#v, got %#v", tc.Want, *resp)
+			}
+		})
+	}
+}
+
+func TestAccessTokenService_NilDecoderResult(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+
+	t.Cleanup(server.Close)
+
+	service := navigaid.New(server.URL,
+		navigaid.WithAccessTokenClient(server.Client()),
+		navigaid.WithAccessTokenDecoder(func(_ []byte) (*navigaid.AccessTokenResponse, error) {
+			return nil, nil //nolint:nilnil
+		}),
+	)
+
+	resp, err := service.NewAccessToken("testNavigaIDToken")
+	if err == nil {
+		t.Fatalf("expected a nil decoder result to be an error, got %#v", resp)
+	}
+}
+
+func TestAccessTokenService_NotBefore(t *testing.T) {
+	opts := navigaid.MockServerOptions{
+		Claims: navigaid.Claims{
+			Org: "sampleorg",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject: "75255a64-58f8-4b25-b102-af1304641096",
+			},
+		},
+		NotBefore: 60,
+	}
+
+	mockServer, err := navigaid.NewMockServer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(mockServer.Server.Close)
+
+	service := navigaid.New(
+		navigaid.AccessTokenEndpoint(mockServer.Server.URL),
+		navigaid.WithAccessTokenClient(mockServer.Client),
+	)
+
+	jwks := navigaid.NewJWKS(
+		navigaid.ImasJWKSEndpoint(mockServer.Server.URL),
+		navigaid.WithJwksClient(mockServer.Client),
+	)
+
+	resp, err := service.NewAccessToken("testNavigaIDToken")
+	if err != nil {
+		t.Fatalf("failed to exchange ID token for an access token: %v", err)
+	}
+
+	_, err = jwks.Validate(resp.AccessToken)
+	if !errors.Is(err, navigaid.ErrTokenNotValidYet) {
+		t.Fatalf("expected a not valid yet error, got: %v", err)
+	}
+}
+
+func TestAccessTokenService_Clock(t *testing.T) {
+	issued := time.Date(2020, time.April, 7, 9, 0, 0, 0, time.UTC)
+
+	opts := navigaid.MockServerOptions{
+		Claims: navigaid.Claims{
+			Org: "sampleorg",
+		},
+		TTL: 600,
+		Clock: func() time.Time {
+			return issued
+		},
+	}
+
+	mockServer, err := navigaid.NewMockServer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(mockServer.Server.Close)
+
+	service := navigaid.New(
+		navigaid.AccessTokenEndpoint(mockServer.Server.URL),
+		navigaid.WithAccessTokenClient(mockServer.Client),
+	)
+
+	resp, err := service.NewAccessToken("testNavigaIDToken")
+	if err != nil {
+		t.Fatalf("failed to exchange ID token for an access token: %v", err)
+	}
+
+	now := issued.Add(5 * time.Minute)
+
+	jwks := navigaid.NewJWKS(
+		navigaid.ImasJWKSEndpoint(mockServer.Server.URL),
+		navigaid.WithJwksClient(mockServer.Client),
+		navigaid.WithJwksClock(func() time.Time {
+			return now
+		}),
+	)
+
+	_, err = jwks.Validate(resp.AccessToken)
+	if err != nil {
+		t.Fatalf("expected token to be valid, was invalid: %v", err)
+	}
+
+	now = issued.Add(11 * time.Minute)
+
+	_, err = jwks.Validate(resp.AccessToken)
+	if !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Fatalf("expected the token to have expired, got: %v", err)
+	}
+}
+
+func TestMockServer_AdvanceTime(t *testing.T) {
+	mockServer, err := navigaid.NewMockServer(navigaid.MockServerOptions{
+		Claims: navigaid.Claims{
+			Org: "sampleorg",
+		},
+		TTL: 600,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(mockServer.Server.Close)
+
+	mockServer.SetNow(func() time.Time {
+		return time.Date(2020, time.April, 7, 9, 0, 0, 0, time.UTC)
+	})
+
+	service := navigaid.New(
+		navigaid.AccessTokenEndpoint(mockServer.Server.URL),
+		navigaid.WithAccessTokenClient(mockServer.Client),
+	)
+
+	jwks := navigaid.NewJWKS(
+		navigaid.ImasJWKSEndpoint(mockServer.Server.URL),
+		navigaid.WithJwksClient(mockServer.Client),
+		navigaid.WithJwksClock(mockServer.Now),
+	)
+
+	resp, err := service.NewAccessToken("testNavigaIDToken")
+	if err != nil {
+		t.Fatalf("failed to exchange ID token for an access token: %v", err)
+	}
+
+	_, err = jwks.Validate(resp.AccessToken)
+	if err != nil {
+		t.Fatalf("expected token to be valid, was invalid: %v", err)
+	}
+
+	mockServer.AdvanceTime(11 * time.Minute)
+
+	_, err = jwks.Validate(resp.AccessToken)
+	if !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Fatalf("expected the token to have expired, got: %v", err)
+	}
+}
+
+func TestMockServer_CurrentKeyID(t *testing.T) {
+	mockServer, err := navigaid.NewMockServer(navigaid.MockServerOptions{
+		Claims: navigaid.Claims{
+			Org: "sampleorg",
+		},
+		TTL: 600,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(mockServer.Server.Close)
+
+	service := navigaid.New(
+		navigaid.AccessTokenEndpoint(mockServer.Server.URL),
+		navigaid.WithAccessTokenClient(mockServer.Client),
+	)
+
+	resp, err := service.NewAccessToken("testNavigaIDToken")
+	if err != nil {
+		t.Fatalf("failed to exchange ID token for an access token: %v", err)
+	}
+
+	token, _, err := jwt.NewParser().ParseUnverified(resp.AccessToken, &navigaid.Claims{})
+	if err != nil {
+		t.Fatalf("failed to parse access token: %v", err)
+	}
+
+	if token.Header["kid"] != mockServer.CurrentKeyID() {
+		t.Errorf("expected the token to be signed with the key %q, got %v",
+			mockServer.CurrentKeyID(), token.Header["kid"])
+	}
+
+	res, err := mockServer.Client.Get(mockServer.Server.URL + "/debug/keyid")
+	if err != nil {
+		t.Fatalf("failed to request the key id: %v", err)
+	}
+
+	defer res.Body.Close()
+
+	var keyID navigaid.MockKeyIDResponse
+
+	err = json.NewDecoder(res.Body).Decode(&keyID)
+	if err != nil {
+		t.Fatalf("failed to decode the key id response: %v", err)
+	}
+
+	if keyID.KeyID != mockServer.CurrentKeyID() {
+		t.Errorf("expected the key id %q, got %q",
+			mockServer.CurrentKeyID(), keyID.KeyID)
+	}
+}
+
+func TestJWKS_Refresh(t *testing.T) {
+	mockServer, err := navigaid.NewMockServer(navigaid.MockServerOptions{
+		Claims: navigaid.Claims{
+			Org: "sampleorg",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(mockServer.Server.Close)
+
+	jwks := navigaid.NewJWKS(
+		navigaid.ImasJWKSEndpoint(mockServer.Server.URL),
+		navigaid.WithJwksClient(mockServer.Client),
+	)
+
+	err = jwks.Refresh(context.Background())
+	if err != nil {
+		t.Fatalf("failed to refresh the JWKS: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+
+	jwks.RefreshHandler().ServeHTTP(rec,
+		httptest.NewRequest(http.MethodPost, "/jwks/refresh", nil))
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected the refresh endpoint to respond with %d, got %d",
+			http.StatusNoContent, rec.Code)
+	}
+
+	mockServer.Server.Close()
+
+	err = jwks.Refresh(context.Background())
+	if err == nil {
+		t.Error("expected refresh to fail when the JWKS can't be fetched")
+	}
+}
+
+func TestJWKS_FetchTimeout(t *testing.T) {
+	release := make(chan struct{})
+
+	slow := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+
+	t.Cleanup(slow.Close)
+	t.Cleanup(func() { close(release) })
+
+	jwks := navigaid.NewJWKS(
+		navigaid.ImasJWKSEndpoint(slow.URL),
+		navigaid.WithJwksClient(&http.Client{}),
+		navigaid.WithJwksFetchTimeout(50*time.Millisecond),
+	)
+
+	start := time.Now()
+
+	err := jwks.Refresh(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the fetch to time out, got: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("expected the fetch to be bounded by the timeout, took %v", elapsed)
+	}
+}
+
+func TestRequestHeaders(t *testing.T) {
+	mockService, err := navigaid.NewMockService(navigaid.MockServerOptions{
+		Claims: navigaid.Claims{
+			Org: "sampleorg",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]string)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen[r.URL.Path] = r.Header.Get("X-Api-Key")
+
+		mockService.ServeHTTP(w, r)
+	}))
+
+	t.Cleanup(server.Close)
+
+	headers := make(http.Header)
+	headers.Set("X-Api-Key", "secret")
+
+	service := navigaid.New(
+		navigaid.AccessTokenEndpoint(server.URL),
+		navigaid.WithAccessTokenClient(server.Client()),
+		navigaid.WithAccessTokenRequestHeaders(headers),
+	)
+
+	jwks := navigaid.NewJWKS(
+		navigaid.ImasJWKSEndpoint(server.URL),
+		navigaid.WithJwksClient(server.Client()),
+		navigaid.WithJwksRequestHeaders(headers),
+	)
+
+	resp, err := service.NewAccessToken("testNavigaIDToken")
+	if err != nil {
+		t.Fatalf("failed to exchange ID token for an access token: %v", err)
+	}
+
+	_, err = jwks.Validate(resp.AccessToken)
+	if err != nil {
+		t.Fatalf("expected token to be valid, was invalid: %v", err)
+	}
+
+	for _, path := range []string{"/v1/token", "/v1/jwks"} {
+		if seen[path] != "secret" {
+			t.Errorf("expected the API key header to be sent to %s", path)
+		}
+	}
+}
+
+func BenchmarkJWKS_Validate(b *testing.B) {
+	mockServer, err := navigaid.NewMockServer(navigaid.MockServerOptions{
+		Claims: navigaid.Claims{
+			Org: "sampleorg",
+		},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Cleanup(mockServer.Server.Close)
+
+	service := navigaid.New(
+		navigaid.AccessTokenEndpoint(mockServer.Server.URL),
+		navigaid.WithAccessTokenClient(mockServer.Client),
+	)
+
+	resp, err := service.NewAccessToken("testNavigaIDToken")
+	if err != nil {
+		b.Fatalf("failed to exchange ID token for an access token: %v", err)
+	}
+
+	samples := map[string][]navigaid.JWKSOption{
+		"Uncached": nil,
+		"Cached":   {navigaid.WithJwksValidationCache(1000)},
+	}
+
+	for name, opts := range samples {
+		jwks := navigaid.NewJWKS(
+			navigaid.ImasJWKSEndpoint(mockServer.Server.URL),
+			append(opts, navigaid.WithJwksClient(mockServer.Client))...,
+		)
+
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := jwks.Validate(resp.AccessToken)
+				if err != nil {
+					b.Fatalf("expected token to be valid, was invalid: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestJWKSHandler(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(navigaid.JWKSHandler(navigaid.PublicKey{
+		KeyID: "service-key",
+		Key:   &key.PublicKey,
+	}))
+
+	t.Cleanup(server.Close)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS512, navigaid.Claims{
+		Org:       "sampleorg",
+		TokenType: navigaid.TokenTypeAccessToken,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	})
+
+	token.Header["kid"] = "service-key"
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := navigaid.NewJWKS(server.URL, navigaid.WithJwksClient(server.Client()))
+
+	claims, err := jwks.Validate(signed)
+	if err != nil {
+		t.Fatalf("expected token to be valid, was invalid: %v", err)
+	}
+
+	if claims.Org != "sampleorg" {
+		t.Errorf("expected the org to be %q, got %q", "sampleorg", claims.Org)
+	}
+}
+
+func TestSigner(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := navigaid.NewSigner(key, "service-key")
+	server := httptest.NewServer(navigaid.JWKSHandler(signer.PublicKey()))
+
+	t.Cleanup(server.Close)
+
+	signed, err := signer.Sign(navigaid.Claims{
+		Org:       "sampleorg",
+		TokenType: navigaid.TokenTypeAccessToken,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	jwks := navigaid.NewJWKS(server.URL, navigaid.WithJwksClient(server.Client()))
+
+	claims, err := jwks.Validate(signed)
+	if err != nil {
+		t.Fatalf("expected token to be valid, was invalid: %v", err)
+	}
+
+	if claims.Org != "sampleorg" {
+		t.Errorf("expected the org to be %q, got %q", "sampleorg", claims.Org)
+	}
+}
+
+func TestNewJWKSChecked(t *testing.T) {
+	samples := map[string]struct {
+		Endpoint string
+		Fail     bool
+	}{
+		"HTTPS":     {Endpoint: "https://imas.example.com/v1/jwks"},
+		"HTTP":      {Endpoint: "http://localhost:8080/v1/jwks"},
+		"Empty":     {Endpoint: "", Fail: true},
+		"NoScheme":  {Endpoint: "imas.example.com/v1/jwks", Fail: true},
+		"FTP":       {Endpoint: "ftp://imas.example.com/v1/jwks", Fail: true},
+		"Malformed": {Endpoint: "https://imas example.com/%zz", Fail: true},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			_, err := navigaid.NewJWKSChecked(tc.Endpoint)
+
+			var epErr navigaid.ErrInvalidJWKSEndpoint
+
+			switch {
+			case tc.Fail && !errors.As(err, &epErr):
+				t.Fatalf("expected an invalid endpoint error, got: %v", err)
+			case !tc.Fail && err != nil:
+				t.Fatalf("expected the endpoint to be accepted: %v", err)
+			}
+		})
+	}
+}
+
+func TestJWKS_AllowedAlgorithms(t *testing.T) {
+	mockServer, err := navigaid.NewMockServer(navigaid.MockServerOptions{
+		Claims: navigaid.Claims{
+			Org: "sampleorg",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(mockServer.Server.Close)
+
+	service := navigaid.New(
+		navigaid.AccessTokenEndpoint(mockServer.Server.URL),
+		navigaid.WithAccessTokenClient(mockServer.Client),
+	)
+
+	resp, err := service.NewAccessToken("testNavigaIDToken")
+	if err != nil {
+		t.Fatalf("failed to get an access token: %v", err)
+	}
+
+	pinned := navigaid.NewJWKS(
+		navigaid.ImasJWKSEndpoint(mockServer.Server.URL),
+		navigaid.WithJwksClient(mockServer.Client),
+		navigaid.WithAllowedAlgorithms(jwt.SigningMethodRS512.Alg()),
+	)
+
+	_, err = pinned.Validate(resp.AccessToken)
+	if err != nil {
+		t.Fatalf("expected a RS512 token to be accepted: %v", err)
+	}
+
+	restricted := navigaid.NewJWKS(
+		navigaid.ImasJWKSEndpoint(mockServer.Server.URL),
+		navigaid.WithJwksClient(mockServer.Client),
+		navigaid.WithAllowedAlgorithms(jwt.SigningMethodRS256.Alg()),
+	)
+
+	_, err = restricted.Validate(resp.AccessToken)
+	if err == nil {
+		t.Fatal("expected a RS512 token to be rejected when only RS256 is allowed")
+	}
+}
@@ -3,7 +3,6 @@ package navigaid
 import (
 	"crypto/rand"
 	"crypto/rsa"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,38 +10,137 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 )
 
+// MockServer is a NavigaID mock server for tests. It must be created
+// using NewMockServer, the zero value has neither a server nor a
+// clock and can't be used.
 type MockServer struct {
 	Server       *httptest.Server
 	PrivateKey   *rsa.PrivateKey
 	PrivateKeyID string
 	Client       *http.Client
+
+	clock *mockClock
+}
+
+// SetNow replaces the clock used by the mock server, and resets any
+// time advancement. Passing nil restores time.Now.
+func (ms *MockServer) SetNow(now Clock) {
+	ms.clock.Set(now)
+}
+
+// AdvanceTime moves the clock of the mock server forward.
+func (ms *MockServer) AdvanceTime(d time.Duration) {
+	ms.clock.Advance(d)
+}
+
+// Now returns the current time of the mock server. It can be used
+// with WithJwksClock to make validation use the same time as the
+// mock server.
+func (ms *MockServer) Now() time.Time {
+	return ms.clock.Now()
+}
+
+// CurrentKeyID returns the ID of the key that the mock server signs
+// tokens with, so that tests can check the "kid" header of tokens.
+func (ms *MockServer) CurrentKeyID() string {
+	return ms.PrivateKeyID
+}
+
+// MockKeyIDResponse is the response of the /debug/keyid endpoint of
+// the mock service.
+type MockKeyIDResponse struct {
+	KeyID string `json:"kid"`
+}
+
+// mockClock is a clock that can be replaced and advanced while the
+// mock server is running.
+type mockClock struct {
+	m      sync.Mutex
+	now    Clock
+	offset time.Duration
+}
+
+func newMockClock(now Clock) *mockClock {
+	var c mockClock
+
+	c.Set(now)
+
+	return &c
+}
+
+func (c *mockClock) Set(now Clock) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if now == nil {
+		now = time.Now
+	}
+
+	c.now = now
+	c.offset = 0
+}
+
+func (c *mockClock) Advance(d time.Duration) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.offset += d
+}
+
+func (c *mockClock) Now() time.Time {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	return c.now().Add(c.offset)
 }
 
 type MockServerOptions struct {
-	Claims          Claims
-	TTL             int    `json:"ttl"`
+	Claims Claims
+	TTL    int `json:"ttl"`
+	// NotBefore is an offset in seconds from the time of issue
+	// that is used for the "nbf" claim. A positive value issues
+	// tokens that aren't valid yet.
+	NotBefore       int    `json:"not_before"`         //nolint:tagliatelle
 	PrivatePemKey   string `json:"private_pem_key"`    //nolint:tagliatelle
 	PrivatePemKeyID string `json:"private_pem_key_id"` //nolint:tagliatelle
+	// ExtraClaims are added to the claims of the issued tokens,
+	// and can be any value that can be marshalled as JSON. They
+	// take precedence over the standard claims.
+	ExtraClaims map[string]interface{} `json:"extra_claims"` //nolint:tagliatelle
+	// Clock is used to get the issue time of tokens, defaults to
+	// time.Now.
+	Clock Clock `json:"-"`
 }
 
+// MockService is a http.Handler that serves the NavigaID mock
+// endpoints. It must be created using NewMockService.
 type MockService struct {
 	Mux        *http.ServeMux
 	PrivateKey *rsa.PrivateKey
 	keyID      string
+	clock      *mockClock
+}
+
+// CurrentKeyID returns the ID of the key that the mock service signs
+// tokens with.
+func (ms MockService) CurrentKeyID() string {
+	return ms.keyID
 }
 
 func (ms MockService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	ms.Mux.ServeHTTP(rw, r)
 }
 
-// This mock server mocks two endpoints, one for creating new access tokens
-// and another one for providing keys.
+// This mock server mocks the endpoints for creating new access tokens,
+// providing keys, and fetching userinfo. Close the server using
+// MockServer.Server.Close when done.
 func NewMockServer(opts MockServerOptions) (*MockServer, error) {
 	mockService, err := NewMockService(opts)
 	if err != nil {
@@ -56,13 +154,14 @@ func NewMockServer(opts MockServerOptions) (*MockServer, error) {
 		Client:       srv.Client(),
 		PrivateKey:   mockService.PrivateKey,
 		PrivateKeyID: mockService.keyID,
+		clock:        mockService.clock,
 	}
 
 	return &mockServer, nil
 }
 
-// This mock service mocks two endpoints, one for creating new access
-// tokens and another one for providing keys.
+// This mock service mocks the endpoints for creating new access
+// tokens, providing keys, and fetching userinfo.
 func NewMockService(opts MockServerOptions) (MockService, error) {
 	var mockService MockService
 
@@ -84,6 +183,8 @@ func NewMockService(opts MockServerOptions) (MockService, error) {
 		return mockService, err
 	}
 
+	clock := newMockClock(opts.Clock)
+
 	mux.HandleFunc("/v1/token", func(w http.ResponseWriter, r *http.Request) {
 		tokenTTL := 600 * time.Second
 
@@ -101,16 +202,24 @@ func NewMockService(opts MockServerOptions) (MockService, error) {
 			tokenTTL = time.Duration(opts.TTL) * time.Second
 		}
 
+		notBefore := time.Duration(opts.NotBefore) * time.Second
+		now := clock.Now()
+
 		jwtClaims := jwt.MapClaims{
 			"sub":         opts.Claims.Subject,
 			"org":         opts.Claims.Org,
 			"ntt":         "access_token",
-			"exp":         time.Now().Add(tokenTTL).Unix(),
-			"iat":         time.Now().Unix(),
+			"exp":         now.Add(tokenTTL).Unix(),
+			"iat":         now.Unix(),
+			"nbf":         now.Add(notBefore).Unix(),
 			"jti":         "da20dda4-c8ce-4dac-98dc-435f2f0128f1",
 			"permissions": opts.Claims.Permissions,
 		}
 
+		for k, v := range opts.ExtraClaims {
+			jwtClaims[k] = v
+		}
+
 		if hasHeaderSpecifiedClaims(r) {
 			err = updateClaimsWithHeaderSpecifiedClaims(r, jwtClaims)
 			if err != nil {
@@ -118,11 +227,7 @@ func NewMockService(opts MockServerOptions) (MockService, error) {
 			}
 		}
 
-		token := jwt.NewWithClaims(jwt.SigningMethodRS512, jwtClaims)
-
-		token.Header["kid"] = privateKeyID
-
-		signed, err := token.SignedString(privateKey)
+		signed, err := signToken(jwtClaims, privateKey, privateKeyID)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			_, _ = w.Write([]byte(fmt.Sprintf("failed to sign access token: %v", err.Error())))
@@ -147,31 +252,62 @@ func NewMockService(opts MockServerOptions) (MockService, error) {
 		}
 	})
 
-	mux.HandleFunc("/v1/jwks", func(w http.ResponseWriter, _ *http.Request) {
-		n := base64.RawURLEncoding.EncodeToString(privateKey.PublicKey.N.Bytes())
-
-		keys := fmt.Sprintf(`{
-			"keys": [
-				{
-					"kty": "RSA",
-					"use": "sig",
-					"alg": "RS512",
-					"kid": "%s",
-					"n": "%s",
-					"e": "AQAB"
-				}],
-				"maxTokenTTL": 604800
-		}`, privateKeyID, n)
-
-		_, err = io.WriteString(w, keys)
+	mux.Handle("/v1/jwks", jwksHandler([]PublicKey{{
+		KeyID: privateKeyID,
+		Key:   &privateKey.PublicKey,
+	}}, 604800))
+
+	mux.HandleFunc("/debug/keyid", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Add("Content-Type", "application/json; charset=utf-8")
+
+		err := json.NewEncoder(w).Encode(MockKeyIDResponse{
+			KeyID: privateKeyID,
+		})
+		if err != nil {
+			_, _ = w.Write([]byte(fmt.Sprintf("failed to write out key id response: %v", err.Error())))
+		}
+	})
+
+	mux.HandleFunc("/v1/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		accessToken, err := TokenFromRequest(r)
+		if err != nil {
+			http.Error(w, "no access token", http.StatusUnauthorized)
+
+			return
+		}
+
+		var claims Claims
+
+		// The claims are validated separately so that the expiry
+		// is checked against the mock clock.
+		_, err = jwt.ParseWithClaims(accessToken, &claims, func(_ *jwt.Token) (interface{}, error) {
+			return &privateKey.PublicKey, nil
+		},
+			jwt.WithValidMethods([]string{jwt.SigningMethodRS512.Alg()}),
+			jwt.WithoutClaimsValidation(),
+		)
+		if err == nil {
+			err = claims.validAt(clock.Now())
+		}
+
 		if err != nil {
-			_, _ = w.Write([]byte(fmt.Sprintf("failed to write out jwks response: %v", err.Error())))
+			http.Error(w, "invalid access token", http.StatusUnauthorized)
+
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json; charset=utf-8")
+
+		err = json.NewEncoder(w).Encode(opts.Claims.Userinfo)
+		if err != nil {
+			_, _ = w.Write([]byte(fmt.Sprintf("failed to write out userinfo response: %v", err.Error())))
 		}
 	})
 
 	mockService.Mux = mux
 	mockService.PrivateKey = privateKey
 	mockService.keyID = privateKeyID
+	mockService.clock = clock
 
 	return mockService, nil
 }
@@ -210,14 +346,15 @@ func parsePrivatePemKeyFromOpts(opts MockServerOptions) (*rsa.PrivateKey, string
 func generatePrivateKey() (*rsa.PrivateKey, string, error) {
 	generatedPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
-		return nil, "", fmt.Errorf("%w", err)
+		return nil, "", fmt.Errorf("failed to generate private key: %w", err)
 	}
 
-	generatedPrivateKeyUUID, err := uuid.NewUUID()
-
+	// Use a random (v4) UUID for the key ID, time based UUIDs would
+	// leak information about the host.
+	keyID, err := uuid.NewRandom()
 	if err != nil {
-		return nil, "", fmt.Errorf("%w", err)
+		return nil, "", fmt.Errorf("failed to generate key ID: %w", err)
 	}
 
-	return generatedPrivateKey, generatedPrivateKeyUUID.String(), err
+	return generatedPrivateKey, keyID.String(), nil
 }
@@ -179,3 +179,54 @@ func TestNavigaIdMockServiceWithCustomPrivateKey(t *testing.T) {
 		}
 	})
 }
+
+func TestMockServer_ExtraClaims(t *testing.T) {
+	mockServer, err := navigaid.NewMockServer(navigaid.MockServerOptions{
+		Claims: navigaid.Claims{Org: "sampleorg"},
+		ExtraClaims: map[string]interface{}{
+			"tenant": map[string]interface{}{
+				"id":      "t-1",
+				"regions": []string{"eu", "us"},
+			},
+			"beta": true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(mockServer.Server.Close)
+
+	service := navigaid.New(
+		navigaid.AccessTokenEndpoint(mockServer.Server.URL),
+		navigaid.WithAccessTokenClient(mockServer.Client),
+	)
+
+	resp, err := service.NewAccessToken("testNavigaIDToken")
+	if err != nil {
+		t.Fatalf("failed to get an access token: %v", err)
+	}
+
+	var claims struct {
+		jwt.RegisteredClaims
+		Org    string `json:"org"`
+		Beta   bool   `json:"beta"`
+		Tenant struct {
+			ID      string   `json:"id"`
+			Regions []string `json:"regions"`
+		} `json:"tenant"`
+	}
+
+	_, _, err = new(jwt.Parser).ParseUnverified(resp.AccessToken, &claims)
+	if err != nil {
+		t.Fatalf("failed to parse token: %v", err)
+	}
+
+	if claims.Org != "sampleorg" || !claims.Beta {
+		t.Errorf("expected the standard and extra claims, got %+v", claims)
+	}
+
+	if claims.Tenant.ID != "t-1" || len(claims.Tenant.Regions) != 2 {
+		t.Errorf("expected the nested extra claims, got %+v", claims.Tenant)
+	}
+}
@@ -0,0 +1,100 @@
+package navigaid
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/twitchtv/twirp"
+)
+
+const (
+	// ActAsOrgHeader is the header that internal tooling uses to
+	// act on behalf of another organisation.
+	ActAsOrgHeader = "X-Act-As-Org"
+	// ImpersonateOrgPermission is the organisation permission that
+	// is required to use the ActAsOrgHeader.
+	ImpersonateOrgPermission = "impersonate-org"
+)
+
+// ActAsAnnotationFunc is used to record the real organisation and
+// the organisation that the caller acts as, for auditing.
+type ActAsAnnotationFunc func(ctx context.Context, realOrg string, actAsOrg string)
+
+// ActAsOrgMiddleware creates a HTTP middleware that lets callers
+// with the "impersonate-org" permission act as the organisation in
+// the X-Act-As-Org header. The organisation of the claims in the
+// request context is replaced, so that it's used for authorization
+// and metrics. Callers without the permission get a 403 response.
+// It must be used after HTTPMiddleware. The annotate function is
+// optional.
+func ActAsOrgMiddleware(annotate ActAsAnnotationFunc) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			actAs := r.Header.Get(ActAsOrgHeader)
+			if actAs == "" {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			auth, err := GetAuth(r.Context())
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+
+				return
+			}
+
+			if !auth.Claims.HasPermissionsInOrganisation(ImpersonateOrgPermission) {
+				w.WriteHeader(http.StatusForbidden)
+
+				return
+			}
+
+			ctx := actAsOrg(r.Context(), auth, actAs, annotate)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// NewTwirpActAsOrgHook creates a twirp server hook that lets callers
+// with the "impersonate-org" permission act as the organisation in
+// the X-Act-As-Org header, see ActAsOrgMiddleware. The header must
+// be added to the Twirp context, and the hook must be chained after
+// the authentication hook. The annotate function is optional.
+func NewTwirpActAsOrgHook(annotate ActAsAnnotationFunc) *twirp.ServerHooks {
+	var hooks twirp.ServerHooks
+
+	hooks.RequestRouted = func(ctx context.Context) (context.Context, error) {
+		headers, ok := twirp.HTTPRequestHeaders(ctx)
+		if !ok || headers.Get(ActAsOrgHeader) == "" {
+			return ctx, nil
+		}
+
+		auth, err := GetAuth(ctx)
+		if err != nil {
+			return ctx, twirp.NewError(twirp.Unauthenticated, "Unauthenticated")
+		}
+
+		if !auth.Claims.HasPermissionsInOrganisation(ImpersonateOrgPermission) {
+			return ctx, twirp.NewError(twirp.PermissionDenied,
+				"not allowed to act as another organisation")
+		}
+
+		return actAsOrg(ctx, auth, headers.Get(ActAsOrgHeader), annotate), nil
+	}
+
+	return &hooks
+}
+
+func actAsOrg(
+	ctx context.Context, auth AuthInfo, org string, annotate ActAsAnnotationFunc,
+) context.Context {
+	if annotate != nil {
+		annotate(ctx, auth.Claims.Org, org)
+	}
+
+	auth.Claims.Org = org
+
+	return SetAuth(ctx, auth, nil)
+}
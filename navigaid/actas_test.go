@@ -0,0 +1,141 @@
+package navigaid_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/navigacontentlab/panurge/v2/navigaid"
+	"github.com/twitchtv/twirp"
+)
+
+func TestActAsOrgMiddleware(t *testing.T) {
+	samples := map[string]struct {
+		ActAs       string
+		Permissions []string
+		NoAuth      bool
+		WantStatus  int
+		WantOrg     string
+	}{
+		"NoHeader":     {WantStatus: http.StatusOK, WantOrg: "hms-govt"},
+		"NoPermission": {ActAs: "kgb", WantStatus: http.StatusForbidden},
+		"Unauthenticated": {
+			ActAs: "kgb", NoAuth: true, WantStatus: http.StatusUnauthorized,
+		},
+		"Impersonate": {
+			ActAs:       "kgb",
+			Permissions: []string{navigaid.ImpersonateOrgPermission},
+			WantStatus:  http.StatusOK,
+			WantOrg:     "kgb",
+		},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			var (
+				gotOrg  string
+				audited [2]string
+			)
+
+			handler := navigaid.ActAsOrgMiddleware(
+				func(_ context.Context, realOrg, actAsOrg string) {
+					audited = [2]string{realOrg, actAsOrg}
+				},
+			)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				auth, err := navigaid.GetAuth(r.Context())
+				if err != nil {
+					t.Fatalf("expected authentication information: %v", err)
+				}
+
+				gotOrg = auth.Claims.Org
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			if tc.ActAs != "" {
+				req.Header.Set(navigaid.ActAsOrgHeader, tc.ActAs)
+			}
+
+			if !tc.NoAuth {
+				req = req.WithContext(navigaid.SetAuth(req.Context(), navigaid.AuthInfo{
+					Claims: navigaid.Claims{
+						Org: "hms-govt",
+						Permissions: navigaid.PermissionsClaim{
+							Org: tc.Permissions,
+						},
+					},
+				}, nil))
+			}
+
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.WantStatus {
+				t.Fatalf("wanted status %d, got %d", tc.WantStatus, rec.Code)
+			}
+
+			if gotOrg != tc.WantOrg {
+				t.Errorf("wanted the org %q, got %q", tc.WantOrg, gotOrg)
+			}
+
+			if tc.WantOrg == tc.ActAs && audited != [2]string{"hms-govt", tc.ActAs} {
+				t.Errorf("expected the real and acted as orgs to be annotated, got %v",
+					audited)
+			}
+		})
+	}
+}
+
+func TestActAsOrg__NoAnnotateFunc(t *testing.T) {
+	auth := navigaid.AuthInfo{
+		Claims: navigaid.Claims{
+			Org: "hms-govt",
+			Permissions: navigaid.PermissionsClaim{
+				Org: []string{navigaid.ImpersonateOrgPermission},
+			},
+		},
+	}
+
+	var gotOrg string
+
+	handler := navigaid.ActAsOrgMiddleware(nil)(http.HandlerFunc(
+		func(_ http.ResponseWriter, r *http.Request) {
+			a, err := navigaid.GetAuth(r.Context())
+			if err == nil {
+				gotOrg = a.Claims.Org
+			}
+		}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(navigaid.ActAsOrgHeader, "kgb")
+	req = req.WithContext(navigaid.SetAuth(req.Context(), auth, nil))
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if gotOrg != "kgb" {
+		t.Errorf("wanted the middleware to act as %q, got %q", "kgb", gotOrg)
+	}
+
+	header := make(http.Header)
+	header.Set(navigaid.ActAsOrgHeader, "kgb")
+
+	ctx, err := twirp.WithHTTPRequestHeaders(
+		navigaid.SetAuth(context.Background(), auth, nil), header)
+	if err != nil {
+		t.Fatalf("failed to add headers to context: %v", err)
+	}
+
+	ctx, err = navigaid.NewTwirpActAsOrgHook(nil).RequestRouted(ctx)
+	if err != nil {
+		t.Fatalf("expected the hook to allow the request: %v", err)
+	}
+
+	a, err := navigaid.GetAuth(ctx)
+	if err != nil || a.Claims.Org != "kgb" {
+		t.Errorf("wanted the hook to act as %q, got %q (%v)", "kgb", a.Claims.Org, err)
+	}
+}
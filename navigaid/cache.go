@@ -0,0 +1,97 @@
+package navigaid
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+type cacheKey [sha256.Size]byte
+
+type cachedToken struct {
+	key     cacheKey
+	claims  Claims
+	token   *jwt.Token
+	expires time.Time
+}
+
+// tokenCache is a size bounded LRU cache of validated tokens. Tokens
+// are keyed on a hash of the token type and the raw token.
+type tokenCache struct {
+	m       sync.Mutex
+	size    int
+	order   *list.List
+	entries map[cacheKey]*list.Element
+}
+
+func newTokenCache(size int) *tokenCache {
+	return &tokenCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[cacheKey]*list.Element, size),
+	}
+}
+
+func tokenCacheKey(token string, tokenType string) cacheKey {
+	return sha256.Sum256([]byte(tokenType + "\x00" + token))
+}
+
+// Get returns a cached token if it hasn't expired.
+func (c *tokenCache) Get(key cacheKey, now time.Time) (*cachedToken, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry, _ := el.Value.(*cachedToken)
+
+	if !now.Before(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+
+	return entry, true
+}
+
+// Add caches the token, evicting the least recently used tokens if
+// the cache is full.
+func (c *tokenCache) Add(entry *cachedToken) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if el, ok := c.entries[entry.key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+
+		return
+	}
+
+	c.entries[entry.key] = c.order.PushFront(entry)
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		evicted, _ := oldest.Value.(*cachedToken)
+
+		c.order.Remove(oldest)
+		delete(c.entries, evicted.key)
+	}
+}
+
+// Purge removes all cached tokens.
+func (c *tokenCache) Purge() {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.order.Init()
+	c.entries = make(map[cacheKey]*list.Element, c.size)
+}
@@ -0,0 +1,52 @@
+package navigaid
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cache := newTokenCache(2)
+
+	add := func(token string, ttl time.Duration) cacheKey {
+		key := tokenCacheKey(token, TokenTypeAccessToken)
+
+		cache.Add(&cachedToken{key: key, expires: now.Add(ttl)})
+
+		return key
+	}
+
+	first := add("first", time.Minute)
+	second := add("second", time.Minute)
+
+	// Touch the first token so that the second one is the least
+	// recently used.
+	if _, ok := cache.Get(first, now); !ok {
+		t.Fatal("expected the first token to be cached")
+	}
+
+	third := add("third", time.Minute)
+
+	if _, ok := cache.Get(second, now); ok {
+		t.Error("expected the least recently used token to be evicted")
+	}
+
+	if _, ok := cache.Get(third, now); !ok {
+		t.Error("expected the third token to be cached")
+	}
+
+	if _, ok := cache.Get(first, now.Add(time.Minute)); ok {
+		t.Error("expected expired tokens to not be returned")
+	}
+
+	if _, ok := cache.Get(tokenCacheKey("third", "other_type"), now); ok {
+		t.Error("expected the token type to be part of the cache key")
+	}
+
+	cache.Purge()
+
+	if _, ok := cache.Get(third, now); ok {
+		t.Error("expected the cache to be empty after a purge")
+	}
+}
@@ -2,6 +2,7 @@ package navigaid
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
@@ -38,6 +39,14 @@ func (c Claims) HasPermissionsInUnit(unit string, permissions ...string) bool {
 	return true
 }
 
+// HasUnitOnlyPermissions checks if the holder has been granted a set
+// of permissions directly in a unit. Permissions in the organisation
+// aren't inherited, use this for sensitive actions that must be
+// granted explicitly per unit.
+func (c Claims) HasUnitOnlyPermissions(unit string, permissions ...string) bool {
+	return len(missingPermissions(c.Permissions.UnitOnlyPermissions(unit), permissions)) == 0
+}
+
 // HasPermissionsInOrganisation checks if the holder has a set of permissions
 // in the organisation.
 func (c Claims) HasPermissionsInOrganisation(permissions ...string) bool {
@@ -52,6 +61,31 @@ func (c Claims) HasPermissionsInOrganisation(permissions ...string) bool {
 	return true
 }
 
+// MissingPermissionsInUnit returns the subset of the required
+// permissions that the holder lacks in a unit, taking permissions
+// inherited from the organisation into account.
+func (c Claims) MissingPermissionsInUnit(unit string, required ...string) []string {
+	return missingPermissions(c.Permissions.PermissionsInUnit(unit), required)
+}
+
+// MissingPermissionsInOrganisation returns the subset of the required
+// permissions that the holder lacks in the organisation.
+func (c Claims) MissingPermissionsInOrganisation(required ...string) []string {
+	return missingPermissions(c.Permissions.PermissionsInOrganisation(), required)
+}
+
+func missingPermissions(perms map[string]bool, required []string) []string {
+	var missing []string
+
+	for _, p := range required {
+		if !perms[p] {
+			missing = append(missing, p)
+		}
+	}
+
+	return missing
+}
+
 // Userinfo contains name and similar data.
 type Userinfo struct {
 	GivenName  string `json:"given_name"`  //nolint:tagliatelle
@@ -93,6 +127,68 @@ func (p PermissionsClaim) PermissionsInUnit(unit string) map[string]bool {
 	return m
 }
 
+// UnitOnlyPermissions returns the permissions that have been granted
+// directly in a unit, without the permissions inherited from the
+// organisation.
+func (p PermissionsClaim) UnitOnlyPermissions(unit string) map[string]bool {
+	m := make(map[string]bool)
+	for _, up := range p.Units[unit] {
+		m[up] = true
+	}
+
+	return m
+}
+
+// Merge returns the union of the permissions in p and other. The
+// permissions keep the order they were first seen in, and duplicates
+// are removed. Neither p nor other is modified.
+func (p PermissionsClaim) Merge(other PermissionsClaim) PermissionsClaim {
+	merged := PermissionsClaim{
+		Org: unionPermissions(p.Org, other.Org),
+	}
+
+	if len(p.Units) == 0 && len(other.Units) == 0 {
+		return merged
+	}
+
+	merged.Units = make(map[string][]string, len(p.Units)+len(other.Units))
+
+	for unit, perms := range p.Units {
+		merged.Units[unit] = unionPermissions(perms, other.Units[unit])
+	}
+
+	for unit, perms := range other.Units {
+		if _, done := merged.Units[unit]; !done {
+			merged.Units[unit] = unionPermissions(perms, nil)
+		}
+	}
+
+	return merged
+}
+
+func unionPermissions(a, b []string) []string {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(a)+len(b))
+	union := make([]string, 0, len(a)+len(b))
+
+	for _, list := range [][]string{a, b} {
+		for _, perm := range list {
+			if seen[perm] {
+				continue
+			}
+
+			seen[perm] = true
+
+			union = append(union, perm)
+		}
+	}
+
+	return union
+}
+
 func (c Claims) Valid() error {
 	err := c.RegisteredClaims.Valid()
 	if err != nil {
@@ -101,3 +197,20 @@ func (c Claims) Valid() error {
 
 	return nil
 }
+
+// validAt validates the time based claims against the provided time.
+func (c Claims) validAt(now time.Time) error {
+	if !c.VerifyExpiresAt(now, false) {
+		return jwt.ErrTokenExpired
+	}
+
+	if !c.VerifyIssuedAt(now, false) {
+		return jwt.ErrTokenUsedBeforeIssued
+	}
+
+	if !c.VerifyNotBefore(now, false) {
+		return jwt.ErrTokenNotValidYet
+	}
+
+	return nil
+}
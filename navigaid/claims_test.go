@@ -0,0 +1,71 @@
+package navigaid_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/navigacontentlab/panurge/v2/navigaid"
+)
+
+func TestClaims_HasUnitOnlyPermissions(t *testing.T) {
+	claims := navigaid.Claims{
+		Permissions: navigaid.PermissionsClaim{
+			Org: []string{"read-files"},
+			Units: map[string][]string{
+				"mi6": {"permission-to-kill"},
+			},
+		},
+	}
+
+	if !claims.HasPermissionsInUnit("mi6", "read-files", "permission-to-kill") {
+		t.Error("expected organisation permissions to be inherited by default")
+	}
+
+	if !claims.HasUnitOnlyPermissions("mi6", "permission-to-kill") {
+		t.Error("expected the directly granted unit permission to be found")
+	}
+
+	if claims.HasUnitOnlyPermissions("mi6", "read-files") {
+		t.Error("expected organisation permissions to not be inherited")
+	}
+
+	perms := claims.Permissions.UnitOnlyPermissions("mi6")
+	if len(perms) != 1 || !perms["permission-to-kill"] {
+		t.Errorf("unexpected unit only permissions: %v", perms)
+	}
+}
+
+func TestPermissionsClaim_Merge(t *testing.T) {
+	base := navigaid.PermissionsClaim{
+		Org: []string{"read-files", "write-files"},
+		Units: map[string][]string{
+			"mi6": {"permission-to-kill"},
+		},
+	}
+
+	delegated := navigaid.PermissionsClaim{
+		Org: []string{"write-files", "shred-files"},
+		Units: map[string][]string{
+			"mi6": {"permission-to-kill", "drive-aston-martin"},
+			"q":   {"build-gadgets"},
+		},
+	}
+
+	got := base.Merge(delegated)
+
+	want := navigaid.PermissionsClaim{
+		Org: []string{"read-files", "write-files", "shred-files"},
+		Units: map[string][]string{
+			"mi6": {"permission-to-kill", "drive-aston-martin"},
+			"q":   {"build-gadgets"},
+		},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %#v, got %#v", want, got)
+	}
+
+	if len(base.Org) != 2 || len(base.Units["mi6"]) != 1 {
+		t.Errorf("expected the original permissions to be left untouched, got %#v", base)
+	}
+}
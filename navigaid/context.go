@@ -2,9 +2,15 @@ package navigaid
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 )
 
+// contextKey is private to this package, and thereby to this major
+// version of panurge. Authentication set by the middlewares of
+// another major version isn't visible to GetAuth, use CopyAuth to
+// bridge between versions during a migration.
 type contextKey int
 
 // authInfoKey is used to retrieve the access token.
@@ -20,11 +26,19 @@ type ai struct {
 	Err error
 }
 
-// GetAutch retrieves authentication information from the context.
+// ErrNoAuthContext is returned by GetAuth when no authentication
+// information has been added to the context, which usually means
+// that the authentication middleware or hook hasn't been applied.
+var ErrNoAuthContext = errors.New("no authentication information in context")
+
+// GetAutch retrieves authentication information from the
+// context. Returns ErrNoAuthContext if the context hasn't been
+// populated by the authentication middleware, otherwise the
+// authentication error, if any.
 func GetAuth(ctx context.Context) (AuthInfo, error) {
-	auth, ok := ctx.Value(authInfoKey).(ai)
+	auth, ok := authFromContext(ctx)
 	if !ok {
-		return AuthInfo{}, errors.New("no authentication information in context")
+		return AuthInfo{}, ErrNoAuthContext
 	}
 
 	if auth.Err != nil {
@@ -34,6 +48,30 @@ func GetAuth(ctx context.Context) (AuthInfo, error) {
 	return auth.Ac, nil
 }
 
+// ClaimsFromContext returns the claims if the request was
+// successfully authenticated. It's meant for code paths that don't
+// gate access, like logging and metrics, and don't care about why
+// authentication information is missing.
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	auth, ok := authFromContext(ctx)
+	if !ok || auth.Err != nil {
+		return Claims{}, false
+	}
+
+	return auth.Ac.Claims, true
+}
+
+func authFromContext(ctx context.Context) (ai, bool) {
+	switch auth := ctx.Value(authInfoKey).(type) {
+	case ai:
+		return auth, true
+	case *refreshingAuth:
+		return auth.get(), true
+	default:
+		return ai{}, false
+	}
+}
+
 // SetClaims adds specified Claims to the context.
 func SetAuth(ctx context.Context, auth AuthInfo, err error) context.Context {
 	return context.WithValue(ctx, authInfoKey, ai{
@@ -41,3 +79,39 @@ func SetAuth(ctx context.Context, auth AuthInfo, err error) context.Context {
 		Err: err,
 	})
 }
+
+// AuthSource reads authentication information from a context. The
+// returned value must have the same structure as AuthInfo, like the
+// AuthInfo of another major version of panurge.
+type AuthSource func(ctx context.Context) (any, error)
+
+// CopyAuth copies authentication information from another source,
+// f.ex. the GetAuth function of another major version of panurge, to
+// the context. This lets a request that was authenticated by the
+// middleware of one version be read by handlers using another:
+//
+//	ctx = navigaid.CopyAuth(ctx, func(ctx context.Context) (any, error) {
+//		return v1navigaid.GetAuth(ctx)
+//	})
+func CopyAuth(ctx context.Context, source AuthSource) context.Context {
+	other, err := source(ctx)
+	if err != nil {
+		return SetAuth(ctx, AuthInfo{}, err)
+	}
+
+	data, err := json.Marshal(other)
+	if err != nil {
+		return SetAuth(ctx, AuthInfo{}, fmt.Errorf(
+			"failed to marshal authentication information: %w", err))
+	}
+
+	var auth AuthInfo
+
+	err = json.Unmarshal(data, &auth)
+	if err != nil {
+		return SetAuth(ctx, AuthInfo{}, fmt.Errorf(
+			"failed to unmarshal authentication information: %w", err))
+	}
+
+	return SetAuth(ctx, auth, nil)
+}
@@ -0,0 +1,96 @@
+package navigaid_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/navigacontentlab/panurge/v2/navigaid"
+)
+
+type otherVersionClaims struct {
+	jwt.RegisteredClaims
+
+	Org string `json:"org"`
+}
+
+type otherVersionAuthInfo struct {
+	AccessToken string
+	Claims      otherVersionClaims
+}
+
+func TestCopyAuth(t *testing.T) {
+	ctx := navigaid.CopyAuth(context.Background(), func(_ context.Context) (any, error) {
+		return otherVersionAuthInfo{
+			AccessToken: "abc123",
+			Claims: otherVersionClaims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject: "core://user/1",
+				},
+				Org: "sampleorg",
+			},
+		}, nil
+	})
+
+	auth, err := navigaid.GetAuth(ctx)
+	if err != nil {
+		t.Fatalf("expected authentication information in context: %v", err)
+	}
+
+	if auth.AccessToken != "abc123" || auth.Claims.Org != "sampleorg" ||
+		auth.Claims.Subject != "core://user/1" {
+		t.Fatalf("authentication information wasn't copied correctly: %#v", auth)
+	}
+
+	authErr := errors.New("no token")
+
+	ctx = navigaid.CopyAuth(context.Background(), func(_ context.Context) (any, error) {
+		return nil, authErr
+	})
+
+	_, err = navigaid.GetAuth(ctx)
+	if !errors.Is(err, authErr) {
+		t.Fatalf("expected the source error to be copied, got: %v", err)
+	}
+}
+
+func TestClaimsFromContext(t *testing.T) {
+	if _, ok := navigaid.ClaimsFromContext(context.Background()); ok {
+		t.Error("expected no claims without authentication information")
+	}
+
+	failed := navigaid.SetAuth(context.Background(),
+		navigaid.AuthInfo{}, errors.New("invalid token"))
+
+	if _, ok := navigaid.ClaimsFromContext(failed); ok {
+		t.Error("expected no claims when authentication failed")
+	}
+
+	ctx := navigaid.SetAuth(context.Background(), navigaid.AuthInfo{
+		Claims: navigaid.Claims{Org: "mi5"},
+	}, nil)
+
+	claims, ok := navigaid.ClaimsFromContext(ctx)
+	if !ok || claims.Org != "mi5" {
+		t.Errorf("expected the claims to be returned, got %v, %v", claims, ok)
+	}
+}
+
+func TestGetAuth_Errors(t *testing.T) {
+	_, err := navigaid.GetAuth(context.Background())
+	if !errors.Is(err, navigaid.ErrNoAuthContext) {
+		t.Errorf("expected ErrNoAuthContext without authentication information, got %v", err)
+	}
+
+	ctx := navigaid.SetAuth(context.Background(), navigaid.AuthInfo{}, navigaid.ErrNoToken{})
+
+	_, err = navigaid.GetAuth(ctx)
+	if errors.Is(err, navigaid.ErrNoAuthContext) {
+		t.Error("expected an authentication failure to not be reported as a missing context")
+	}
+
+	if !errors.As(err, &navigaid.ErrNoToken{}) {
+		t.Errorf("expected the stored authentication error, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package navigaid
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// TimeoutHeader is the header used to propagate the remaining time
+// until a deadline to downstream services. The value uses the gRPC
+// timeout format: at most 8 digits followed by a unit, one of "H"
+// (hours), "M" (minutes), "S" (seconds), "m" (milliseconds), "u"
+// (microseconds), or "n" (nanoseconds).
+const TimeoutHeader = "Grpc-Timeout"
+
+const maxTimeoutValue = 99999999
+
+// FormatTimeout formats a duration for use in the TimeoutHeader,
+// using the most precise unit that fits.
+func FormatTimeout(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+
+	units := []struct {
+		unit string
+		size time.Duration
+	}{
+		{"n", time.Nanosecond},
+		{"u", time.Microsecond},
+		{"m", time.Millisecond},
+		{"S", time.Second},
+		{"M", time.Minute},
+	}
+
+	for _, u := range units {
+		// Round up so that we never shorten the deadline to zero.
+		value := (d + u.size - 1) / u.size
+		if value <= maxTimeoutValue {
+			return strconv.FormatInt(int64(value), 10) + u.unit
+		}
+	}
+
+	value := (d + time.Hour - 1) / time.Hour
+	if value > maxTimeoutValue {
+		value = maxTimeoutValue
+	}
+
+	return strconv.FormatInt(int64(value), 10) + "H"
+}
+
+// ParseTimeout parses a TimeoutHeader value.
+func ParseTimeout(value string) (time.Duration, error) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, fmt.Errorf("invalid timeout %q", value)
+	}
+
+	var unit time.Duration
+
+	switch value[len(value)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, fmt.Errorf("invalid timeout unit in %q", value)
+	}
+
+	n, err := strconv.ParseUint(value[:len(value)-1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout value in %q: %w", value, err)
+	}
+
+	if n > uint64(math.MaxInt64/int64(unit)) {
+		return 0, fmt.Errorf("timeout %q is out of range", value)
+	}
+
+	return time.Duration(n) * unit, nil
+}
+
+// TimeoutFromContext returns a TimeoutHeader value for the deadline
+// of the context.
+func TimeoutFromContext(ctx context.Context) (string, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return "", false
+	}
+
+	return FormatTimeout(time.Until(deadline)), true
+}
+
+// ContextWithTimeoutHeader applies the timeout from a TimeoutHeader
+// value to the context. An empty value returns the context as-is
+// with a no-op cancel function.
+func ContextWithTimeoutHeader(
+	ctx context.Context, value string,
+) (context.Context, context.CancelFunc, error) {
+	if value == "" {
+		return ctx, func() {}, nil
+	}
+
+	timeout, err := ParseTimeout(value)
+	if err != nil {
+		return ctx, func() {}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+
+	return ctx, cancel, nil
+}
@@ -0,0 +1,48 @@
+package navigaid_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/navigacontentlab/panurge/v2/navigaid"
+)
+
+func TestTimeoutHeader(t *testing.T) {
+	samples := map[string]struct {
+		Duration time.Duration
+		Value    string
+	}{
+		"Nanoseconds":  {Duration: 500 * time.Nanosecond, Value: "500n"},
+		"Milliseconds": {Duration: 250 * time.Second, Value: "250000m"},
+		"Seconds":      {Duration: 30 * time.Hour, Value: "108000S"},
+		"Zero":         {Duration: 0, Value: "0n"},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			value := navigaid.FormatTimeout(tc.Duration)
+			if value != tc.Value {
+				t.Fatalf("expected %v to be formatted as %q, got %q",
+					tc.Duration, tc.Value, value)
+			}
+
+			parsed, err := navigaid.ParseTimeout(value)
+			if err != nil {
+				t.Fatalf("failed to parse %q: %v", value, err)
+			}
+
+			if parsed != tc.Duration {
+				t.Fatalf("expected %q to be parsed as %v, got %v",
+					value, tc.Duration, parsed)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"", "5", "5x", "123456789S", "-5S", "99999999H"} {
+		if _, err := navigaid.ParseTimeout(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
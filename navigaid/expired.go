@@ -0,0 +1,47 @@
+package navigaid
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ErrTokenExpired is returned by validators when the token has
+// expired. Use errors.Is() to check for it.
+var ErrTokenExpired = jwt.ErrTokenExpired
+
+// TokenExpiredCode is the error code used in the response body when
+// ExpiredTokenMiddleware rejects a request.
+const TokenExpiredCode = "token_expired"
+
+// ExpiredTokenMiddleware rejects requests with expired tokens with a
+// 401 response that has the "token_expired" error code and a
+// WWW-Authenticate header, so that clients know to refresh the token
+// instead of making the user log in again. Requests without a token
+// or with otherwise invalid tokens are passed on to the next handler.
+// It must be used after HTTPMiddleware.
+func ExpiredTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := GetAuth(r.Context())
+		if !errors.Is(err, ErrTokenExpired) {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		w.Header().Set("WWW-Authenticate",
+			`Bearer error="invalid_token", error_description="the access token expired"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+
+		_ = json.NewEncoder(w).Encode(struct {
+			Code string `json:"code"`
+			Msg  string `json:"msg"`
+		}{
+			Code: TokenExpiredCode,
+			Msg:  "the access token has expired",
+		})
+	})
+}
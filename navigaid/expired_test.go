@@ -0,0 +1,85 @@
+package navigaid_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/navigacontentlab/panurge/v2/navigaid"
+)
+
+func TestExpiredTokenMiddleware(t *testing.T) {
+	mockServer, err := navigaid.NewMockServer(navigaid.MockServerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(mockServer.Server.Close)
+
+	jwks := navigaid.NewJWKS(
+		navigaid.ImasJWKSEndpoint(mockServer.Server.URL),
+		navigaid.WithJwksClient(mockServer.Client),
+	)
+
+	handler := navigaid.HTTPMiddleware(jwks, navigaid.ExpiredTokenMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})), func(_ context.Context, _, _ string) {})
+
+	apiServer := httptest.NewServer(handler)
+	t.Cleanup(apiServer.Close)
+
+	tokenWithExpiry := func(exp time.Time) string {
+		return getAccessToken(t, mockServer.PrivateKey, mockServer.PrivateKeyID,
+			navigaid.Claims{
+				Org: "sampleorg",
+				RegisteredClaims: jwt.RegisteredClaims{
+					ExpiresAt: jwt.NewNumericDate(exp),
+				},
+			})
+	}
+
+	res := getWithToken(t, apiServer.Client(), apiServer.URL,
+		tokenWithExpiry(time.Now().Add(-time.Minute)))
+
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected a 401 response for an expired token, got %d", res.StatusCode)
+	}
+
+	if !strings.Contains(res.Header.Get("WWW-Authenticate"), `error="invalid_token"`) {
+		t.Errorf("expected an invalid token WWW-Authenticate header, got %q",
+			res.Header.Get("WWW-Authenticate"))
+	}
+
+	var body struct {
+		Code string `json:"code"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+
+	if body.Code != navigaid.TokenExpiredCode {
+		t.Errorf("expected the error code %q, got %q", navigaid.TokenExpiredCode, body.Code)
+	}
+
+	res = getWithToken(t, apiServer.Client(), apiServer.URL,
+		tokenWithExpiry(time.Now().Add(time.Minute)))
+
+	if res.StatusCode != http.StatusNoContent {
+		t.Errorf("expected a valid token to pass, got %d", res.StatusCode)
+	}
+
+	res = getWithToken(t, apiServer.Client(), apiServer.URL, "not-a-token")
+
+	if res.StatusCode != http.StatusNoContent {
+		t.Errorf("expected an invalid token to be passed to the handler, got %d",
+			res.StatusCode)
+	}
+}
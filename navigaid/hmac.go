@@ -0,0 +1,80 @@
+package navigaid
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// HMACValidator can validate HS256 tokens signed with a shared
+// secret. It's meant for internal service-to-service authentication.
+type HMACValidator struct {
+	secret []byte
+	clock  Clock
+}
+
+// HMACOption is used to configure a HMACValidator.
+type HMACOption func(v *HMACValidator)
+
+// WithHMACClock sets the clock that should be used for token expiry
+// checks.
+func WithHMACClock(clock Clock) HMACOption {
+	return func(v *HMACValidator) {
+		v.clock = clock
+	}
+}
+
+// NewHMACValidator creates a validator for HS256 tokens signed with
+// the provided secret.
+func NewHMACValidator(secret []byte, options ...HMACOption) *HMACValidator {
+	v := HMACValidator{
+		secret: secret,
+		clock:  time.Now,
+	}
+
+	for _, opt := range options {
+		opt(&v)
+	}
+
+	return &v
+}
+
+// Validate tries to validate a given access token.
+func (v *HMACValidator) Validate(accessToken string) (Claims, error) {
+	return v.ValidateToken(accessToken, TokenTypeAccessToken)
+}
+
+// ValidateToken tries to validate a given JWT token and verifies that
+// it has the expected token type.
+func (v *HMACValidator) ValidateToken(token string, tokenType string) (Claims, error) {
+	var claims Claims
+
+	parser := jwt.NewParser(
+		jwt.WithoutClaimsValidation(),
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+	)
+
+	t, err := parser.ParseWithClaims(token, &claims, func(_ *jwt.Token) (interface{}, error) {
+		if claims.TokenType != tokenType {
+			return nil, fmt.Errorf("unexpected token type %q", claims.TokenType)
+		}
+
+		return v.secret, nil
+	})
+	if err != nil {
+		return Claims{}, fmt.Errorf("failed to parse token: %w", err)
+	}
+
+	if !t.Valid {
+		return Claims{}, errors.New("token is invalid")
+	}
+
+	err = claims.validAt(v.clock())
+	if err != nil {
+		return Claims{}, fmt.Errorf("token is invalid: %w", err)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,113 @@
+package navigaid_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/navigacontentlab/panurge/v2/navigaid"
+)
+
+func TestHMACValidator(t *testing.T) {
+	secret := []byte("not-so-secret")
+
+	validator := navigaid.NewHMACValidator(secret)
+
+	sign := func(t *testing.T, method jwt.SigningMethod, key interface{}, tokenType string) string {
+		t.Helper()
+
+		token := jwt.NewWithClaims(method, navigaid.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "core://application/service",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
+			},
+			Org:       "sampleorg",
+			TokenType: tokenType,
+		})
+
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+
+		return signed
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		claims, err := validator.Validate(sign(t,
+			jwt.SigningMethodHS256, secret, navigaid.TokenTypeAccessToken))
+		if err != nil {
+			t.Fatalf("expected token to be valid, was invalid: %v", err)
+		}
+
+		if claims.Org != "sampleorg" {
+			t.Fatalf("wanted the org %q, got %q", "sampleorg", claims.Org)
+		}
+	})
+
+	t.Run("WrongSecret", func(t *testing.T) {
+		_, err := validator.Validate(sign(t,
+			jwt.SigningMethodHS256, []byte("wrong"), navigaid.TokenTypeAccessToken))
+		if err == nil {
+			t.Fatal("expected token to be invalid but was valid")
+		}
+	})
+
+	t.Run("WrongAlgorithm", func(t *testing.T) {
+		_, err := validator.Validate(sign(t,
+			jwt.SigningMethodHS512, secret, navigaid.TokenTypeAccessToken))
+		if err == nil {
+			t.Fatal("expected token to be invalid but was valid")
+		}
+	})
+
+	t.Run("WrongTokenType", func(t *testing.T) {
+		_, err := validator.Validate(sign(t,
+			jwt.SigningMethodHS256, secret, navigaid.TokenTypeIDToken))
+		if err == nil {
+			t.Fatal("expected token to be invalid but was valid")
+		}
+	})
+
+	t.Run("Clock", func(t *testing.T) {
+		token := sign(t, jwt.SigningMethodHS256, secret, navigaid.TokenTypeAccessToken)
+
+		later := navigaid.NewHMACValidator(secret, navigaid.WithHMACClock(func() time.Time {
+			return time.Now().Add(10 * time.Minute)
+		}))
+
+		_, err := later.Validate(token)
+		if !errors.Is(err, navigaid.ErrTokenExpired) {
+			t.Fatalf("expected the token to have expired, got: %v", err)
+		}
+	})
+
+	t.Run("Middleware", func(t *testing.T) {
+		var org string
+
+		handler := navigaid.HTTPMiddleware(validator,
+			http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				auth, err := navigaid.GetAuth(r.Context())
+				if err == nil {
+					org = auth.Claims.Org
+				}
+			}),
+			func(_ context.Context, _, _ string) {},
+		)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+sign(t,
+			jwt.SigningMethodHS256, secret, navigaid.TokenTypeAccessToken))
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if org != "sampleorg" {
+			t.Fatalf("expected the request to be authenticated for %q, got %q",
+				"sampleorg", org)
+		}
+	})
+}
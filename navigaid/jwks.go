@@ -2,6 +2,7 @@ package navigaid
 
 import (
 	"bytes"
+	"context"
 	"crypto/rsa"
 	"encoding/base64"
 	"encoding/binary"
@@ -10,6 +11,8 @@ import (
 	"fmt"
 	"math/big"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,7 +20,20 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
-const defaultJwksTTL = 10 * time.Minute
+const (
+	defaultJwksTTL          = 10 * time.Minute
+	defaultJwksMinCacheTTL  = 1 * time.Minute
+	defaultJwksMaxCacheTTL  = 24 * time.Hour
+	defaultJwksFetchTimeout = 5 * time.Second
+)
+
+// ErrTokenNotValidYet is returned (wrapped) by the validation
+// functions when a token is used before its "nbf" time.
+var ErrTokenNotValidYet = jwt.ErrTokenNotValidYet
+
+// ErrUnknownKeyID is returned (wrapped) by the validation functions
+// when the token was signed with a key that isn't in the JWKS.
+var ErrUnknownKeyID = errors.New("unknown key id")
 
 // ImasJWKSEndpoint is a helper function that returns the v1 JWKS
 // endpoint URL given an URL that points to the IMAS service.
@@ -25,11 +41,22 @@ func ImasJWKSEndpoint(serviceURL string) string {
 	return fmt.Sprintf("%s/v1/jwks", strings.TrimSuffix(serviceURL, "/"))
 }
 
+// Clock returns the current time. It's used to make time dependent
+// behaviour testable.
+type Clock func() time.Time
+
 // JWKS can validate access tokens using published JWKS.
 type JWKS struct {
 	client       *http.Client
 	jwksEndpoint string
 	ttl          time.Duration
+	minCacheTTL  time.Duration
+	maxCacheTTL  time.Duration
+	fetchTimeout time.Duration
+	algorithms   []string
+	headers      http.Header
+	clock        Clock
+	cache        *tokenCache
 
 	m              sync.Mutex
 	jwksStaleAfter time.Time
@@ -39,13 +66,44 @@ type JWKS struct {
 // JWKSOption is a function that controls the JWKS configuration.
 type JWKSOption func(j *JWKS)
 
-// WithJwksTTL can be used to change the default JWKS refresh rate.
+// WithJwksTTL can be used to change the default JWKS refresh rate,
+// it's used when the JWKS response doesn't have any cache headers.
 func WithJwksTTL(ttl time.Duration) JWKSOption {
 	return func(j *JWKS) {
 		j.ttl = ttl
 	}
 }
 
+// WithJwksCacheBounds sets the bounds for the refresh rate that's
+// derived from the Cache-Control or Expires headers of the JWKS
+// response. The default bounds are one minute and 24 hours.
+func WithJwksCacheBounds(minTTL, maxTTL time.Duration) JWKSOption {
+	return func(j *JWKS) {
+		j.minCacheTTL = minTTL
+		j.maxCacheTTL = maxTTL
+	}
+}
+
+// WithJwksFetchTimeout bounds the time that a JWKS fetch can take,
+// regardless of the timeout of the HTTP client. Defaults to five
+// seconds, non-positive durations are ignored.
+func WithJwksFetchTimeout(d time.Duration) JWKSOption {
+	return func(j *JWKS) {
+		if d > 0 {
+			j.fetchTimeout = d
+		}
+	}
+}
+
+// WithAllowedAlgorithms restricts the signing algorithms that are
+// accepted, f.ex. "RS512". Tokens signed with other algorithms are
+// rejected even if the JWKS has a matching key.
+func WithAllowedAlgorithms(algs ...string) JWKSOption {
+	return func(j *JWKS) {
+		j.algorithms = algs
+	}
+}
+
 // WithJwksClient sets the HTTP client that should be used for
 // requests.
 func WithJwksClient(client *http.Client) JWKSOption {
@@ -54,11 +112,45 @@ func WithJwksClient(client *http.Client) JWKSOption {
 	}
 }
 
+// WithJwksRequestHeaders sets static headers that should be added to
+// every JWKS request, f.ex. an API key or user agent required by a
+// gateway.
+func WithJwksRequestHeaders(headers http.Header) JWKSOption {
+	return func(j *JWKS) {
+		j.headers = headers.Clone()
+	}
+}
+
+// WithJwksValidationCache enables caching of validated tokens so
+// that a token that is presented repeatedly doesn't have to be
+// verified every time. Tokens are cached until they expire, and at
+// most size tokens are kept. Tokens without an expiry time aren't
+// cached.
+func WithJwksValidationCache(size int) JWKSOption {
+	return func(j *JWKS) {
+		if size > 0 {
+			j.cache = newTokenCache(size)
+		}
+	}
+}
+
+// WithJwksClock sets the clock that should be used for token expiry
+// checks and JWKS staleness.
+func WithJwksClock(clock Clock) JWKSOption {
+	return func(j *JWKS) {
+		j.clock = clock
+	}
+}
+
 // New creates a new access token validator.
 func NewJWKS(jwksEndpoint string, options ...JWKSOption) *JWKS {
 	j := JWKS{
 		jwksEndpoint: jwksEndpoint,
 		ttl:          defaultJwksTTL,
+		minCacheTTL:  defaultJwksMinCacheTTL,
+		maxCacheTTL:  defaultJwksMaxCacheTTL,
+		fetchTimeout: defaultJwksFetchTimeout,
+		clock:        time.Now,
 	}
 
 	for _, o := range options {
@@ -72,15 +164,70 @@ func NewJWKS(jwksEndpoint string, options ...JWKSOption) *JWKS {
 	return &j
 }
 
-func (j *JWKS) fetchJWKS() (*jwksResponse, error) {
-	req, err := http.NewRequest(http.MethodGet, j.jwksEndpoint, nil)
+// ErrInvalidJWKSEndpoint is returned by NewJWKSChecked when the JWKS
+// endpoint isn't a valid HTTP(S) URL.
+type ErrInvalidJWKSEndpoint struct {
+	Endpoint string
+	Reason   string
+}
+
+func (err ErrInvalidJWKSEndpoint) Error() string {
+	return fmt.Sprintf("invalid JWKS endpoint %q: %s", err.Endpoint, err.Reason)
+}
+
+// NewJWKSChecked works like NewJWKS but verifies that the endpoint is
+// a valid HTTP(S) URL, so that misconfiguration is detected at
+// startup instead of on the first validation. Returns
+// ErrInvalidJWKSEndpoint if the endpoint is invalid.
+func NewJWKSChecked(jwksEndpoint string, options ...JWKSOption) (*JWKS, error) {
+	err := checkJWKSEndpoint(jwksEndpoint)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create jwks fetch request: %w", err)
+		return nil, err
+	}
+
+	return NewJWKS(jwksEndpoint, options...), nil
+}
+
+func checkJWKSEndpoint(endpoint string) error {
+	if endpoint == "" {
+		return ErrInvalidJWKSEndpoint{Endpoint: endpoint, Reason: "empty URL"}
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ErrInvalidJWKSEndpoint{Endpoint: endpoint, Reason: err.Error()}
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrInvalidJWKSEndpoint{
+			Endpoint: endpoint,
+			Reason:   "the scheme must be http or https",
+		}
 	}
 
+	if u.Host == "" {
+		return ErrInvalidJWKSEndpoint{Endpoint: endpoint, Reason: "missing host"}
+	}
+
+	return nil
+}
+
+// fetchJWKS fetches the JWKS and returns it together with the time
+// it should be cached for.
+func (j *JWKS) fetchJWKS(ctx context.Context) (*jwksResponse, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, j.fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.jwksEndpoint, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create jwks fetch request: %w", err)
+	}
+
+	addHeaders(req.Header, j.headers)
+
 	res, err := j.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("%w", err)
+		return nil, 0, fmt.Errorf("%w", err)
 	}
 
 	defer func() {
@@ -88,7 +235,7 @@ func (j *JWKS) fetchJWKS() (*jwksResponse, error) {
 	}()
 
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server responded with: %s", res.Status)
+		return nil, 0, fmt.Errorf("server responded with: %s", res.Status)
 	}
 
 	dec := json.NewDecoder(res.Body)
@@ -97,10 +244,101 @@ func (j *JWKS) fetchJWKS() (*jwksResponse, error) {
 
 	err = dec.Decode(&jwks)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode JWKS response: %w", err)
+		return nil, 0, fmt.Errorf("failed to decode JWKS response: %w", err)
+	}
+
+	return &jwks, j.cacheTTL(res.Header), nil
+}
+
+// cacheTTL derives the cache TTL from the Cache-Control max-age or
+// the Expires header, bounded by the configured cache bounds. Falls
+// back to the configured TTL if there are no cache headers.
+func (j *JWKS) cacheTTL(header http.Header) time.Duration {
+	ttl, ok := maxAge(header.Get("Cache-Control"))
+
+	if !ok {
+		expires, err := http.ParseTime(header.Get("Expires"))
+		if err != nil {
+			return j.ttl
+		}
+
+		ttl = expires.Sub(j.clock())
+	}
+
+	if ttl < j.minCacheTTL {
+		return j.minCacheTTL
+	}
+
+	if j.maxCacheTTL > 0 && ttl > j.maxCacheTTL {
+		return j.maxCacheTTL
+	}
+
+	return ttl
+}
+
+// maxAge returns the max-age from a Cache-Control header value. A
+// no-store or no-cache directive results in a zero max-age.
+func maxAge(cacheControl string) (time.Duration, bool) {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+
+		switch strings.ToLower(name) {
+		case "no-store", "no-cache":
+			return 0, true
+		case "max-age":
+			seconds, err := strconv.Atoi(strings.Trim(value, `"`))
+			if err != nil || seconds < 0 {
+				continue
+			}
+
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+
+	return 0, false
+}
+
+// Refresh fetches the JWKS and replaces the cached keys immediately,
+// f.ex. after a known key rotation.
+func (j *JWKS) Refresh(ctx context.Context) error {
+	j.m.Lock()
+	defer j.m.Unlock()
+
+	res, ttl, err := j.fetchJWKS(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch jwks: %w", err)
 	}
 
-	return &jwks, nil
+	j.jwks = res
+	j.jwksStaleAfter = j.clock().Add(ttl)
+
+	if j.cache != nil {
+		j.cache.Purge()
+	}
+
+	return nil
+}
+
+// RefreshHandler returns a HTTP handler that refreshes the JWKS on
+// POST requests. It's meant to be mounted on an internal mux.
+func (j *JWKS) RefreshHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		err := j.Refresh(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
 }
 
 func (j *JWKS) getKey(kid string) (*jwksKey, error) {
@@ -108,15 +346,15 @@ func (j *JWKS) getKey(kid string) (*jwksKey, error) {
 	defer j.m.Unlock()
 
 	// ensure up-to-date version of our jwks
-	if time.Now().After(j.jwksStaleAfter) {
-		res, err := j.fetchJWKS()
+	if j.clock().After(j.jwksStaleAfter) {
+		res, ttl, err := j.fetchJWKS(context.Background())
 		if err != nil {
 			return nil, fmt.Errorf(
 				"failed to fetch jwks: %w", err)
 		}
 
 		j.jwks = res
-		j.jwksStaleAfter = time.Now().Add(j.ttl)
+		j.jwksStaleAfter = j.clock().Add(ttl)
 	}
 
 	// find the correct key
@@ -126,7 +364,7 @@ func (j *JWKS) getKey(kid string) (*jwksKey, error) {
 		}
 	}
 
-	return nil, errors.New("key not found")
+	return nil, ErrUnknownKeyID
 }
 
 // Validate tries to validate a given access token by first parsing it and then
@@ -135,13 +373,65 @@ func (j *JWKS) Validate(accessToken string) (Claims, error) {
 	return j.ValidateToken(accessToken, TokenTypeAccessToken)
 }
 
+// ValidateDetailed works like Validate, but also returns the parsed
+// and validated token so that callers can inspect f.ex. the token
+// header.
+func (j *JWKS) ValidateDetailed(accessToken string) (Claims, *jwt.Token, error) {
+	return j.validateToken(accessToken, TokenTypeAccessToken)
+}
+
 // ValidateToken tries to validate a given JWT token by first parsing
 // it and then looking up the "kid" to match with a jwk (which are
 // cached locally).
 func (j *JWKS) ValidateToken(token string, tokenType string) (Claims, error) {
+	claims, _, err := j.validateToken(token, tokenType)
+
+	return claims, err
+}
+
+func (j *JWKS) validateToken(token string, tokenType string) (Claims, *jwt.Token, error) {
+	if j.cache == nil {
+		return j.verifyToken(token, tokenType)
+	}
+
+	key := tokenCacheKey(token, tokenType)
+	now := j.clock()
+
+	if cached, ok := j.cache.Get(key, now); ok && cached.claims.validAt(now) == nil {
+		return cached.claims, cached.token, nil
+	}
+
+	claims, t, err := j.verifyToken(token, tokenType)
+	if err != nil {
+		return Claims{}, nil, err
+	}
+
+	if claims.ExpiresAt != nil {
+		j.cache.Add(&cachedToken{
+			key:     key,
+			claims:  claims,
+			token:   t,
+			expires: claims.ExpiresAt.Time,
+		})
+	}
+
+	return claims, t, nil
+}
+
+func (j *JWKS) verifyToken(token string, tokenType string) (Claims, *jwt.Token, error) {
 	var claims Claims
 
-	t, err := jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+	// The time based claims are validated separately so that we
+	// can use our own clock.
+	parserOpts := []jwt.ParserOption{jwt.WithoutClaimsValidation()}
+
+	if len(j.algorithms) > 0 {
+		parserOpts = append(parserOpts, jwt.WithValidMethods(j.algorithms))
+	}
+
+	parser := jwt.NewParser(parserOpts...)
+
+	t, err := parser.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
 			return Claims{}, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
@@ -150,9 +440,11 @@ func (j *JWKS) ValidateToken(token string, tokenType string) (Claims, error) {
 			return Claims{}, fmt.Errorf("unexpected token type %q", claims.TokenType)
 		}
 
-		jwk, err := j.getKey(token.Header["kid"].(string))
+		kid, _ := token.Header["kid"].(string)
+
+		jwk, err := j.getKey(kid)
 		if err != nil {
-			return Claims{}, errors.New("unknown key id")
+			return Claims{}, err
 		}
 
 		// ensure we have the same algorithm
@@ -163,14 +455,19 @@ func (j *JWKS) ValidateToken(token string, tokenType string) (Claims, error) {
 		return jwk.publicKey()
 	})
 	if err != nil {
-		return Claims{}, fmt.Errorf("failed to parse token: %w", err)
+		return Claims{}, nil, fmt.Errorf("failed to parse token: %w", err)
 	}
 
 	if !t.Valid {
-		return Claims{}, errors.New("token is invalid")
+		return Claims{}, nil, errors.New("token is invalid")
+	}
+
+	err = claims.validAt(j.clock())
+	if err != nil {
+		return Claims{}, nil, fmt.Errorf("token is invalid: %w", err)
 	}
 
-	return claims, nil
+	return claims, t, nil
 }
 
 type jwksKey struct {
@@ -0,0 +1,62 @@
+package navigaid
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// PublicKey is a public RSA key that should be published in a JWKS.
+type PublicKey struct {
+	KeyID string
+	Key   *rsa.PublicKey
+	// Algorithm is the signing algorithm used with the key,
+	// defaults to RS512.
+	Algorithm string
+}
+
+type jwksDocument struct {
+	Keys        []jwksKey `json:"keys"`
+	MaxTokenTTL int       `json:"maxTokenTTL,omitempty"` //nolint:tagliatelle
+}
+
+// JWKSHandler creates a HTTP handler that serves a JWKS document with
+// the given public keys. It can be used by services that sign their
+// own tokens to publish the keys that consumers need to verify them.
+func JWKSHandler(keys ...PublicKey) http.Handler {
+	return jwksHandler(keys, 0)
+}
+
+func jwksHandler(keys []PublicKey, maxTokenTTL int) http.Handler {
+	doc := jwksDocument{
+		Keys:        make([]jwksKey, len(keys)),
+		MaxTokenTTL: maxTokenTTL,
+	}
+
+	for i, k := range keys {
+		alg := k.Algorithm
+		if alg == "" {
+			alg = jwt.SigningMethodRS512.Alg()
+		}
+
+		doc.Keys[i] = jwksKey{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: alg,
+			Kid: k.KeyID,
+			N:   base64.RawURLEncoding.EncodeToString(k.Key.N.Bytes()),
+			E: base64.RawURLEncoding.EncodeToString(
+				big.NewInt(int64(k.Key.E)).Bytes()),
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		_ = json.NewEncoder(w).Encode(doc)
+	})
+}
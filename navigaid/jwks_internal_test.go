@@ -0,0 +1,58 @@
+package navigaid
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestJWKSCacheTTL(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	samples := map[string]struct {
+		CacheControl string
+		Expires      string
+		TTL          time.Duration
+	}{
+		"NoHeaders":     {TTL: defaultJwksTTL},
+		"MaxAge":        {CacheControl: "public, max-age=3600", TTL: time.Hour},
+		"BelowMinimum":  {CacheControl: "max-age=5", TTL: defaultJwksMinCacheTTL},
+		"AboveMaximum":  {CacheControl: "max-age=604800", TTL: defaultJwksMaxCacheTTL},
+		"NoStore":       {CacheControl: "no-store", TTL: defaultJwksMinCacheTTL},
+		"InvalidMaxAge": {CacheControl: "max-age=soon", TTL: defaultJwksTTL},
+		"Expires": {
+			Expires: now.Add(2 * time.Hour).Format(http.TimeFormat),
+			TTL:     2 * time.Hour,
+		},
+		"MaxAgeOverExpires": {
+			CacheControl: "max-age=600",
+			Expires:      now.Add(2 * time.Hour).Format(http.TimeFormat),
+			TTL:          10 * time.Minute,
+		},
+	}
+
+	j := NewJWKS("http://localhost/v1/jwks", WithJwksClock(func() time.Time {
+		return now
+	}))
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			header := make(http.Header)
+
+			if tc.CacheControl != "" {
+				header.Set("Cache-Control", tc.CacheControl)
+			}
+
+			if tc.Expires != "" {
+				header.Set("Expires", tc.Expires)
+			}
+
+			got := j.cacheTTL(header)
+			if got != tc.TTL {
+				t.Fatalf("expected the TTL %v, got %v", tc.TTL, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package navigaid
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reasons used for the "reason" label of auth_attempts_total.
+const (
+	AuthReasonNone            = "none"
+	AuthReasonNoToken         = "no_token"
+	AuthReasonExpired         = "expired"
+	AuthReasonNotValidYet     = "not_valid_yet"
+	AuthReasonBadSignature    = "bad_signature"
+	AuthReasonUnknownKeyID    = "unknown_kid"
+	AuthReasonInvalidAudience = "invalid_audience"
+	AuthReasonInvalid         = "invalid"
+)
+
+// AuthMetrics counts authentication attempts by result and reason.
+type AuthMetrics struct {
+	attempts *prometheus.CounterVec
+}
+
+// AuthMetricsOption controls how the auth metrics are created.
+type AuthMetricsOption func(opts *authMetricsOptions)
+
+type authMetricsOptions struct {
+	namespace string
+	subsystem string
+}
+
+// WithAuthMetricsNamespace prefixes the metric name with a namespace
+// and subsystem.
+func WithAuthMetricsNamespace(namespace, subsystem string) AuthMetricsOption {
+	return func(opts *authMetricsOptions) {
+		opts.namespace = namespace
+		opts.subsystem = subsystem
+	}
+}
+
+// NewAuthMetrics creates and registers the auth_attempts_total
+// metric. Use WithAuthMetrics to make the middlewares record their
+// authentication attempts.
+func NewAuthMetrics(reg prometheus.Registerer, opts ...AuthMetricsOption) (*AuthMetrics, error) {
+	var opt authMetricsOptions
+
+	for i := range opts {
+		opts[i](&opt)
+	}
+
+	attempts := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: opt.namespace,
+			Subsystem: opt.subsystem,
+			Name:      "auth_attempts_total",
+			Help:      "Number of authentication attempts.",
+		},
+		[]string{"result", "reason"},
+	)
+	if err := reg.Register(attempts); err != nil {
+		return nil, fmt.Errorf("failed to register metric: %w", err)
+	}
+
+	return &AuthMetrics{
+		attempts: attempts,
+	}, nil
+}
+
+// Observe records the outcome of an authentication attempt.
+func (m *AuthMetrics) Observe(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+
+	m.attempts.WithLabelValues(result, AuthFailureReason(err)).Inc()
+}
+
+// AuthFailureReason classifies an authentication error.
+func AuthFailureReason(err error) string {
+	switch {
+	case err == nil:
+		return AuthReasonNone
+	case errors.As(err, &ErrNoToken{}):
+		return AuthReasonNoToken
+	case errors.Is(err, ErrTokenExpired):
+		return AuthReasonExpired
+	case errors.Is(err, ErrTokenNotValidYet):
+		return AuthReasonNotValidYet
+	case errors.Is(err, ErrUnknownKeyID):
+		return AuthReasonUnknownKeyID
+	case errors.Is(err, jwt.ErrSignatureInvalid), errors.Is(err, rsa.ErrVerification):
+		return AuthReasonBadSignature
+	case errors.Is(err, ErrInvalidAudience):
+		return AuthReasonInvalidAudience
+	default:
+		return AuthReasonInvalid
+	}
+}
+
+// WithAuthMetrics makes the middlewares record the outcome of their
+// authentication attempts.
+func WithAuthMetrics(m *AuthMetrics) AuthOption {
+	return func(o *authOptions) {
+		o.metrics = m
+	}
+}
+
+func (o authOptions) observe(err error) {
+	if o.metrics != nil {
+		o.metrics.Observe(err)
+	}
+}
@@ -0,0 +1,90 @@
+package navigaid_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/navigacontentlab/panurge/v2/navigaid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAuthMetrics(t *testing.T) {
+	mockServer, err := navigaid.NewMockServer(navigaid.MockServerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(mockServer.Server.Close)
+
+	jwks := navigaid.NewJWKS(
+		navigaid.ImasJWKSEndpoint(mockServer.Server.URL),
+		navigaid.WithJwksClient(mockServer.Client),
+	)
+
+	reg := prometheus.NewPedanticRegistry()
+
+	metrics, err := navigaid.NewAuthMetrics(reg)
+	if err != nil {
+		t.Fatalf("failed to create metrics: %v", err)
+	}
+
+	handler := navigaid.HTTPMiddleware(jwks,
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}), func(_ context.Context, _, _ string) {},
+		navigaid.WithAuthMetrics(metrics))
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	claims := func(exp time.Time) navigaid.Claims {
+		return navigaid.Claims{
+			Org: "sampleorg",
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(exp),
+			},
+		}
+	}
+
+	valid := getAccessToken(t, mockServer.PrivateKey, mockServer.PrivateKeyID,
+		claims(time.Now().Add(time.Minute)))
+	expired := getAccessToken(t, mockServer.PrivateKey, mockServer.PrivateKeyID,
+		claims(time.Now().Add(-time.Minute)))
+	unknownKey := getAccessToken(t, otherKey, "other-key",
+		claims(time.Now().Add(time.Minute)))
+	badSignature := getAccessToken(t, otherKey, mockServer.PrivateKeyID,
+		claims(time.Now().Add(time.Minute)))
+
+	for _, token := range []string{"", valid, expired, unknownKey, badSignature} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP auth_attempts_total Number of authentication attempts.
+# TYPE auth_attempts_total counter
+auth_attempts_total{reason="bad_signature",result="failure"} 1
+auth_attempts_total{reason="expired",result="failure"} 1
+auth_attempts_total{reason="no_token",result="failure"} 1
+auth_attempts_total{reason="none",result="success"} 1
+auth_attempts_total{reason="unknown_kid",result="failure"} 1
+`), "auth_attempts_total")
+	if err != nil {
+		t.Fatalf("unexpected metrics: %v", err)
+	}
+}
@@ -11,6 +11,10 @@ import (
 // AnnotationFunc is used to add authentication annotations to the context.
 type AnnotationFunc func(ctx context.Context, organisation string, user string)
 
+// ClaimsAnnotationFunc is used to add annotations based on the full
+// set of claims to the context.
+type ClaimsAnnotationFunc func(ctx context.Context, claims Claims)
+
 // HTTPMiddleware populates the request context with NavigaID
 // authentication information. If there's an XRay segment on the
 // context it will be decorated with the sub claim as the user and an
@@ -19,19 +23,31 @@ type AnnotationFunc func(ctx context.Context, organisation string, user string)
 // It is the responsibility of the individual handlers to act on
 // authentication errors by calling GetAuth() and inspecting the
 // error.
-func HTTPMiddleware(jwks *JWKS, next http.Handler, annotate AnnotationFunc) http.Handler {
+func HTTPMiddleware(
+	validator Validator, next http.Handler, annotate AnnotationFunc, options ...AuthOption,
+) http.Handler {
+	opts := newAuthOptions(options)
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
-		accessToken, err := getAuthToken(r.Header)
+		accessToken, err := TokenFromRequest(r, options...)
 		if err != nil {
+			opts.observe(err)
+
 			ctx = SetAuth(ctx, AuthInfo{}, err)
 			next.ServeHTTP(w, r.WithContext(ctx))
 
 			return
 		}
 
-		claims, err := jwks.Validate(accessToken)
+		claims, err := validator.Validate(accessToken)
+		if err == nil {
+			err = opts.checkClaims(claims)
+		}
+
+		opts.observe(err)
+
 		if err != nil {
 			ctx = SetAuth(ctx, AuthInfo{}, err)
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -40,6 +56,7 @@ func HTTPMiddleware(jwks *JWKS, next http.Handler, annotate AnnotationFunc) http
 		}
 
 		annotate(ctx, claims.Org, claims.Subject)
+		opts.annotateClaims(ctx, claims)
 
 		ctx = SetAuth(ctx, AuthInfo{
 			AccessToken: accessToken,
@@ -52,11 +69,13 @@ func HTTPMiddleware(jwks *JWKS, next http.Handler, annotate AnnotationFunc) http
 // NewTwirpAuthHook creates a twirp server hook that requires a valid
 // NavigaID access token and adds the authentication result to the
 // request context.
-func NewTwirpAuthHook(_ *slog.Logger, jwks *JWKS, annotate AnnotationFunc) *twirp.ServerHooks {
+func NewTwirpAuthHook(
+	_ *slog.Logger, validator Validator, annotate AnnotationFunc, options ...AuthOption,
+) *twirp.ServerHooks {
 	var hooks twirp.ServerHooks
 
 	hooks.RequestRouted = func(ctx context.Context) (context.Context, error) {
-		return TwirpAuthenticate(ctx, jwks, annotate)
+		return TwirpAuthenticate(ctx, validator, annotate, options...)
 	}
 
 	return &hooks
@@ -64,25 +83,33 @@ func NewTwirpAuthHook(_ *slog.Logger, jwks *JWKS, annotate AnnotationFunc) *twir
 
 // TwirpAuthenticate verifies that there is a valid access token and
 // adds the authentication result to the request context.
-func TwirpAuthenticate(ctx context.Context, jwks *JWKS, annotate AnnotationFunc) (context.Context, error) {
-	headers, ok := twirp.HTTPRequestHeaders(ctx)
-	if !ok {
-		return ctx, twirp.NewError(twirp.Unauthenticated, "Unauthenticated")
-	}
+func TwirpAuthenticate(
+	ctx context.Context, validator Validator, annotate AnnotationFunc, options ...AuthOption,
+) (context.Context, error) {
+	opts := newAuthOptions(options)
 
-	accessToken, err := getAuthToken(headers)
+	accessToken, err := TokenFromTwirpContext(ctx, options...)
 	if err != nil {
+		opts.observe(err)
+
 		return ctx, twirp.NewError(
 			twirp.Unauthenticated, "Unauthenticated")
 	}
 
-	claims, err := jwks.Validate(accessToken)
+	claims, err := validator.Validate(accessToken)
+	if err == nil {
+		err = opts.checkClaims(claims)
+	}
+
+	opts.observe(err)
+
 	if err != nil {
 		return ctx, twirp.NewError(
 			twirp.Unauthenticated, "Unauthenticated")
 	}
 
 	annotate(ctx, claims.Org, claims.Subject)
+	opts.annotateClaims(ctx, claims)
 
 	authCtx := SetAuth(ctx, AuthInfo{
 		AccessToken: accessToken,
@@ -91,3 +118,112 @@ func TwirpAuthenticate(ctx context.Context, jwks *JWKS, annotate AnnotationFunc)
 
 	return authCtx, nil
 }
+
+// OrgAllowlistMiddleware creates a HTTP middleware that only lets
+// requests through if they have been authenticated for one of the
+// allowed organisations. It must be used after HTTPMiddleware, as it
+// relies on the authentication information in the request context.
+func OrgAllowlistMiddleware(allowed ...string) func(next http.Handler) http.Handler {
+	orgs := orgSet(allowed)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth, err := GetAuth(r.Context())
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+
+				return
+			}
+
+			if !orgs[auth.Claims.Org] {
+				w.WriteHeader(http.StatusForbidden)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// NewTwirpOrgAllowlistHook creates a twirp server hook that only lets
+// requests through if they have been authenticated for one of the
+// allowed organisations. It must be chained after the authentication
+// hook.
+func NewTwirpOrgAllowlistHook(allowed ...string) *twirp.ServerHooks {
+	orgs := orgSet(allowed)
+
+	var hooks twirp.ServerHooks
+
+	hooks.RequestRouted = func(ctx context.Context) (context.Context, error) {
+		auth, err := GetAuth(ctx)
+		if err != nil {
+			return ctx, twirp.NewError(twirp.Unauthenticated, "Unauthenticated")
+		}
+
+		if !orgs[auth.Claims.Org] {
+			return ctx, twirp.NewError(twirp.PermissionDenied,
+				"organisation is not allowed to use this service")
+		}
+
+		return ctx, nil
+	}
+
+	return &hooks
+}
+
+// Headers used to echo the authenticated identity for debugging.
+const (
+	DebugOrgHeader     = "X-Debug-Org"
+	DebugSubjectHeader = "X-Debug-Subject"
+)
+
+// DebugIdentityMiddleware creates a HTTP middleware that adds the
+// authenticated organisation and subject as X-Debug-Org and
+// X-Debug-Subject response headers. It must be used after
+// HTTPMiddleware. This leaks identity information to the client and
+// should only be used for testing, never in production.
+func DebugIdentityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, err := GetAuth(r.Context())
+		if err == nil {
+			w.Header().Set(DebugOrgHeader, auth.Claims.Org)
+			w.Header().Set(DebugSubjectHeader, auth.Claims.Subject)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// NewTwirpDebugIdentityHook creates a twirp server hook that adds the
+// authenticated organisation and subject as X-Debug-Org and
+// X-Debug-Subject response headers. It must be chained after the
+// authentication hook. This leaks identity information to the client
+// and should only be used for testing, never in production.
+func NewTwirpDebugIdentityHook() *twirp.ServerHooks {
+	var hooks twirp.ServerHooks
+
+	hooks.RequestRouted = func(ctx context.Context) (context.Context, error) {
+		auth, err := GetAuth(ctx)
+		if err != nil {
+			return ctx, nil
+		}
+
+		_ = twirp.SetHTTPResponseHeader(ctx, DebugOrgHeader, auth.Claims.Org)
+		_ = twirp.SetHTTPResponseHeader(ctx, DebugSubjectHeader, auth.Claims.Subject)
+
+		return ctx, nil
+	}
+
+	return &hooks
+}
+
+func orgSet(orgs []string) map[string]bool {
+	set := make(map[string]bool, len(orgs))
+
+	for _, org := range orgs {
+		set[org] = true
+	}
+
+	return set
+}
@@ -8,11 +8,13 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/navigacontentlab/panurge/v2/navigaid"
+	"github.com/twitchtv/twirp"
 )
 
 //nolint:funlen
@@ -225,3 +227,566 @@ func getAccessToken(t *testing.T, signKey *rsa.PrivateKey, keyID string, claims
 
 	return accessToken
 }
+
+func TestOrgAllowlistMiddleware(t *testing.T) {
+	handler := navigaid.OrgAllowlistMiddleware("hms-govt", "mi5")(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+	samples := map[string]struct {
+		Auth   *navigaid.AuthInfo
+		Status int
+	}{
+		"Allowed":         {Auth: &navigaid.AuthInfo{Claims: navigaid.Claims{Org: "mi5"}}, Status: http.StatusNoContent},
+		"NotAllowed":      {Auth: &navigaid.AuthInfo{Claims: navigaid.Claims{Org: "kgb"}}, Status: http.StatusForbidden},
+		"Unauthenticated": {Status: http.StatusUnauthorized},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			ctx := navigaid.SetAuth(context.Background(), navigaid.AuthInfo{}, errors.New("no token"))
+			if tc.Auth != nil {
+				ctx = navigaid.SetAuth(context.Background(), *tc.Auth, nil)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.Status {
+				t.Fatalf("expected status %d, got %d", tc.Status, rec.Code)
+			}
+		})
+	}
+}
+
+func TestTokenFromTwirpContext(t *testing.T) {
+	_, err := navigaid.TokenFromTwirpContext(context.Background())
+	if !errors.As(err, &navigaid.ErrNoToken{}) {
+		t.Fatalf("expected a no token error without headers, got: %v", err)
+	}
+
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer abc123")
+
+	ctx, err := twirp.WithHTTPRequestHeaders(context.Background(), header)
+	if err != nil {
+		t.Fatalf("failed to add headers to context: %v", err)
+	}
+
+	token, err := navigaid.TokenFromTwirpContext(ctx)
+	if err != nil {
+		t.Fatalf("failed to get token: %v", err)
+	}
+
+	if token != "abc123" {
+		t.Fatalf("wanted the token %q, got %q", "abc123", token)
+	}
+}
+
+type fakeValidator struct {
+	Claims navigaid.Claims
+}
+
+func (fv fakeValidator) Validate(accessToken string) (navigaid.Claims, error) {
+	return fv.ValidateToken(accessToken, navigaid.TokenTypeAccessToken)
+}
+
+func (fv fakeValidator) ValidateToken(token string, _ string) (navigaid.Claims, error) {
+	if token != "valid" {
+		return navigaid.Claims{}, errors.New("invalid token")
+	}
+
+	return fv.Claims, nil
+}
+
+func TestTwirpAuthenticate_CustomValidator(t *testing.T) {
+	validator := fakeValidator{
+		Claims: navigaid.Claims{Org: "sampleorg"},
+	}
+
+	authenticate := func(token string) (context.Context, error) {
+		header := make(http.Header)
+		header.Set("Authorization", "Bearer "+token)
+
+		ctx, err := twirp.WithHTTPRequestHeaders(context.Background(), header)
+		if err != nil {
+			t.Fatalf("failed to add headers to context: %v", err)
+		}
+
+		return navigaid.TwirpAuthenticate(ctx, validator,
+			func(_ context.Context, _, _ string) {})
+	}
+
+	ctx, err := authenticate("valid")
+	if err != nil {
+		t.Fatalf("expected authentication to succeed: %v", err)
+	}
+
+	auth, err := navigaid.GetAuth(ctx)
+	if err != nil {
+		t.Fatalf("expected authentication information in context: %v", err)
+	}
+
+	if auth.Claims.Org != "sampleorg" {
+		t.Fatalf("wanted the org %q, got %q", "sampleorg", auth.Claims.Org)
+	}
+
+	_, err = authenticate("invalid")
+
+	var tErr twirp.Error
+	if !errors.As(err, &tErr) || tErr.Code() != twirp.Unauthenticated {
+		t.Fatalf("expected an unauthenticated error, got: %v", err)
+	}
+}
+
+func TestTwirpAuthenticate_Audience(t *testing.T) {
+	validator := fakeValidator{
+		Claims: navigaid.Claims{
+			Org: "sampleorg",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience: jwt.ClaimStrings{"service-a"},
+			},
+		},
+	}
+
+	authenticate := func(audience string) error {
+		header := make(http.Header)
+		header.Set("Authorization", "Bearer valid")
+
+		ctx, err := twirp.WithHTTPRequestHeaders(context.Background(), header)
+		if err != nil {
+			t.Fatalf("failed to add headers to context: %v", err)
+		}
+
+		_, err = navigaid.TwirpAuthenticate(ctx, validator,
+			func(_ context.Context, _, _ string) {},
+			navigaid.WithAudience(audience))
+
+		return err
+	}
+
+	if err := authenticate("service-a"); err != nil {
+		t.Fatalf("expected authentication to succeed: %v", err)
+	}
+
+	if err := authenticate(""); err != nil {
+		t.Fatalf("expected authentication without audience check to succeed: %v", err)
+	}
+
+	err := authenticate("service-b")
+
+	var tErr twirp.Error
+	if !errors.As(err, &tErr) || tErr.Code() != twirp.Unauthenticated {
+		t.Fatalf("expected an unauthenticated error, got: %v", err)
+	}
+}
+
+func TestTwirpAuthenticate_ClaimsAnnotation(t *testing.T) {
+	validator := fakeValidator{
+		Claims: navigaid.Claims{Org: "sampleorg", Groups: []string{"editors"}},
+	}
+
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer valid")
+
+	ctx, err := twirp.WithHTTPRequestHeaders(context.Background(), header)
+	if err != nil {
+		t.Fatalf("failed to add headers to context: %v", err)
+	}
+
+	var (
+		annotated []string
+		groups    []string
+	)
+
+	_, err = navigaid.TwirpAuthenticate(ctx, validator,
+		func(_ context.Context, org, _ string) {
+			annotated = append(annotated, "org:"+org)
+		},
+		navigaid.WithClaimsAnnotation(func(_ context.Context, claims navigaid.Claims) {
+			annotated = append(annotated, "claims")
+			groups = claims.Groups
+		}))
+	if err != nil {
+		t.Fatalf("expected authentication to succeed: %v", err)
+	}
+
+	if strings.Join(annotated, ",") != "org:sampleorg,claims" {
+		t.Fatalf("expected the standard annotation followed by the claims annotation, got %v",
+			annotated)
+	}
+
+	if len(groups) != 1 || groups[0] != "editors" {
+		t.Fatalf("expected the claims annotation to get the groups, got %v", groups)
+	}
+}
+
+func TestRequirePermissionsInUnit(t *testing.T) {
+	ctx := navigaid.SetAuth(context.Background(), navigaid.AuthInfo{
+		Claims: navigaid.Claims{
+			Org: "hms-govt",
+			Permissions: navigaid.PermissionsClaim{
+				Org: []string{"access-building"},
+				Units: map[string][]string{
+					"mi6": {"read-files"},
+				},
+			},
+		},
+	}, nil)
+
+	err := navigaid.RequirePermissionsInUnit(ctx, "mi6", "access-building", "read-files")
+	if err != nil {
+		t.Fatalf("expected the permissions to be granted: %v", err)
+	}
+
+	err = navigaid.RequirePermissionsInUnit(ctx, "mi6",
+		"access-building", "q-equipment", "read-files", "permission-to-kill")
+
+	var tErr twirp.Error
+	if !errors.As(err, &tErr) || tErr.Code() != twirp.PermissionDenied {
+		t.Fatalf("expected a permission denied error, got: %v", err)
+	}
+
+	want := "q-equipment,permission-to-kill"
+	if tErr.Meta("missing_permissions") != want {
+		t.Fatalf("wanted the missing permissions %q, got %q",
+			want, tErr.Meta("missing_permissions"))
+	}
+
+	err = navigaid.RequirePermissionsInOrganisation(ctx, "access-building")
+	if err != nil {
+		t.Fatalf("expected the permissions to be granted: %v", err)
+	}
+}
+
+func TestTokenFromRequest_LegacyHeader(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(navigaid.LegacyTokenHeader, "legacy")
+
+	_, err := navigaid.TokenFromRequest(req)
+	if !errors.As(err, &navigaid.ErrNoToken{}) {
+		t.Fatalf("expected the legacy header to be ignored by default, got: %v", err)
+	}
+
+	token, err := navigaid.TokenFromRequest(req, navigaid.WithLegacyTokenHeader())
+	if err != nil {
+		t.Fatalf("failed to get token: %v", err)
+	}
+
+	if token != "legacy" {
+		t.Fatalf("wanted the token %q, got %q", "legacy", token)
+	}
+
+	req.Header.Set("Authorization", "Bearer standard")
+
+	token, err = navigaid.TokenFromRequest(req, navigaid.WithLegacyTokenHeader())
+	if err != nil {
+		t.Fatalf("failed to get token: %v", err)
+	}
+
+	if token != "standard" {
+		t.Fatalf("expected the Authorization header to take precedence, got %q", token)
+	}
+}
+
+func TestTokenFromRequest_TokenHeaders(t *testing.T) {
+	opt := navigaid.WithTokenHeaders(
+		navigaid.TokenHeader{Name: "X-Forwarded-Access-Token"},
+		navigaid.TokenHeader{Name: "Authorization", Bearer: true},
+	)
+
+	samples := map[string]struct {
+		Forwarded     string
+		Authorization string
+		Want          string
+		Fail          bool
+	}{
+		"Forwarded":     {Forwarded: "gateway", Authorization: "Bearer standard", Want: "gateway"},
+		"Authorization": {Authorization: "Bearer standard", Want: "standard"},
+		"NotBearer":     {Authorization: "Basic dXNlcjpwYXNz", Fail: true},
+		"NoHeaders":     {Fail: true},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			if tc.Forwarded != "" {
+				req.Header.Set("X-Forwarded-Access-Token", tc.Forwarded)
+			}
+
+			if tc.Authorization != "" {
+				req.Header.Set("Authorization", tc.Authorization)
+			}
+
+			token, err := navigaid.TokenFromRequest(req, opt)
+			if tc.Fail {
+				if !errors.As(err, &navigaid.ErrNoToken{}) {
+					t.Fatalf("expected a missing token error, got: %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to get token: %v", err)
+			}
+
+			if token != tc.Want {
+				t.Fatalf("wanted the token %q, got %q", tc.Want, token)
+			}
+		})
+	}
+}
+
+func TestTokenFromRequest_QueryToken(t *testing.T) {
+	samples := map[string]struct {
+		Method        string
+		Target        string
+		Authorization string
+		Options       []navigaid.AuthOption
+		Want          string
+		Fail          bool
+	}{
+		"Query": {
+			Target:  "/download?access_token=query",
+			Options: []navigaid.AuthOption{navigaid.WithQueryToken("")},
+			Want:    "query",
+		},
+		"CustomParam": {
+			Target:  "/download?t=query",
+			Options: []navigaid.AuthOption{navigaid.WithQueryToken("t")},
+			Want:    "query",
+		},
+		"HeaderFirst": {
+			Target:        "/download?access_token=query",
+			Authorization: "Bearer header",
+			Options:       []navigaid.AuthOption{navigaid.WithQueryToken("")},
+			Want:          "header",
+		},
+		"NotEnabled": {
+			Target: "/download?access_token=query",
+			Fail:   true,
+		},
+		"NotGet": {
+			Method:  http.MethodPost,
+			Target:  "/download?access_token=query",
+			Options: []navigaid.AuthOption{navigaid.WithQueryToken("")},
+			Fail:    true,
+		},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			method := tc.Method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			req := httptest.NewRequest(method, tc.Target, nil)
+
+			if tc.Authorization != "" {
+				req.Header.Set("Authorization", tc.Authorization)
+			}
+
+			token, err := navigaid.TokenFromRequest(req, tc.Options...)
+			if tc.Fail {
+				if !errors.As(err, &navigaid.ErrNoToken{}) {
+					t.Fatalf("expected a missing token error, got: %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to get token: %v", err)
+			}
+
+			if token != tc.Want {
+				t.Fatalf("wanted the token %q, got %q", tc.Want, token)
+			}
+		})
+	}
+}
+
+func TestDebugIdentityMiddleware(t *testing.T) {
+	handler := navigaid.DebugIdentityMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+	auth := navigaid.AuthInfo{Claims: navigaid.Claims{Org: "mi5"}}
+	auth.Claims.Subject = "007"
+
+	ctx := navigaid.SetAuth(context.Background(), auth, nil)
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	if got := rec.Header().Get(navigaid.DebugOrgHeader); got != "mi5" {
+		t.Errorf("expected the org header to be %q, got %q", "mi5", got)
+	}
+
+	if got := rec.Header().Get(navigaid.DebugSubjectHeader); got != "007" {
+		t.Errorf("expected the subject header to be %q, got %q", "007", got)
+	}
+
+	ctx = navigaid.SetAuth(context.Background(), navigaid.AuthInfo{}, errors.New("no token"))
+	rec = httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	if got := rec.Header().Get(navigaid.DebugOrgHeader); got != "" {
+		t.Errorf("expected no org header for unauthenticated requests, got %q", got)
+	}
+}
+
+func TestRequireValidClaims(t *testing.T) {
+	ctx := navigaid.SetAuth(context.Background(), navigaid.AuthInfo{
+		Claims: navigaid.Claims{
+			Org:    "hms-govt",
+			Groups: []string{"double-o"},
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "hms-govt://agent/007",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
+			},
+			Permissions: navigaid.PermissionsClaim{
+				Org: []string{"permission-to-kill"},
+			},
+		},
+	}, nil)
+
+	samples := map[string]struct {
+		Checks []navigaid.ClaimsCheck
+		Code   twirp.ErrorCode
+		Meta   [2]string
+	}{
+		"AllPass": {
+			Checks: []navigaid.ClaimsCheck{
+				navigaid.CheckOrg(),
+				navigaid.CheckSubject(),
+				navigaid.CheckMinLifetime(time.Minute),
+				navigaid.CheckGroups("double-o"),
+				navigaid.CheckPermissions("permission-to-kill"),
+			},
+		},
+		"ExpiresSoon": {
+			Checks: []navigaid.ClaimsCheck{navigaid.CheckMinLifetime(time.Hour)},
+			Code:   twirp.Unauthenticated,
+			Meta:   [2]string{"reason", "token_expiring"},
+		},
+		"MissingGroup": {
+			Checks: []navigaid.ClaimsCheck{navigaid.CheckGroups("double-o", "q-branch")},
+			Code:   twirp.PermissionDenied,
+			Meta:   [2]string{"missing_groups", "q-branch"},
+		},
+		"FirstFailureWins": {
+			Checks: []navigaid.ClaimsCheck{
+				navigaid.CheckPermissions("read-files"),
+				navigaid.CheckMinLifetime(time.Hour),
+			},
+			Code: twirp.PermissionDenied,
+			Meta: [2]string{"missing_permissions", "read-files"},
+		},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			err := navigaid.RequireValidClaims(ctx, tc.Checks...)
+			if tc.Code == "" {
+				if err != nil {
+					t.Fatalf("expected the checks to pass: %v", err)
+				}
+
+				return
+			}
+
+			var tErr twirp.Error
+			if !errors.As(err, &tErr) || tErr.Code() != tc.Code {
+				t.Fatalf("expected a %q error, got: %v", tc.Code, err)
+			}
+
+			if tErr.Meta(tc.Meta[0]) != tc.Meta[1] {
+				t.Fatalf("wanted the %q meta %q, got %q",
+					tc.Meta[0], tc.Meta[1], tErr.Meta(tc.Meta[0]))
+			}
+		})
+	}
+
+	err := navigaid.RequireValidClaims(context.Background(), navigaid.CheckOrg())
+
+	var tErr twirp.Error
+	if !errors.As(err, &tErr) || tErr.Code() != twirp.Unauthenticated {
+		t.Fatalf("expected an unauthenticated error without auth, got: %v", err)
+	}
+}
+
+func TestTwirpOrgAllowlistHook(t *testing.T) {
+	hooks := navigaid.NewTwirpOrgAllowlistHook("hms-govt", "mi5")
+
+	samples := map[string]struct {
+		Auth    *navigaid.AuthInfo
+		AuthErr error
+		Code    twirp.ErrorCode
+	}{
+		"Allowed": {
+			Auth: &navigaid.AuthInfo{Claims: navigaid.Claims{Org: "mi5"}},
+		},
+		"NotAllowed": {
+			Auth: &navigaid.AuthInfo{Claims: navigaid.Claims{Org: "kgb"}},
+			Code: twirp.PermissionDenied,
+		},
+		"MissingOrgClaim": {
+			Auth: &navigaid.AuthInfo{Claims: navigaid.Claims{}},
+			Code: twirp.PermissionDenied,
+		},
+		"AuthFailed": {
+			AuthErr: errors.New("no token"),
+			Code:    twirp.Unauthenticated,
+		},
+		"NoAuth": {
+			Code: twirp.Unauthenticated,
+		},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			switch {
+			case tc.Auth != nil:
+				ctx = navigaid.SetAuth(ctx, *tc.Auth, nil)
+			case tc.AuthErr != nil:
+				ctx = navigaid.SetAuth(ctx, navigaid.AuthInfo{}, tc.AuthErr)
+			}
+
+			_, err := hooks.RequestRouted(ctx)
+
+			if tc.Code == "" {
+				if err != nil {
+					t.Fatalf("expected the request to be allowed, got: %v", err)
+				}
+
+				return
+			}
+
+			var tErr twirp.Error
+			if !errors.As(err, &tErr) || tErr.Code() != tc.Code {
+				t.Fatalf("expected a %q error, got: %v", tc.Code, err)
+			}
+		})
+	}
+}
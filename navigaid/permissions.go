@@ -0,0 +1,147 @@
+package navigaid
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/twitchtv/twirp"
+)
+
+// RequirePermissionsInUnit returns a Twirp error if the authenticated
+// holder lacks any of the required permissions in the unit. The
+// missing permissions are included in the "missing_permissions" error
+// meta as a comma separated list.
+func RequirePermissionsInUnit(ctx context.Context, unit string, required ...string) error {
+	auth, err := GetAuth(ctx)
+	if err != nil {
+		return twirp.NewError(twirp.Unauthenticated, "Unauthenticated")
+	}
+
+	return permissionDenied(auth.Claims.MissingPermissionsInUnit(unit, required...))
+}
+
+// RequirePermissionsInOrganisation returns a Twirp error if the
+// authenticated holder lacks any of the required permissions in the
+// organisation. The missing permissions are included in the
+// "missing_permissions" error meta as a comma separated list.
+func RequirePermissionsInOrganisation(ctx context.Context, required ...string) error {
+	auth, err := GetAuth(ctx)
+	if err != nil {
+		return twirp.NewError(twirp.Unauthenticated, "Unauthenticated")
+	}
+
+	return permissionDenied(auth.Claims.MissingPermissionsInOrganisation(required...))
+}
+
+func permissionDenied(missing []string) error {
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return twirp.NewError(twirp.PermissionDenied, "missing permissions").
+		WithMeta("missing_permissions", strings.Join(missing, ","))
+}
+
+// ClaimsCheck is a precondition for the claims that is used with
+// RequireValidClaims, it returns a Twirp error if it fails.
+type ClaimsCheck func(claims Claims, now time.Time) error
+
+// RequireValidClaims runs the checks against the claims of the
+// authenticated holder and returns the error of the first failed
+// check. An unauthenticated error is returned if the context lacks
+// authentication information.
+func RequireValidClaims(ctx context.Context, checks ...ClaimsCheck) error {
+	auth, err := GetAuth(ctx)
+	if err != nil {
+		return twirp.NewError(twirp.Unauthenticated, "Unauthenticated")
+	}
+
+	now := time.Now()
+
+	for _, check := range checks {
+		err := check(auth.Claims, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CheckOrg requires the claims to have an organisation.
+func CheckOrg() ClaimsCheck {
+	return func(claims Claims, _ time.Time) error {
+		if claims.Org == "" {
+			return twirp.NewError(twirp.PermissionDenied,
+				"the token has no organisation")
+		}
+
+		return nil
+	}
+}
+
+// CheckSubject requires the claims to have a subject.
+func CheckSubject() ClaimsCheck {
+	return func(claims Claims, _ time.Time) error {
+		if claims.Subject == "" {
+			return twirp.NewError(twirp.PermissionDenied,
+				"the token has no subject")
+		}
+
+		return nil
+	}
+}
+
+// CheckMinLifetime requires the token to be valid for at least the
+// given duration, so that it doesn't expire during a long running
+// operation. The error has the "token_expiring" reason meta, telling
+// the client to refresh the token.
+func CheckMinLifetime(d time.Duration) ClaimsCheck {
+	return func(claims Claims, now time.Time) error {
+		if claims.ExpiresAt == nil || claims.ExpiresAt.Time.Sub(now) >= d {
+			return nil
+		}
+
+		return twirp.NewError(twirp.Unauthenticated,
+			"the token expires too soon").
+			WithMeta("reason", "token_expiring")
+	}
+}
+
+// CheckGroups requires the holder to be a member of all the groups.
+// The missing groups are included in the "missing_groups" error meta
+// as a comma separated list.
+func CheckGroups(groups ...string) ClaimsCheck {
+	return func(claims Claims, _ time.Time) error {
+		member := make(map[string]bool, len(claims.Groups))
+
+		for _, g := range claims.Groups {
+			member[g] = true
+		}
+
+		var missing []string
+
+		for _, g := range groups {
+			if !member[g] {
+				missing = append(missing, g)
+			}
+		}
+
+		if len(missing) == 0 {
+			return nil
+		}
+
+		return twirp.NewError(twirp.PermissionDenied, "missing groups").
+			WithMeta("missing_groups", strings.Join(missing, ","))
+	}
+}
+
+// CheckPermissions requires the holder to have all the permissions
+// in the organisation, see RequirePermissionsInOrganisation.
+func CheckPermissions(permissions ...string) ClaimsCheck {
+	return func(claims Claims, _ time.Time) error {
+		return permissionDenied(
+			claims.MissingPermissionsInOrganisation(permissions...))
+	}
+}
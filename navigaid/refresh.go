@@ -0,0 +1,215 @@
+package navigaid
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	minRefreshInterval = time.Second
+	refreshRetryDelay  = 5 * time.Second
+	clockCheckInterval = 50 * time.Millisecond
+)
+
+// RefreshOption controls the behaviour of RefreshingAuthContext.
+type RefreshOption func(opts *refreshOptions)
+
+type refreshOptions struct {
+	clock         Clock
+	checkInterval time.Duration
+	ticks         <-chan time.Time
+	notify        func(refreshed bool, err error)
+}
+
+// WithRefreshClock sets the clock that is used to decide when to
+// refresh the access token. The clock is checked regularly, so that
+// tests can trigger a refresh by advancing a mock clock, see
+// MockServer.AdvanceTime.
+func WithRefreshClock(clock Clock) RefreshOption {
+	return func(opts *refreshOptions) {
+		opts.clock = clock
+		opts.checkInterval = clockCheckInterval
+	}
+}
+
+// WithRefreshTicker makes the refresh loop check if the access token
+// should be refreshed every time it receives a tick, instead of using
+// timers. Combined with WithRefreshClock and WithRefreshNotify it lets
+// tests control exactly when the checks happen.
+func WithRefreshTicker(ticks <-chan time.Time) RefreshOption {
+	return func(opts *refreshOptions) {
+		opts.ticks = ticks
+	}
+}
+
+// WithRefreshNotify sets a function that is called after every check,
+// with whether the access token was refreshed and the error if the
+// refresh failed.
+func WithRefreshNotify(fn func(refreshed bool, err error)) RefreshOption {
+	return func(opts *refreshOptions) {
+		opts.notify = fn
+	}
+}
+
+// refreshingAuth holds authentication information that is replaced
+// as the access token is refreshed.
+type refreshingAuth struct {
+	m    sync.RWMutex
+	auth ai
+}
+
+func (r *refreshingAuth) get() ai {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	return r.auth
+}
+
+func (r *refreshingAuth) set(auth ai) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.auth = auth
+}
+
+// RefreshingAuthContext exchanges the NavigaID token for an access
+// token and returns a context with authentication information that
+// is refreshed automatically before the access token expires. This
+// is meant for long-lived requests, like Server-Sent Events streams,
+// that make downstream calls using Transport.
+//
+// The refreshing stops when the returned cancel function is called or
+// the parent context is done. If a refresh fails it's retried, and
+// GetAuth will return the refresh error once the current access token
+// has expired.
+func RefreshingAuthContext(
+	ctx context.Context, navigaIDToken string,
+	tokens *AccessTokenService, validator Validator,
+	options ...RefreshOption,
+) (context.Context, context.CancelFunc, error) {
+	opts := refreshOptions{
+		clock:  time.Now,
+		notify: func(_ bool, _ error) {},
+	}
+
+	for i := range options {
+		options[i](&opts)
+	}
+
+	auth, expires, err := exchangeToken(opts.clock, navigaIDToken, tokens, validator)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ref := refreshingAuth{auth: ai{Ac: auth}}
+
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, authInfoKey, &ref))
+
+	now := opts.clock()
+	refreshAt := now.Add(refreshDelay(now, expires))
+
+	go func() {
+		for {
+			if !opts.wait(ctx, refreshAt) {
+				return
+			}
+
+			if opts.clock().Before(refreshAt) {
+				opts.notify(false, nil)
+
+				continue
+			}
+
+			auth, newExpires, err := exchangeToken(opts.clock, navigaIDToken, tokens, validator)
+			if err != nil {
+				if !opts.clock().Before(expires) {
+					ref.set(ai{Err: err})
+				}
+
+				refreshAt = opts.clock().Add(refreshRetryDelay)
+
+				opts.notify(false, err)
+
+				continue
+			}
+
+			ref.set(ai{Ac: auth})
+
+			expires = newExpires
+			refreshed := opts.clock()
+			refreshAt = refreshed.Add(refreshDelay(refreshed, expires))
+
+			opts.notify(true, nil)
+		}
+	}()
+
+	return ctx, cancel, nil
+}
+
+// wait blocks until it's time to check if the token should be
+// refreshed. Returns false if the context is done.
+func (opts *refreshOptions) wait(ctx context.Context, refreshAt time.Time) bool {
+	if opts.ticks != nil {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-opts.ticks:
+			return true
+		}
+	}
+
+	delay := refreshAt.Sub(opts.clock())
+	if opts.checkInterval > 0 && delay > opts.checkInterval {
+		delay = opts.checkInterval
+	}
+
+	timer := time.NewTimer(delay)
+
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// refreshDelay returns the time to wait before refreshing a token,
+// refreshing when 80% of the remaining lifetime has passed.
+func refreshDelay(now time.Time, expires time.Time) time.Duration {
+	delay := expires.Sub(now) * 4 / 5
+	if delay < minRefreshInterval {
+		return minRefreshInterval
+	}
+
+	return delay
+}
+
+func exchangeToken(
+	clock Clock, navigaIDToken string, tokens *AccessTokenService, validator Validator,
+) (AuthInfo, time.Time, error) {
+	res, err := tokens.NewAccessToken(navigaIDToken)
+	if err != nil {
+		return AuthInfo{}, time.Time{}, fmt.Errorf(
+			"failed to get access token: %w", err)
+	}
+
+	claims, err := validator.Validate(res.AccessToken)
+	if err != nil {
+		return AuthInfo{}, time.Time{}, fmt.Errorf(
+			"failed to validate access token: %w", err)
+	}
+
+	expires := clock().Add(time.Duration(res.ExpiresIn) * time.Second)
+	if claims.ExpiresAt != nil {
+		expires = claims.ExpiresAt.Time
+	}
+
+	return AuthInfo{
+		AccessToken: res.AccessToken,
+		Claims:      claims,
+	}, expires, nil
+}
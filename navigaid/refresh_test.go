@@ -0,0 +1,100 @@
+package navigaid_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/navigacontentlab/panurge/v2/navigaid"
+)
+
+func TestRefreshingAuthContext(t *testing.T) {
+	mockServer, err := navigaid.NewMockServer(navigaid.MockServerOptions{
+		Claims: navigaid.Claims{
+			Org: "sampleorg",
+		},
+		TTL: 600,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(mockServer.Server.Close)
+
+	mockServer.SetNow(func() time.Time {
+		return time.Date(2020, time.April, 7, 9, 0, 0, 0, time.UTC)
+	})
+
+	tokens := navigaid.New(
+		navigaid.AccessTokenEndpoint(mockServer.Server.URL),
+		navigaid.WithAccessTokenClient(mockServer.Client),
+	)
+
+	jwks := navigaid.NewJWKS(
+		navigaid.ImasJWKSEndpoint(mockServer.Server.URL),
+		navigaid.WithJwksClient(mockServer.Client),
+		navigaid.WithJwksClock(mockServer.Now),
+	)
+
+	ticks := make(chan time.Time)
+	checks := make(chan bool, 1)
+
+	ctx, cancel, err := navigaid.RefreshingAuthContext(
+		context.Background(), "testNavigaIDToken", tokens, jwks,
+		navigaid.WithRefreshClock(mockServer.Now),
+		navigaid.WithRefreshTicker(ticks),
+		navigaid.WithRefreshNotify(func(refreshed bool, err error) {
+			if err != nil {
+				t.Errorf("failed to refresh the access token: %v", err)
+			}
+
+			checks <- refreshed
+		}))
+	if err != nil {
+		t.Fatalf("failed to create refreshing context: %v", err)
+	}
+
+	t.Cleanup(cancel)
+
+	initial, err := navigaid.GetAuth(ctx)
+	if err != nil {
+		t.Fatalf("expected the context to be authenticated: %v", err)
+	}
+
+	check := func() bool {
+		ticks <- mockServer.Now()
+
+		return <-checks
+	}
+
+	// The token is refreshed when 80% of its lifetime has passed.
+	mockServer.AdvanceTime(7 * time.Minute)
+
+	if check() {
+		t.Fatal("didn't expect the access token to be refreshed yet")
+	}
+
+	current, err := navigaid.GetAuth(ctx)
+	if err != nil {
+		t.Fatalf("expected the context to still be authenticated: %v", err)
+	}
+
+	if current.AccessToken != initial.AccessToken {
+		t.Fatal("didn't expect the access token to be replaced yet")
+	}
+
+	mockServer.AdvanceTime(2 * time.Minute)
+
+	if !check() {
+		t.Fatal("expected the access token to be refreshed")
+	}
+
+	refreshed, err := navigaid.GetAuth(ctx)
+	if err != nil {
+		t.Fatalf("expected the context to still be authenticated: %v", err)
+	}
+
+	if refreshed.AccessToken == initial.AccessToken {
+		t.Error("expected the access token to have been replaced")
+	}
+}
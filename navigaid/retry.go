@@ -0,0 +1,197 @@
+package navigaid
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = 100 * time.Millisecond
+	defaultRetryMaxBackoff     = 2 * time.Second
+)
+
+// RetryTransport is an http.RoundTripper that retries idempotent
+// requests that fail with connection errors or 502/503/504 responses,
+// using exponential backoff between attempts.
+type RetryTransport struct {
+	// Base is the RoundTripper used to make HTTP requests. If nil,
+	// a Transport based on http.DefaultTransport is used.
+	Base http.RoundTripper
+	// MaxAttempts caps the total number of attempts, including the
+	// first one. Defaults to 3.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, it's
+	// doubled for every subsequent retry. Defaults to 100ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts. Defaults to 2s.
+	MaxBackoff time.Duration
+}
+
+// NewRetryingHTTPClient creates a HTTP client that authenticates
+// outgoing requests using the NavigaID context and retries transient
+// failures.
+func NewRetryingHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &RetryTransport{
+			Base: &Transport{
+				Base: http.DefaultTransport,
+			},
+		},
+	}
+}
+
+// RoundTrip performs the request, retrying it if it's idempotent and
+// the failure looks transient.
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isIdempotent(req) {
+		res, err := t.base().RoundTrip(req)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		return res, nil
+	}
+
+	var getBody func() (io.ReadCloser, error)
+
+	if req.Body != nil && req.Body != http.NoBody {
+		gb, err := bufferBody(req)
+		if err != nil {
+			return nil, err
+		}
+
+		getBody = gb
+	}
+
+	maxAttempts := t.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultRetryMaxAttempts
+	}
+
+	backoff := t.InitialBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryInitialBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+
+		if getBody != nil {
+			body, err := getBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+
+			attemptReq = cloneRequest(req)
+			attemptReq.Body = body
+		}
+
+		res, err := t.base().RoundTrip(attemptReq)
+
+		if attempt >= maxAttempts || !shouldRetry(res, err) {
+			if err != nil {
+				return nil, fmt.Errorf("%w", err)
+			}
+
+			return res, nil
+		}
+
+		if res != nil {
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+		}
+
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+
+			return nil, fmt.Errorf("%w", req.Context().Err())
+		case <-timer.C:
+		}
+
+		backoff = t.nextBackoff(backoff)
+	}
+}
+
+func (t *RetryTransport) nextBackoff(current time.Duration) time.Duration {
+	maxBackoff := t.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+
+	next := current * 2
+	if next > maxBackoff {
+		return maxBackoff
+	}
+
+	return next
+}
+
+func (t *RetryTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+
+	return &Transport{}
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func shouldRetry(res *http.Response, err error) bool {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+
+		var netErr net.Error
+
+		return errors.As(err, &netErr)
+	}
+
+	switch res.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// bufferBody returns a function that can be used to get a fresh copy
+// of the request body for every attempt.
+func bufferBody(req *http.Request) (func() (io.ReadCloser, error), error) {
+	if req.GetBody != nil {
+		_ = req.Body.Close()
+
+		return req.GetBody, nil
+	}
+
+	data, err := io.ReadAll(req.Body)
+
+	_ = req.Body.Close()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to buffer request body: %w", err)
+	}
+
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}, nil
+}
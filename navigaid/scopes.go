@@ -0,0 +1,60 @@
+package navigaid
+
+import "strings"
+
+// ScopeConvention describes how permissions are encoded as flat scope
+// strings, f.ex. "org:<permission>" and "unit:<unit>:<permission>".
+type ScopeConvention struct {
+	OrgPrefix  string
+	UnitPrefix string
+	Separator  string
+}
+
+// DefaultScopeConvention returns the "org:<permission>" and
+// "unit:<unit>:<permission>" convention.
+func DefaultScopeConvention() ScopeConvention {
+	return ScopeConvention{
+		OrgPrefix:  "org",
+		UnitPrefix: "unit",
+		Separator:  ":",
+	}
+}
+
+// ParseScopedPermissions parses scope strings that follow the default
+// scope convention into a permissions claim.
+func ParseScopedPermissions(scopes []string) PermissionsClaim {
+	return DefaultScopeConvention().Parse(scopes)
+}
+
+// Parse interprets scope strings into a permissions claim. Scopes
+// that don't follow the convention are ignored.
+func (sc ScopeConvention) Parse(scopes []string) PermissionsClaim {
+	claim := PermissionsClaim{
+		Units: make(map[string][]string),
+	}
+
+	orgPrefix := sc.OrgPrefix + sc.Separator
+	unitPrefix := sc.UnitPrefix + sc.Separator
+
+	for _, scope := range scopes {
+		if perm, ok := strings.CutPrefix(scope, orgPrefix); ok && perm != "" {
+			claim.Org = append(claim.Org, perm)
+
+			continue
+		}
+
+		rest, ok := strings.CutPrefix(scope, unitPrefix)
+		if !ok {
+			continue
+		}
+
+		unit, perm, ok := strings.Cut(rest, sc.Separator)
+		if !ok || unit == "" || perm == "" {
+			continue
+		}
+
+		claim.Units[unit] = append(claim.Units[unit], perm)
+	}
+
+	return claim
+}
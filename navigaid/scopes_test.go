@@ -0,0 +1,55 @@
+package navigaid_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/navigacontentlab/panurge/v2/navigaid"
+)
+
+func TestParseScopedPermissions(t *testing.T) {
+	got := navigaid.ParseScopedPermissions([]string{
+		"org:permission-to-kill",
+		"unit:mi6:read-files",
+		"unit:mi6:access-building",
+		"unit:mi5:access-building",
+		"unit:broken",
+		"openid",
+	})
+
+	want := navigaid.PermissionsClaim{
+		Org: []string{"permission-to-kill"},
+		Units: map[string][]string{
+			"mi6": {"read-files", "access-building"},
+			"mi5": {"access-building"},
+		},
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("permissions mismatch (-want +got):\n%s", diff)
+	}
+
+	claims := navigaid.Claims{Permissions: got}
+	if !claims.HasPermissionsInUnit("mi6", "permission-to-kill", "read-files") {
+		t.Error("expected the parsed permissions to grant access")
+	}
+
+	custom := navigaid.ScopeConvention{
+		OrgPrefix:  "o",
+		UnitPrefix: "u",
+		Separator:  "/",
+	}
+
+	got = custom.Parse([]string{"o/admin", "u/mi6/read-files"})
+
+	want = navigaid.PermissionsClaim{
+		Org: []string{"admin"},
+		Units: map[string][]string{
+			"mi6": {"read-files"},
+		},
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("custom permissions mismatch (-want +got):\n%s", diff)
+	}
+}
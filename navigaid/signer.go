@@ -0,0 +1,51 @@
+package navigaid
+
+import (
+	"crypto/rsa"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Signer signs tokens with a private RSA key, so that services can
+// mint their own tokens. Publish the matching public key using
+// JWKSHandler and Signer.PublicKey().
+type Signer struct {
+	key   *rsa.PrivateKey
+	keyID string
+}
+
+// NewSigner creates a signer that signs tokens using RS512.
+func NewSigner(key *rsa.PrivateKey, keyID string) *Signer {
+	return &Signer{
+		key:   key,
+		keyID: keyID,
+	}
+}
+
+// Sign creates a signed token with the given claims.
+func (s *Signer) Sign(claims Claims) (string, error) {
+	return signToken(claims, s.key, s.keyID)
+}
+
+// PublicKey returns the public key that can be used to verify the
+// signed tokens.
+func (s *Signer) PublicKey() PublicKey {
+	return PublicKey{
+		KeyID: s.keyID,
+		Key:   &s.key.PublicKey,
+	}
+}
+
+func signToken(claims jwt.Claims, key *rsa.PrivateKey, keyID string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS512, claims)
+
+	token.Header["kid"] = keyID
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return signed, nil
+}
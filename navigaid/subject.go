@@ -0,0 +1,67 @@
+package navigaid
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidSubject is returned when a URL-style subject is missing
+// its type or ID.
+var ErrInvalidSubject = errors.New("invalid subject")
+
+// SubjectIdentity is the structured form of a token subject.
+//
+// URL-style subjects like "hms-govt://agent/007" are split into the
+// prefix "hms-govt", the type "agent" and the ID "007". Everything
+// after the type is treated as the ID, so "hms-govt://agent/007/bond"
+// has the ID "007/bond". Other subjects, like plain UUIDs, only have
+// an ID.
+type SubjectIdentity struct {
+	Prefix string
+	Type   string
+	ID     string
+}
+
+// IsURL returns true if the identity was parsed from a URL-style
+// subject.
+func (si SubjectIdentity) IsURL() bool {
+	return si.Prefix != ""
+}
+
+// String returns the subject the identity was parsed from.
+func (si SubjectIdentity) String() string {
+	if !si.IsURL() {
+		return si.ID
+	}
+
+	return si.Prefix + "://" + si.Type + "/" + si.ID
+}
+
+// ParseSubject parses a token subject. Subjects that aren't URL-style
+// are returned untouched as the ID of the identity.
+func ParseSubject(sub string) (SubjectIdentity, error) {
+	prefix, rest, ok := strings.Cut(sub, "://")
+	if !ok {
+		return SubjectIdentity{ID: sub}, nil
+	}
+
+	subType, id, _ := strings.Cut(rest, "/")
+
+	if prefix == "" || subType == "" || id == "" {
+		return SubjectIdentity{}, fmt.Errorf(
+			"%w: %q must have the format prefix://type/id",
+			ErrInvalidSubject, sub)
+	}
+
+	return SubjectIdentity{
+		Prefix: prefix,
+		Type:   subType,
+		ID:     id,
+	}, nil
+}
+
+// SubjectIdentity parses the subject of the claims, see ParseSubject.
+func (c Claims) SubjectIdentity() (SubjectIdentity, error) {
+	return ParseSubject(c.Subject)
+}
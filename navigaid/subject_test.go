@@ -0,0 +1,84 @@
+package navigaid_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/navigacontentlab/panurge/v2/navigaid"
+)
+
+func TestParseSubject(t *testing.T) {
+	samples := map[string]struct {
+		Subject string
+		Want    navigaid.SubjectIdentity
+		WantErr bool
+	}{
+		"URL": {
+			Subject: "hms-govt://agent/007",
+			Want: navigaid.SubjectIdentity{
+				Prefix: "hms-govt", Type: "agent", ID: "007",
+			},
+		},
+		"NestedID": {
+			Subject: "hms-govt://agent/007/roger-moore",
+			Want: navigaid.SubjectIdentity{
+				Prefix: "hms-govt", Type: "agent", ID: "007/roger-moore",
+			},
+		},
+		"UUID": {
+			Subject: "0b5cc8a4-70b5-4c4b-9bb6-3c2a4b1f1d5e",
+			Want: navigaid.SubjectIdentity{
+				ID: "0b5cc8a4-70b5-4c4b-9bb6-3c2a4b1f1d5e",
+			},
+		},
+		"MissingID":     {Subject: "hms-govt://agent", WantErr: true},
+		"MissingType":   {Subject: "hms-govt:///007", WantErr: true},
+		"MissingPrefix": {Subject: "://agent/007", WantErr: true},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			got, err := navigaid.ParseSubject(tc.Subject)
+
+			if tc.WantErr {
+				if !errors.Is(err, navigaid.ErrInvalidSubject) {
+					t.Fatalf("expected an invalid subject error, got: %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to parse subject: %v", err)
+			}
+
+			if got != tc.Want {
+				t.Errorf("wanted %#v, got %#v", tc.Want, got)
+			}
+
+			if got.String() != tc.Subject {
+				t.Errorf("wanted the string %q, got %q", tc.Subject, got.String())
+			}
+		})
+	}
+}
+
+func TestClaims_SubjectIdentity(t *testing.T) {
+	claims := navigaid.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject: "hms-govt://cleaner/101",
+		},
+	}
+
+	id, err := claims.SubjectIdentity()
+	if err != nil {
+		t.Fatalf("failed to get subject identity: %v", err)
+	}
+
+	if id.Type != "cleaner" || id.ID != "101" {
+		t.Errorf("unexpected identity: %#v", id)
+	}
+}
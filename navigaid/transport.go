@@ -3,6 +3,9 @@ package navigaid
 import (
 	"fmt"
 	"net/http"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"golang.org/x/oauth2"
 )
 
 func NewHTTPClient() *http.Client {
@@ -19,10 +22,21 @@ type Transport struct {
 	// Base is the base RoundTripper used to make HTTP requests.
 	// If nil, http.DefaultTransport is used.
 	Base http.RoundTripper
+	// PropagateDeadline adds the remaining time until the context
+	// deadline as a TimeoutHeader to outgoing requests.
+	PropagateDeadline bool
+	// TokenSource is used to get the access token when set, so
+	// that the token can be refreshed transparently. It takes
+	// precedence over the authentication information in the
+	// request context, which is ignored.
+	TokenSource oauth2.TokenSource
 }
 
 // RoundTrip authorizes and authenticates the request with an
-// access token from Transport's Source.
+// access token from the TokenSource, or from the request context if
+// there is no TokenSource. The XRay trace header of the segment in
+// the request context is added so that downstream services continue
+// the trace, unless XRay is disabled.
 func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	reqBodyClosed := false
 
@@ -34,13 +48,21 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 		}()
 	}
 
-	auth, err := GetAuth(req.Context())
+	accessToken, err := t.accessToken(req)
 	if err != nil {
-		return nil, fmt.Errorf("no authentication information in context: %w", err)
+		return nil, err
 	}
 
 	req2 := cloneRequest(req) // per RoundTripper contract
-	req2.Header.Set("Authorization", "Bearer "+auth.AccessToken)
+	req2.Header.Set("Authorization", "Bearer "+accessToken)
+
+	addTraceHeader(req2)
+
+	if t.PropagateDeadline && req2.Header.Get(TimeoutHeader) == "" {
+		if timeout, ok := TimeoutFromContext(req.Context()); ok {
+			req2.Header.Set(TimeoutHeader, timeout)
+		}
+	}
 
 	// req.Body is assumed to be closed by the base RoundTripper.
 	reqBodyClosed = true
@@ -53,6 +75,37 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return trip, nil
 }
 
+func (t *Transport) accessToken(req *http.Request) (string, error) {
+	if t.TokenSource != nil {
+		token, err := t.TokenSource.Token()
+		if err != nil {
+			return "", fmt.Errorf("failed to get token from source: %w", err)
+		}
+
+		return token.AccessToken, nil
+	}
+
+	auth, err := GetAuth(req.Context())
+	if err != nil {
+		return "", fmt.Errorf("failed to get authentication information: %w", err)
+	}
+
+	return auth.AccessToken, nil
+}
+
+func addTraceHeader(req *http.Request) {
+	if xray.SdkDisabled() || req.Header.Get(xray.TraceIDHeaderKey) != "" {
+		return
+	}
+
+	seg := xray.GetSegment(req.Context())
+	if seg == nil {
+		return
+	}
+
+	req.Header.Set(xray.TraceIDHeaderKey, seg.DownstreamHeader().String())
+}
+
 func (t *Transport) base() http.RoundTripper {
 	if t.Base != nil {
 		return t.Base
@@ -2,11 +2,16 @@ package navigaid_test
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-xray-sdk-go/xray"
 	"github.com/navigacontentlab/panurge/v2/navigaid"
+	"golang.org/x/oauth2"
 )
 
 func TestTransport(t *testing.T) {
@@ -46,3 +51,239 @@ func TestTransport(t *testing.T) {
 		t.Fatalf("error response from server: %s", res.Status)
 	}
 }
+
+func TestRetryTransport(t *testing.T) {
+	token := "abc123"
+
+	var attempts int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+
+		body, err := io.ReadAll(req.Body)
+		if err != nil || string(body) != "payload" {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		if req.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client := server.Client()
+	client.Transport = &navigaid.RetryTransport{
+		Base: &navigaid.Transport{
+			Base: client.Transport,
+		},
+		InitialBackoff: time.Millisecond,
+	}
+
+	ctx := navigaid.SetAuth(context.Background(), navigaid.AuthInfo{
+		AccessToken: token,
+	}, nil)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, server.URL,
+		io.NopCloser(strings.NewReader("payload")))
+	if err != nil {
+		t.Fatalf("failed to create test request: %v", err)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("failed to perform test request: %v", err)
+	}
+
+	_ = res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("error response from server: %s", res.Status)
+	}
+
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+
+	t.Run("NonIdempotent", func(t *testing.T) {
+		attempts = 0
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL,
+			strings.NewReader("payload"))
+		if err != nil {
+			t.Fatalf("failed to create test request: %v", err)
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("failed to perform test request: %v", err)
+		}
+
+		_ = res.Body.Close()
+
+		if res.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("expected the failure to be returned, got: %s", res.Status)
+		}
+
+		if attempts != 1 {
+			t.Fatalf("expected 1 attempt, got %d", attempts)
+		}
+	})
+}
+
+func TestTransport_PropagateDeadline(t *testing.T) {
+	var timeout string
+
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		timeout = req.Header.Get(navigaid.TimeoutHeader)
+	}))
+
+	t.Cleanup(server.Close)
+
+	client := server.Client()
+	client.Transport = &navigaid.Transport{
+		Base:              client.Transport,
+		PropagateDeadline: true,
+	}
+
+	ctx := navigaid.SetAuth(context.Background(), navigaid.AuthInfo{
+		AccessToken: "abc123",
+	}, nil)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create test request: %v", err)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("failed to perform test request: %v", err)
+	}
+
+	_ = res.Body.Close()
+
+	remaining, err := navigaid.ParseTimeout(timeout)
+	if err != nil {
+		t.Fatalf("expected a valid timeout header, got %q: %v", timeout, err)
+	}
+
+	if remaining <= 0 || remaining > time.Minute {
+		t.Errorf("expected the remaining time to be within a minute, got %v", remaining)
+	}
+}
+
+type rotatingSource struct {
+	tokens []string
+	calls  int
+}
+
+func (s *rotatingSource) Token() (*oauth2.Token, error) {
+	token := s.tokens[s.calls%len(s.tokens)]
+
+	s.calls++
+
+	return &oauth2.Token{AccessToken: token}, nil
+}
+
+func TestTransport_TokenSource(t *testing.T) {
+	var got []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		got = append(got, req.Header.Get("Authorization"))
+	}))
+
+	t.Cleanup(server.Close)
+
+	client := server.Client()
+	client.Transport = &navigaid.Transport{
+		Base:        client.Transport,
+		TokenSource: &rotatingSource{tokens: []string{"first", "second"}},
+	}
+
+	// The token source takes precedence over the context.
+	ctx := navigaid.SetAuth(context.Background(), navigaid.AuthInfo{
+		AccessToken: "from-context",
+	}, nil)
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatalf("failed to create test request: %v", err)
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("failed to perform test request: %v", err)
+		}
+
+		_ = res.Body.Close()
+	}
+
+	want := "Bearer first,Bearer second"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("wanted the authorization headers %q, got %q", want, got)
+	}
+}
+
+func TestTransport_TraceHeader(t *testing.T) {
+	var traceHeader string
+
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		traceHeader = req.Header.Get(xray.TraceIDHeaderKey)
+	}))
+
+	t.Cleanup(server.Close)
+
+	client := server.Client()
+	client.Transport = &navigaid.Transport{
+		Base: client.Transport,
+	}
+
+	do := func(ctx context.Context) {
+		t.Helper()
+
+		ctx = navigaid.SetAuth(ctx, navigaid.AuthInfo{
+			AccessToken: "abc123",
+		}, nil)
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatalf("failed to create test request: %v", err)
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("failed to perform test request: %v", err)
+		}
+
+		_ = res.Body.Close()
+	}
+
+	do(context.Background())
+
+	if traceHeader != "" {
+		t.Errorf("expected no trace header without a segment, got %q", traceHeader)
+	}
+
+	ctx, seg := xray.BeginSegment(context.Background(), "transport-test")
+	defer seg.Close(nil)
+
+	do(ctx)
+
+	if !strings.Contains(traceHeader, "Root="+seg.TraceID) {
+		t.Errorf("expected the trace header to continue the trace %q, got %q",
+			seg.TraceID, traceHeader)
+	}
+}
@@ -0,0 +1,89 @@
+package navigaid
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// UserinfoEndpoint is a helper function that returns the v1 userinfo
+// endpoint URL given an URL that points to the access token service.
+func UserinfoEndpoint(serviceURL string) string {
+	return fmt.Sprintf("%s/v1/userinfo", strings.TrimSuffix(serviceURL, "/"))
+}
+
+type UserinfoServiceOption func(us *UserinfoService)
+
+// WithUserinfoClient sets the HTTP client that should be used for
+// userinfo requests.
+func WithUserinfoClient(client *http.Client) UserinfoServiceOption {
+	return func(us *UserinfoService) {
+		us.client = client
+	}
+}
+
+// UserinfoService fetches up to date user profile information for
+// the holder of an access token.
+type UserinfoService struct {
+	client           *http.Client
+	userinfoEndpoint string
+}
+
+// NewUserinfoService creates a new userinfo service with given
+// options.
+func NewUserinfoService(userinfoEndpoint string, options ...UserinfoServiceOption) *UserinfoService {
+	us := UserinfoService{
+		userinfoEndpoint: userinfoEndpoint,
+	}
+
+	for _, o := range options {
+		o(&us)
+	}
+
+	if us.client == nil {
+		us.client = http.DefaultClient
+	}
+
+	return &us
+}
+
+// GetUserinfo fetches the userinfo for the holder of the access
+// token.
+func (us *UserinfoService) GetUserinfo(ctx context.Context, accessToken string) (Userinfo, error) {
+	var info Userinfo
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, us.userinfoEndpoint, nil)
+	if err != nil {
+		return info, fmt.Errorf("failed to create userinfo request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res, err := us.client.Do(req)
+	if err != nil {
+		return info, fmt.Errorf("failed to perform userinfo request: %w", err)
+	}
+
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+
+		return info, fmt.Errorf("userinfo request failed with status %d: %s",
+			res.StatusCode, string(body))
+	}
+
+	dec := json.NewDecoder(res.Body)
+
+	err = dec.Decode(&info)
+	if err != nil {
+		return info, fmt.Errorf("failed to decode userinfo response: %w", err)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,112 @@
+package navigaid_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/navigacontentlab/panurge/v2/navigaid"
+)
+
+func TestUserinfoService(t *testing.T) {
+	mockServer, err := navigaid.NewMockServer(navigaid.MockServerOptions{
+		Claims: navigaid.Claims{
+			Org: "sampleorg",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject: "75255a64-58f8-4b25-b102-af1304641096",
+			},
+			Userinfo: navigaid.Userinfo{
+				GivenName:  "Jane",
+				FamilyName: "Doe",
+				Email:      "jane.doe@example.com",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(mockServer.Server.Close)
+
+	tokens := navigaid.New(
+		navigaid.AccessTokenEndpoint(mockServer.Server.URL),
+		navigaid.WithAccessTokenClient(mockServer.Client),
+	)
+
+	userinfo := navigaid.NewUserinfoService(
+		navigaid.UserinfoEndpoint(mockServer.Server.URL),
+		navigaid.WithUserinfoClient(mockServer.Client),
+	)
+
+	resp, err := tokens.NewAccessToken("testNavigaIDToken")
+	if err != nil {
+		t.Fatalf("failed to exchange ID token for an access token: %v", err)
+	}
+
+	ctx := context.Background()
+
+	info, err := userinfo.GetUserinfo(ctx, resp.AccessToken)
+	if err != nil {
+		t.Fatalf("failed to get userinfo: %v", err)
+	}
+
+	if info.GivenName != "Jane" || info.Email != "jane.doe@example.com" {
+		t.Errorf("unexpected userinfo: %#v", info)
+	}
+
+	_, err = userinfo.GetUserinfo(ctx, "not-a-token")
+	if err == nil {
+		t.Error("expected userinfo request with an invalid token to fail")
+	}
+}
+
+func TestUserinfoService__MockClock(t *testing.T) {
+	mockServer, err := navigaid.NewMockServer(navigaid.MockServerOptions{
+		Claims: navigaid.Claims{
+			Org: "sampleorg",
+			Userinfo: navigaid.Userinfo{
+				GivenName: "Jane",
+			},
+		},
+		TTL: 600,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(mockServer.Server.Close)
+
+	mockServer.SetNow(func() time.Time {
+		return time.Date(2020, time.April, 7, 9, 0, 0, 0, time.UTC)
+	})
+
+	tokens := navigaid.New(
+		navigaid.AccessTokenEndpoint(mockServer.Server.URL),
+		navigaid.WithAccessTokenClient(mockServer.Client),
+	)
+
+	userinfo := navigaid.NewUserinfoService(
+		navigaid.UserinfoEndpoint(mockServer.Server.URL),
+		navigaid.WithUserinfoClient(mockServer.Client),
+	)
+
+	resp, err := tokens.NewAccessToken("testNavigaIDToken")
+	if err != nil {
+		t.Fatalf("failed to exchange ID token for an access token: %v", err)
+	}
+
+	ctx := context.Background()
+
+	_, err = userinfo.GetUserinfo(ctx, resp.AccessToken)
+	if err != nil {
+		t.Fatalf("expected the token to be valid at the mock time: %v", err)
+	}
+
+	mockServer.AdvanceTime(11 * time.Minute)
+
+	_, err = userinfo.GetUserinfo(ctx, resp.AccessToken)
+	if err == nil {
+		t.Error("expected the token to have expired at the mock time")
+	}
+}
@@ -0,0 +1,14 @@
+package navigaid
+
+// Validator validates tokens and returns their claims.
+type Validator interface {
+	// Validate validates an access token.
+	Validate(accessToken string) (Claims, error)
+	// ValidateToken validates a token of the given token type.
+	ValidateToken(token string, tokenType string) (Claims, error)
+}
+
+var (
+	_ Validator = &JWKS{}
+	_ Validator = &HMACValidator{}
+)
@@ -0,0 +1,144 @@
+package panurge
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+)
+
+// Middleware wraps a HTTP handler.
+type Middleware func(next http.Handler) http.Handler
+
+// MiddlewareStage names a position in the middleware pipeline of the
+// public server of a StandardApp.
+type MiddlewareStage string
+
+// The stages of the public middleware pipeline, in the order that
+// they see a request:
+//
+//   - StageInFlight counts the requests in flight for draining.
+//   - StageConcurrencyLimit rejects requests over the concurrency
+//     limit, see WithAppMaxConcurrency.
+//   - StageTracing starts the XRay segment and adds annotation
+//     support to the context.
+//   - StageClientIP annotates the client IP, see WithAppClientIP.
+//   - StageRequestSummary logs the request summary, see
+//     WithAppRequestSummary.
+//
+// After the last stage the request is routed by the mux, and CORS,
+// Twirp header forwarding and the Twirp hooks are applied per
+// service.
+const (
+	StageInFlight         MiddlewareStage = "in_flight"
+	StageConcurrencyLimit MiddlewareStage = "concurrency_limit"
+	StageTracing          MiddlewareStage = "tracing"
+	StageClientIP         MiddlewareStage = "client_ip"
+	StageRequestSummary   MiddlewareStage = "request_summary"
+)
+
+var middlewareStages = []MiddlewareStage{
+	StageInFlight,
+	StageConcurrencyLimit,
+	StageTracing,
+	StageClientIP,
+	StageRequestSummary,
+}
+
+// WithAppMiddleware adds a middleware to the public server directly
+// after the given stage, so that it sees requests after the stage's
+// own middleware, f.ex. after StageTracing to be able to use
+// annotations. The position is kept even if the stage itself isn't
+// enabled. Middlewares added to the same stage run in the order they
+// were added.
+func WithAppMiddleware(after MiddlewareStage, mw Middleware) StandardAppOption {
+	return func(app *StandardApp) {
+		if app.middleware == nil {
+			app.middleware = make(map[MiddlewareStage][]Middleware)
+		}
+
+		app.middleware[after] = append(app.middleware[after], mw)
+	}
+}
+
+// buildPublicHandler assembles the middleware pipeline for the public
+// server around the mux, see the MiddlewareStage constants for the
+// order.
+func (app *StandardApp) buildPublicHandler(mux http.Handler) (http.Handler, error) {
+	for stage := range app.middleware {
+		if !isMiddlewareStage(stage) {
+			return nil, fmt.Errorf("unknown middleware stage %q", stage)
+		}
+	}
+
+	standard, err := app.standardMiddleware()
+	if err != nil {
+		return nil, err
+	}
+
+	var pipeline []Middleware
+
+	for _, stage := range middlewareStages {
+		if mw, ok := standard[stage]; ok {
+			pipeline = append(pipeline, mw)
+		}
+
+		pipeline = append(pipeline, app.middleware[stage]...)
+	}
+
+	handler := mux
+
+	for i := len(pipeline) - 1; i >= 0; i-- {
+		handler = pipeline[i](handler)
+	}
+
+	return handler, nil
+}
+
+// standardMiddleware returns the standard middleware for the stages
+// that are enabled.
+func (app *StandardApp) standardMiddleware() (map[MiddlewareStage]Middleware, error) {
+	standard := map[MiddlewareStage]Middleware{
+		StageInFlight: app.inFlight.Middleware,
+		StageTracing: func(next http.Handler) http.Handler {
+			return xray.Handler(
+				xray.NewFixedSegmentNamer(app.name),
+				AnnotationMiddleware(next),
+			)
+		},
+	}
+
+	if app.limitConcurrency {
+		limiter, err := NewConcurrencyLimiter(
+			app.concurrency, app.metricsOpts...)
+		if err != nil {
+			return nil, err
+		}
+
+		standard[StageConcurrencyLimit] = limiter.Middleware
+	}
+
+	if app.clientIP {
+		standard[StageClientIP] = func(next http.Handler) http.Handler {
+			return ClientIPMiddleware(app.proxyHops, next, app.clientIPOpts...)
+		}
+	}
+
+	if app.summaryLog {
+		standard[StageRequestSummary] = func(next http.Handler) http.Handler {
+			return RequestSummaryMiddleware(app.logger, next)
+		}
+	}
+
+	return standard, nil
+}
+
+func isMiddlewareStage(stage MiddlewareStage) bool {
+	for _, s := range middlewareStages {
+		if s == stage {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,105 @@
+package pt
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	panurge "github.com/navigacontentlab/panurge/v2"
+	"github.com/navigacontentlab/panurge/v2/navigaid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// AppHarness is a StandardApp running on test servers with a
+// NavigaID mock server for authentication.
+type AppHarness struct {
+	App *panurge.StandardApp
+
+	t       *testing.T
+	servers *panurge.TestServers
+	signer  *navigaid.Signer
+}
+
+// StartApp creates a StandardApp that uses test servers and trusts a
+// NavigaID mock server. The servers are closed when the test ends.
+//
+// Metrics are registered with a new registry so that several apps
+// can be started in the same test binary. Note that passing
+// panurge.WithTwirpMetricsOptions replaces the registry, so include
+// a registerer in the options when using it.
+func StartApp(
+	t *testing.T, logger *slog.Logger, name string, opts ...panurge.StandardAppOption,
+) *AppHarness {
+	t.Helper()
+
+	servers := panurge.TestServers{
+		MockNavigaID: &navigaid.MockServerOptions{},
+	}
+
+	appOpts := make([]panurge.StandardAppOption, 0, len(opts)+2)
+	appOpts = append(appOpts, panurge.WithTwirpMetricsOptions(
+		panurge.WithTwirpMetricsRegisterer(prometheus.NewRegistry()),
+	))
+	appOpts = append(appOpts, opts...)
+	appOpts = append(appOpts, panurge.WithAppTestServers(&servers))
+
+	app, err := panurge.NewStandardApp(logger, name, appOpts...)
+	Must(t, err, "failed to create application")
+
+	t.Cleanup(servers.Close)
+
+	err = app.ListenAndServe()
+	Must(t, err, "failed to start application")
+
+	mock := servers.NavigaID()
+
+	return &AppHarness{
+		App:     app,
+		t:       t,
+		servers: &servers,
+		signer:  navigaid.NewSigner(mock.PrivateKey, mock.PrivateKeyID),
+	}
+}
+
+// PublicURL returns the URL of the public server.
+func (h *AppHarness) PublicURL() string {
+	return h.servers.GetPublic().URL
+}
+
+// InternalURL returns the URL of the internal server.
+func (h *AppHarness) InternalURL() string {
+	return h.servers.GetInternal().URL
+}
+
+// NavigaID returns the NavigaID mock server that the application
+// trusts.
+func (h *AppHarness) NavigaID() *navigaid.MockServer {
+	return h.servers.NavigaID()
+}
+
+// TokenFor creates an access token with the claims that the
+// application will accept. The token type and expiry default to an
+// access token that's valid for ten minutes.
+func (h *AppHarness) TokenFor(claims navigaid.Claims) string {
+	h.t.Helper()
+
+	now := h.NavigaID().Now()
+
+	if claims.TokenType == "" {
+		claims.TokenType = navigaid.TokenTypeAccessToken
+	}
+
+	if claims.ExpiresAt == nil {
+		claims.ExpiresAt = jwt.NewNumericDate(now.Add(10 * time.Minute))
+	}
+
+	if claims.IssuedAt == nil {
+		claims.IssuedAt = jwt.NewNumericDate(now)
+	}
+
+	token, err := h.signer.Sign(claims)
+	Must(h.t, err, "failed to sign token")
+
+	return token
+}
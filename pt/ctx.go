@@ -3,6 +3,9 @@ package pt
 import (
 	"context"
 	"testing"
+
+	panurge "github.com/navigacontentlab/panurge/v2"
+	"github.com/navigacontentlab/panurge/v2/navigaid"
 )
 
 func TestContext(t *testing.T) context.Context {
@@ -22,3 +25,21 @@ func TestContext(t *testing.T) context.Context {
 
 	return ctx
 }
+
+// AuthedContext returns a test context with annotations and
+// authentication information for the given claims, so that handlers
+// can be called directly without the HTTP and Twirp stack. The user
+// and "imid_org" annotations are set like the standard auth hook
+// does.
+func AuthedContext(t *testing.T, claims navigaid.Claims) context.Context {
+	t.Helper()
+
+	ctx := panurge.ContextWithAnnotations(TestContext(t))
+
+	panurge.AddUserAnnotation(ctx, claims.Subject)
+	panurge.AddAnnotation(ctx, "imid_org", claims.Org)
+
+	return navigaid.SetAuth(ctx, navigaid.AuthInfo{
+		Claims: claims,
+	}, nil)
+}
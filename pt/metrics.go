@@ -0,0 +1,111 @@
+package pt
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// MetricCount is an expected count for the series of a metric that
+// have the given labels.
+type MetricCount struct {
+	Labels map[string]string
+	Count  float64
+}
+
+// ExpectMetricCounts gathers the named metric and compares the counts
+// for the label sets. Labels that aren't listed are ignored and the
+// counts of all matching series are summed, so a label set without
+// "organisation" matches the series of all organisations. Counters
+// and gauges are compared by value, and histograms and summaries by
+// their sample count, ignoring the bucket boundaries.
+func ExpectMetricCounts(
+	t *testing.T, gatherer prometheus.Gatherer, name string, want ...MetricCount,
+) {
+	t.Helper()
+
+	families, err := gatherer.Gather()
+	Must(t, err, "failed to gather metrics")
+
+	var family *dto.MetricFamily
+
+	for _, f := range families {
+		if f.GetName() == name {
+			family = f
+
+			break
+		}
+	}
+
+	if family == nil {
+		t.Errorf("the metric %q wasn't gathered", name)
+
+		return
+	}
+
+	for _, w := range want {
+		var got float64
+
+		for _, m := range family.GetMetric() {
+			if hasLabels(m, w.Labels) {
+				got += metricCount(m)
+			}
+		}
+
+		if got != w.Count {
+			t.Errorf("wanted the count %v for %s%s, got %v",
+				w.Count, name, formatLabels(w.Labels), got)
+		}
+	}
+}
+
+func hasLabels(m *dto.Metric, labels map[string]string) bool {
+	matched := 0
+
+	for _, lp := range m.GetLabel() {
+		value, ok := labels[lp.GetName()]
+		if !ok {
+			continue
+		}
+
+		if value != lp.GetValue() {
+			return false
+		}
+
+		matched++
+	}
+
+	return matched == len(labels)
+}
+
+func metricCount(m *dto.Metric) float64 {
+	switch {
+	case m.Counter != nil:
+		return m.Counter.GetValue()
+	case m.Gauge != nil:
+		return m.Gauge.GetValue()
+	case m.Histogram != nil:
+		return float64(m.Histogram.GetSampleCount())
+	case m.Summary != nil:
+		return float64(m.Summary.GetSampleCount())
+	case m.Untyped != nil:
+		return m.Untyped.GetValue()
+	}
+
+	return 0
+}
+
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+
+	sort.Strings(pairs)
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -27,8 +27,41 @@ func ExpectTwirpInvalidArgument(t *testing.T, err error, argument string) {
 	}
 }
 
+func ExpectTwirpNotFound(t *testing.T, err error, resource string) {
+	t.Helper()
+
+	expectTwirpResourceError(t, err, twirp.NotFound, resource)
+}
+
+func ExpectTwirpAlreadyExists(t *testing.T, err error, resource string) {
+	t.Helper()
+
+	expectTwirpResourceError(t, err, twirp.AlreadyExists, resource)
+}
+
+func expectTwirpResourceError(t testing.TB, err error, code twirp.ErrorCode, resource string) {
+	t.Helper()
+
+	te, ok := checkTwirpErrorCode(t, err, code)
+	if !ok {
+		return
+	}
+
+	if te.Meta("resource") != resource {
+		t.Errorf("expected the error to be for the resource %q, got %q",
+			resource, te.Meta("resource"))
+
+		return
+	}
+
+	if testing.Verbose() {
+		t.Logf("got expected %s error for resource %q (id %q)",
+			code, resource, te.Meta("id"))
+	}
+}
+
 //nolint:ireturn
-func checkTwirpErrorCode(t *testing.T, err error, code twirp.ErrorCode) (twirp.Error, bool) {
+func checkTwirpErrorCode(t testing.TB, err error, code twirp.ErrorCode) (twirp.Error, bool) {
 	t.Helper()
 
 	if err == nil {
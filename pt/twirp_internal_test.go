@@ -0,0 +1,89 @@
+package pt
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/twitchtv/twirp"
+)
+
+// recordingT records test failures instead of failing the test.
+type recordingT struct {
+	testing.TB
+
+	failures []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Error(args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprint(args...))
+}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestExpectTwirpResourceErrors(t *testing.T) {
+	notFound := twirp.NotFoundError("no such document").
+		WithMeta("resource", "document").
+		WithMeta("id", "123")
+	exists := twirp.NewError(twirp.AlreadyExists, "document exists").
+		WithMeta("resource", "document")
+
+	samples := map[string]struct {
+		Code     twirp.ErrorCode
+		Err      error
+		Resource string
+		Fail     bool
+	}{
+		"NotFound": {
+			Code: twirp.NotFound, Err: notFound, Resource: "document",
+		},
+		"NotFoundWrongResource": {
+			Code: twirp.NotFound, Err: notFound, Resource: "user",
+			Fail: true,
+		},
+		"NotFoundWrongCode": {
+			Code: twirp.NotFound, Err: exists, Resource: "document",
+			Fail: true,
+		},
+		"NotFoundNoError": {
+			Code: twirp.NotFound, Resource: "document",
+			Fail: true,
+		},
+		"NotFoundNotTwirp": {
+			Code: twirp.NotFound, Err: errors.New("plain error"),
+			Resource: "document", Fail: true,
+		},
+		"AlreadyExists": {
+			Code: twirp.AlreadyExists, Err: exists, Resource: "document",
+		},
+		"AlreadyExistsWrongResource": {
+			Code: twirp.AlreadyExists, Err: exists, Resource: "user",
+			Fail: true,
+		},
+		"AlreadyExistsWrongCode": {
+			Code: twirp.AlreadyExists, Err: notFound, Resource: "document",
+			Fail: true,
+		},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			rec := recordingT{TB: t}
+
+			expectTwirpResourceError(&rec, tc.Err, tc.Code, tc.Resource)
+
+			switch {
+			case tc.Fail && len(rec.failures) == 0:
+				t.Fatal("expected the expectation to fail")
+			case !tc.Fail && len(rec.failures) > 0:
+				t.Fatalf("expected the expectation to pass, got %v", rec.failures)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package panurge
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// ReopenableWriter is an io.Writer for log files that reopens the
+// file when the process receives SIGHUP, so that it can be used with
+// external log rotation like logrotate.
+type ReopenableWriter struct {
+	path    string
+	onError func(err error)
+
+	m    sync.Mutex
+	file *os.File
+
+	signals   chan os.Signal
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// ReopenableWriterOption is used to configure a ReopenableWriter.
+type ReopenableWriterOption func(w *ReopenableWriter)
+
+// WithReopenErrorHandler sets a function that's called when the file
+// can't be reopened after a SIGHUP. By default the error is logged
+// using the default slog logger.
+func WithReopenErrorHandler(fn func(err error)) ReopenableWriterOption {
+	return func(w *ReopenableWriter) {
+		w.onError = fn
+	}
+}
+
+// NewReopenableWriter opens the file at path for appending and
+// reopens it on SIGHUP. Call Close to stop listening for signals and
+// close the file.
+func NewReopenableWriter(path string, opts ...ReopenableWriterOption) (*ReopenableWriter, error) {
+	w := ReopenableWriter{
+		path:    path,
+		signals: make(chan os.Signal, 1),
+		done:    make(chan struct{}),
+		onError: func(err error) {
+			slog.Error("failed to reopen log file",
+				"path", path,
+				"err", err)
+		},
+	}
+
+	for _, opt := range opts {
+		opt(&w)
+	}
+
+	err := w.Reopen()
+	if err != nil {
+		return nil, err
+	}
+
+	signal.Notify(w.signals, syscall.SIGHUP)
+
+	go w.handleSignals()
+
+	return &w, nil
+}
+
+func (w *ReopenableWriter) handleSignals() {
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.signals:
+			err := w.Reopen()
+			if err != nil {
+				w.onError(err)
+			}
+		}
+	}
+}
+
+// Reopen closes the current file and opens the file at the path
+// again.
+func (w *ReopenableWriter) Reopen() error {
+	// Log files are commonly read by other users, like log
+	// shippers.
+	//nolint:gosec
+	file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	w.m.Lock()
+	old := w.file
+	w.file = file
+	w.m.Unlock()
+
+	if old != nil {
+		_ = old.Close()
+	}
+
+	return nil
+}
+
+// Write writes to the current file.
+func (w *ReopenableWriter) Write(p []byte) (int, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	n, err := w.file.Write(p)
+	if err != nil {
+		return n, fmt.Errorf("%w", err)
+	}
+
+	return n, nil
+}
+
+// Close stops listening for SIGHUP and closes the file. It's safe to
+// call Close more than once, subsequent calls return the result of
+// the first call.
+func (w *ReopenableWriter) Close() error {
+	w.closeOnce.Do(func() {
+		signal.Stop(w.signals)
+		close(w.done)
+
+		w.m.Lock()
+		defer w.m.Unlock()
+
+		err := w.file.Close()
+		if err != nil {
+			w.closeErr = fmt.Errorf("failed to close log file: %w", err)
+		}
+	})
+
+	return w.closeErr
+}
@@ -0,0 +1,101 @@
+package panurge_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	panurge "github.com/navigacontentlab/panurge/v2"
+	"github.com/navigacontentlab/panurge/v2/pt"
+)
+
+func TestReopenableWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "service.log")
+
+	w, err := panurge.NewReopenableWriter(path)
+	pt.Must(t, err, "failed to create writer")
+
+	t.Cleanup(func() {
+		_ = w.Close()
+	})
+
+	logger := panurge.Logger("info", w)
+
+	logger.Info("before rotation")
+
+	err = os.Rename(path, path+".1")
+	pt.Must(t, err, "failed to rotate log file")
+
+	err = w.Reopen()
+	pt.Must(t, err, "failed to reopen log file")
+
+	logger.Info("after rotation")
+
+	rotated, err := os.ReadFile(path + ".1")
+	pt.Must(t, err, "failed to read rotated log file")
+
+	current, err := os.ReadFile(path)
+	pt.Must(t, err, "failed to read current log file")
+
+	if !strings.Contains(string(rotated), "before rotation") {
+		t.Errorf("expected the rotated file to contain the first entry, got %q", rotated)
+	}
+
+	if !strings.Contains(string(current), "after rotation") ||
+		strings.Contains(string(current), "before rotation") {
+		t.Errorf("expected the new file to only contain the second entry, got %q", current)
+	}
+}
+
+func TestReopenableWriter__Close(t *testing.T) {
+	w, err := panurge.NewReopenableWriter(filepath.Join(t.TempDir(), "service.log"))
+	pt.Must(t, err, "failed to create writer")
+
+	err = w.Close()
+	pt.Must(t, err, "failed to close writer")
+
+	err = w.Close()
+	pt.Must(t, err, "expected closing the writer again to be a no-op")
+}
+
+func TestReopenableWriter__ErrorHandler(t *testing.T) {
+	dir := t.TempDir()
+	logDir := filepath.Join(dir, "logs")
+
+	err := os.Mkdir(logDir, 0o700)
+	pt.Must(t, err, "failed to create log directory")
+
+	errs := make(chan error, 1)
+
+	w, err := panurge.NewReopenableWriter(filepath.Join(logDir, "service.log"),
+		panurge.WithReopenErrorHandler(func(err error) {
+			errs <- err
+		}))
+	pt.Must(t, err, "failed to create writer")
+
+	t.Cleanup(func() {
+		_ = w.Close()
+	})
+
+	// Make the reopen fail by removing the log directory.
+	err = os.RemoveAll(logDir)
+	pt.Must(t, err, "failed to remove log directory")
+
+	err = syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	pt.Must(t, err, "failed to send SIGHUP")
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected a not exists error, got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the reopen error")
+	}
+}
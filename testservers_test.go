@@ -1,11 +1,16 @@
 package panurge_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
+	"os"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -165,6 +170,9 @@ rpc_duration_bucket{method="DoThing",organisation="testorg",service="Test",le="8
 rpc_duration_bucket{method="DoThing",organisation="testorg",service="Test",le="+Inf"} 1
 rpc_duration_sum{method="DoThing",organisation="testorg",service="Test"} 1000
 rpc_duration_count{method="DoThing",organisation="testorg",service="Test"} 1
+# HELP rpc_errors_total Number of RPC error responses sent.
+# TYPE rpc_errors_total counter
+rpc_errors_total{code="unauthenticated",method="DoThing",organisation="",service="Test"} 1
 # HELP rpc_requests_total Number of RPC requests received.
 # TYPE rpc_requests_total counter
 rpc_requests_total{method="DoThing",organisation="",service="Test"} 1
@@ -176,11 +184,23 @@ rpc_responses_total{method="DoThing",organisation="testorg",service="Test",statu
 `)
 
 	err = testutil.GatherAndCompare(reg, wantMetrics,
-		"rpc_duration", "rpc_requests_total", "rpc_responses_total")
+		"rpc_duration", "rpc_errors_total", "rpc_requests_total", "rpc_responses_total")
 	if err != nil {
 		t.Errorf("didn't gather the expected metrics: %v", err)
 	}
 
+	pt.ExpectMetricCounts(t, reg, "rpc_duration",
+		pt.MetricCount{Labels: map[string]string{"method": "DoThing"}, Count: 2},
+		pt.MetricCount{Labels: map[string]string{"organisation": "testorg"}, Count: 1},
+	)
+	pt.ExpectMetricCounts(t, reg, "rpc_responses_total",
+		pt.MetricCount{Labels: map[string]string{"status": "200"}, Count: 1},
+		pt.MetricCount{Labels: map[string]string{"status": "500"}, Count: 0},
+	)
+	pt.ExpectMetricCounts(t, reg, "http_requests_in_flight",
+		pt.MetricCount{Count: 0},
+	)
+
 	err = xray.Configure(xray.Config{
 		SamplingStrategy: SamplingStrategy(false),
 	})
@@ -255,3 +275,692 @@ func (e DummyEmitter) Emit(_ *xray.Segment) {
 
 func (e DummyEmitter) RefreshEmitterWithAddress(_ *net.UDPAddr) {
 }
+
+func TestNewStandardApp__NilLogger(t *testing.T) {
+	_, err := panurge.NewStandardApp(nil, "testservice")
+	if err == nil {
+		t.Fatal("expected the application creation to fail without a logger")
+	}
+}
+
+func TestServers__InternalHandler(t *testing.T) {
+	var testServers panurge.TestServers
+
+	logger := panurge.Logger("warning", pt.NewTestLogWriter(t))
+
+	app, err := panurge.NewStandardApp(logger, "testservice",
+		panurge.WithAppTestServers(&testServers),
+		panurge.WithAppInternalHandler("/flags", http.HandlerFunc(
+			func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+			})),
+	)
+	pt.Must(t, err, "failed to create test application")
+
+	t.Cleanup(testServers.Close)
+
+	app.InternalMux.Handle("/config", http.HandlerFunc(
+		func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNonAuthoritativeInfo)
+		}))
+
+	internal := testServers.GetInternal()
+
+	for path, want := range map[string]int{
+		"/flags":  http.StatusAccepted,
+		"/config": http.StatusNonAuthoritativeInfo,
+		"/health": http.StatusOK,
+	} {
+		res, err := internal.Client().Get(internal.URL + path)
+		pt.Mustf(t, err, "failed to request %q", path)
+
+		_ = res.Body.Close()
+
+		if res.StatusCode != want {
+			t.Errorf("wanted status %d for %q, got %d", want, path, res.StatusCode)
+		}
+	}
+}
+
+func TestServers__InternalHandlerConflict(t *testing.T) {
+	logger := panurge.Logger("warning", pt.NewTestLogWriter(t))
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	samples := map[string]struct {
+		Options []panurge.StandardAppOption
+	}{
+		"Metrics": {
+			Options: []panurge.StandardAppOption{
+				panurge.WithAppInternalHandler("/metrics", handler),
+			},
+		},
+		"Ready": {
+			Options: []panurge.StandardAppOption{
+				panurge.WithAppInternalHandler("/ready", handler),
+			},
+		},
+		"Profiling": {
+			Options: []panurge.StandardAppOption{
+				panurge.WithAppInternalHandler("/debug/pprof/", handler),
+			},
+		},
+		"CustomHealthPath": {
+			Options: []panurge.StandardAppOption{
+				panurge.WithAppInternalPaths("", "/healthz"),
+				panurge.WithAppInternalHandler("/healthz", handler),
+			},
+		},
+		"Duplicate": {
+			Options: []panurge.StandardAppOption{
+				panurge.WithAppInternalHandler("/flags", handler),
+				panurge.WithAppInternalHandler("/flags", handler),
+			},
+		},
+		"EmptyPattern": {
+			Options: []panurge.StandardAppOption{
+				panurge.WithAppInternalHandler("", handler),
+			},
+		},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			var testServers panurge.TestServers
+
+			opts := append([]panurge.StandardAppOption{
+				panurge.WithAppTestServers(&testServers),
+			}, tc.Options...)
+
+			_, err := panurge.NewStandardApp(logger, "testservice", opts...)
+			if err == nil {
+				testServers.Close()
+				t.Fatal("expected the conflicting internal handler to be rejected")
+			}
+		})
+	}
+
+	var testServers panurge.TestServers
+
+	_, err := panurge.NewStandardApp(logger, "testservice",
+		panurge.WithAppTestServers(&testServers),
+		panurge.WithAppDisableProfiling(),
+		panurge.WithAppInternalHandler("/debug/pprof/", handler),
+	)
+	pt.Must(t, err, "expected the pprof path to be free when profiling is disabled")
+
+	testServers.Close()
+}
+
+func TestServers__MockNavigaID(t *testing.T) {
+	testServers := panurge.TestServers{
+		MockNavigaID: &navigaid.MockServerOptions{
+			Claims: navigaid.Claims{
+				Org: "testorg",
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject: "75255a64-58f8-4b25-b102-af1304641096",
+				},
+			},
+		},
+	}
+
+	logger := panurge.Logger("warning", pt.NewTestLogWriter(t))
+
+	_, err := panurge.NewStandardApp(logger, "testservice",
+		panurge.WithAppTestServers(&testServers),
+		panurge.WithTwirpMetricsOptions(
+			panurge.WithTwirpMetricsRegisterer(prometheus.NewPedanticRegistry()),
+		),
+		panurge.WithAppService(
+			testservice.TestPathPrefix,
+			func(hooks *twirp.ServerHooks) http.Handler {
+				return testservice.NewTestServer(&Greeter{}, hooks)
+			},
+		),
+	)
+	pt.Must(t, err, "failed to create test application")
+
+	t.Cleanup(testServers.Close)
+
+	if testServers.NavigaID() == nil {
+		t.Fatal("expected a NavigaID mock server to be available")
+	}
+
+	ctx := pt.TestContext(t)
+
+	tok, err := testServers.AccessTokenService().NewAccessToken("testNavigaIDToken")
+	pt.Must(t, err, "failed to create test token")
+
+	httpClient := oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{
+		AccessToken: tok.AccessToken,
+	}))
+
+	server := testServers.GetPublic()
+	client := testservice.NewTestProtobufClient(server.URL, httpClient)
+
+	res, err := client.DoThing(ctx, &testservice.ThingReq{
+		Name: "Horatio Hornblower",
+	})
+	pt.Must(t, err, "got error response")
+
+	want := "Hello Horatio Hornblower!"
+	if res.Response != want {
+		t.Errorf("got %q, want %q", res.Response, want)
+	}
+}
+
+func TestStandardApp__RunWithSignals(t *testing.T) {
+	var logs bytes.Buffer
+
+	logger := panurge.Logger("info", pt.NewTestLogWriter(t), &logs)
+
+	app, err := panurge.NewStandardApp(logger, "testservice",
+		panurge.WithAppPorts(0, 0),
+		panurge.WithAppShutdownTiming(100*time.Millisecond, time.Second),
+	)
+	pt.Must(t, err, "failed to create application")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- app.RunWithSignals(ctx)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		pt.Must(t, err, "expected a clean shutdown")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the application to shut down")
+	}
+
+	if _, draining := app.InFlight().Draining(); !draining {
+		t.Error("expected the application to be draining after shutdown")
+	}
+
+	for _, msg := range []string{"draining started", "drain complete", "servers stopped"} {
+		if !strings.Contains(logs.String(), `"msg":"`+msg+`"`) {
+			t.Errorf("expected the shutdown to log %q", msg)
+		}
+	}
+
+	dec := json.NewDecoder(&logs)
+
+	for dec.More() {
+		var entry struct {
+			Msg       string  `json:"msg"`
+			ElapsedMS float64 `json:"elapsed_ms"` //nolint:tagliatelle
+		}
+
+		err := dec.Decode(&entry)
+		pt.Must(t, err, "failed to decode log entry")
+
+		// The elapsed time should include the drain delay.
+		if entry.Msg == "servers stopped" && entry.ElapsedMS < 100 {
+			t.Errorf("expected the elapsed time to be measured from the drain start, got %vms",
+				entry.ElapsedMS)
+		}
+	}
+}
+
+func TestStandardApp__RunWithSignals__SecondSignal(t *testing.T) {
+	logger := panurge.Logger("error", pt.NewTestLogWriter(t))
+
+	app, err := panurge.NewStandardApp(logger, "testservice",
+		panurge.WithAppPorts(0, 0),
+		panurge.WithAppShutdownTiming(time.Minute, time.Second),
+	)
+	pt.Must(t, err, "failed to create application")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- app.RunWithSignals(ctx)
+	}()
+
+	cancel()
+
+	// The drain starts after the application is listening for a
+	// second signal.
+	for {
+		if _, draining := app.InFlight().Draining(); draining {
+			break
+		}
+
+		select {
+		case err := <-done:
+			t.Fatalf("expected the application to drain, it stopped with: %v", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	proc, err := os.FindProcess(os.Getpid())
+	pt.Must(t, err, "failed to find the test process")
+
+	err = proc.Signal(os.Interrupt)
+	pt.Must(t, err, "failed to signal the test process")
+
+	select {
+	case err := <-done:
+		pt.Must(t, err, "expected a clean shutdown")
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the second signal to skip the drain delay")
+	}
+}
+
+func TestServers__CORSPathFilter(t *testing.T) {
+	var testServers panurge.TestServers
+
+	logger := panurge.Logger("warning", pt.NewTestLogWriter(t))
+
+	ok := func(_ *twirp.ServerHooks) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+
+	_, err := panurge.NewStandardApp(logger, "testservice",
+		panurge.WithAppTestServers(&testServers),
+		panurge.WithTwirpMetricsOptions(
+			panurge.WithTwirpMetricsRegisterer(prometheus.NewPedanticRegistry()),
+		),
+		panurge.WithAppService("/browser/", ok),
+		panurge.WithAppService("/backend/", ok),
+		panurge.WithCORSPathFilter(func(path string) bool {
+			return strings.HasPrefix(path, "/browser/")
+		}),
+	)
+	pt.Must(t, err, "failed to create test application")
+
+	t.Cleanup(testServers.Close)
+
+	public := testServers.GetPublic()
+
+	for path, wantCORS := range map[string]bool{
+		"/browser/Method": true,
+		"/backend/Method": false,
+	} {
+		req, err := http.NewRequest(http.MethodOptions, public.URL+path, nil)
+		pt.Must(t, err, "failed to create preflight request")
+
+		req.Header.Set("Origin", "https://app.infomaker.io")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+
+		res, err := public.Client().Do(req)
+		pt.Mustf(t, err, "failed to request %q", path)
+
+		_ = res.Body.Close()
+
+		gotCORS := res.Header.Get("Access-Control-Allow-Origin") != ""
+		if gotCORS != wantCORS {
+			t.Errorf("expected CORS handling to be %v for %q, got %v",
+				wantCORS, path, gotCORS)
+		}
+	}
+}
+
+func TestStandardApp__NoPublicServer(t *testing.T) {
+	logger := panurge.Logger("warning", pt.NewTestLogWriter(t))
+
+	// Occupy a port and use it as the public port, starting a
+	// public server would fail.
+	occupied, err := net.Listen("tcp", ":0")
+	pt.Must(t, err, "failed to listen on a random port")
+
+	t.Cleanup(func() {
+		_ = occupied.Close()
+	})
+
+	port := occupied.Addr().(*net.TCPAddr).Port
+
+	app, err := panurge.NewStandardApp(logger, "testservice",
+		panurge.WithAppPorts(port, 0),
+		panurge.WithAppNoPublicServer(),
+		panurge.WithAppShutdownTiming(0, time.Second),
+	)
+	pt.Must(t, err, "failed to create application")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- app.RunWithSignals(ctx)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		pt.Must(t, err, "expected the application to run without a public server")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the application to shut down")
+	}
+}
+
+func TestStandardApp__DegradedInternalServer(t *testing.T) {
+	logger := panurge.Logger("error", pt.NewTestLogWriter(t))
+
+	// Occupy a port and use it as the internal port, starting the
+	// internal server will fail.
+	occupied, err := net.Listen("tcp", ":0")
+	pt.Must(t, err, "failed to listen on a random port")
+
+	t.Cleanup(func() {
+		_ = occupied.Close()
+	})
+
+	port := occupied.Addr().(*net.TCPAddr).Port
+
+	strict, err := panurge.NewStandardApp(logger, "testservice",
+		panurge.WithAppPorts(0, port),
+		panurge.WithAppInternalServerBackoff(2, 10*time.Millisecond),
+		panurge.WithAppShutdownTiming(0, time.Second),
+	)
+	pt.Must(t, err, "failed to create application")
+
+	err = strict.RunWithSignals(context.Background())
+	if !errors.Is(err, syscall.EADDRINUSE) {
+		t.Fatalf("expected the internal bind error to be returned, got: %v", err)
+	}
+
+	app, err := panurge.NewStandardApp(logger, "testservice",
+		panurge.WithAppPorts(0, port),
+		panurge.WithAppInternalServerBackoff(2, 10*time.Millisecond),
+		panurge.WithAppDegradedInternalServer(),
+		panurge.WithAppShutdownTiming(0, time.Second),
+	)
+	pt.Must(t, err, "failed to create application")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- app.RunWithSignals(ctx)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		pt.Must(t, err, "expected the application to run without an internal server")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the application to shut down")
+	}
+}
+
+func TestGreeter__AuthedContext(t *testing.T) {
+	ctx := pt.AuthedContext(t, navigaid.Claims{Org: "testorg"})
+
+	res, err := (&Greeter{}).DoThing(ctx, &testservice.ThingReq{
+		Name: "Horatio Hornblower",
+	})
+	pt.Must(t, err, "failed to call the handler directly")
+
+	want := "Hello Horatio Hornblower!"
+	if res.Response != want {
+		t.Errorf("got %q, want %q", res.Response, want)
+	}
+}
+
+func TestServers__StartApp(t *testing.T) {
+	logger := panurge.Logger("warning", pt.NewTestLogWriter(t))
+
+	harness := pt.StartApp(t, logger, "testservice",
+		panurge.WithTwirpMetricsOptions(
+			panurge.WithTwirpMetricsRegisterer(prometheus.NewPedanticRegistry()),
+		),
+		panurge.WithAppService(
+			testservice.TestPathPrefix,
+			func(hooks *twirp.ServerHooks) http.Handler {
+				return testservice.NewTestServer(&Greeter{}, hooks)
+			},
+		),
+	)
+
+	ctx := pt.TestContext(t)
+
+	token := harness.TokenFor(navigaid.Claims{Org: "testorg"})
+
+	httpClient := oauth2.NewClient(ctx, oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: token},
+	))
+
+	client := testservice.NewTestProtobufClient(harness.PublicURL(), httpClient)
+
+	res, err := client.DoThing(ctx, &testservice.ThingReq{
+		Name: "Horatio Hornblower",
+	})
+	pt.Must(t, err, "failed to call the service")
+
+	if res.Response != "Hello Horatio Hornblower!" {
+		t.Errorf("unexpected response %q", res.Response)
+	}
+
+	health, err := http.DefaultClient.Get(harness.InternalURL() + "/health")
+	pt.Must(t, err, "failed to call the health endpoint")
+
+	_ = health.Body.Close()
+
+	if health.StatusCode != http.StatusOK {
+		t.Errorf("expected the health check to pass, got %d", health.StatusCode)
+	}
+}
+
+func TestServers__StartAppTwice(t *testing.T) {
+	logger := panurge.Logger("warning", pt.NewTestLogWriter(t))
+
+	// Both apps would register the same metrics if they used the
+	// default registerer.
+	for i := 0; i < 2; i++ {
+		harness := pt.StartApp(t, logger, "testservice",
+			panurge.WithAppService(
+				testservice.TestPathPrefix,
+				func(hooks *twirp.ServerHooks) http.Handler {
+					return testservice.NewTestServer(&Greeter{}, hooks)
+				},
+			),
+		)
+
+		if harness.App == nil {
+			t.Fatal("expected an application to be started")
+		}
+	}
+}
+
+func TestServers__MockNavigaIDWithValidator(t *testing.T) {
+	testServers := panurge.TestServers{
+		MockNavigaID: &navigaid.MockServerOptions{},
+	}
+
+	logger := panurge.Logger("warning", pt.NewTestLogWriter(t))
+
+	_, err := panurge.NewStandardApp(logger, "testservice",
+		panurge.WithAppTestServers(&testServers),
+		panurge.WithAppValidator(navigaid.NewJWKS("https://imas.example.com/v1/jwks")),
+	)
+	if err == nil {
+		t.Fatal("expected a mock server and a custom validator to be rejected")
+	}
+
+	if testServers.NavigaID() != nil {
+		t.Error("didn't expect the mock server to be started")
+	}
+}
+
+func TestServers__MockNavigaIDClosedOnError(t *testing.T) {
+	testServers := panurge.TestServers{
+		MockNavigaID: &navigaid.MockServerOptions{},
+	}
+
+	logger := panurge.Logger("warning", pt.NewTestLogWriter(t))
+	reg := prometheus.NewPedanticRegistry()
+
+	// Register the metrics up front so that the application setup
+	// fails after the mock server has been started.
+	_, err := panurge.NewTwirpMetricsHooks(panurge.WithTwirpMetricsRegisterer(reg))
+	pt.Must(t, err, "failed to register the metrics")
+
+	_, err = panurge.NewStandardApp(logger, "testservice",
+		panurge.WithAppTestServers(&testServers),
+		panurge.WithTwirpMetricsOptions(panurge.WithTwirpMetricsRegisterer(reg)),
+		panurge.WithAppService(
+			testservice.TestPathPrefix,
+			func(hooks *twirp.ServerHooks) http.Handler {
+				return testservice.NewTestServer(&Greeter{}, hooks)
+			},
+		),
+	)
+	if err == nil {
+		t.Fatal("expected the duplicate metrics registration to fail")
+	}
+
+	mock := testServers.NavigaID()
+	if mock == nil {
+		t.Fatal("expected the mock server to have been started")
+	}
+
+	res, err := http.Get(mock.Server.URL + "/v1/jwks")
+	if err == nil {
+		_ = res.Body.Close()
+
+		t.Fatal("expected the mock server to have been closed")
+	}
+}
+
+func TestServers__DisableProfiling(t *testing.T) {
+	var testServers panurge.TestServers
+
+	logger := panurge.Logger("warning", pt.NewTestLogWriter(t))
+
+	_, err := panurge.NewStandardApp(logger, "testservice",
+		panurge.WithAppTestServers(&testServers),
+		panurge.WithAppDisableProfiling(),
+	)
+	pt.Must(t, err, "failed to create test application")
+
+	t.Cleanup(testServers.Close)
+
+	internal := testServers.GetInternal()
+
+	for path, want := range map[string]int{
+		"/health":       http.StatusOK,
+		"/metrics":      http.StatusOK,
+		"/debug/pprof/": http.StatusNotFound,
+		"/debug/vars":   http.StatusNotFound,
+	} {
+		res, err := internal.Client().Get(internal.URL + path)
+		pt.Mustf(t, err, "failed to request %q", path)
+
+		_ = res.Body.Close()
+
+		if res.StatusCode != want {
+			t.Errorf("wanted status %d for %q, got %d", want, path, res.StatusCode)
+		}
+	}
+}
+
+func TestServers__InternalPaths(t *testing.T) {
+	var testServers panurge.TestServers
+
+	logger := panurge.Logger("warning", pt.NewTestLogWriter(t))
+
+	_, err := panurge.NewStandardApp(logger, "testservice",
+		panurge.WithAppTestServers(&testServers),
+		panurge.WithAppInternalPaths("/prometheus", "/healthz"),
+	)
+	pt.Must(t, err, "failed to create test application")
+
+	t.Cleanup(testServers.Close)
+
+	internal := testServers.GetInternal()
+
+	for path, want := range map[string]int{
+		"/healthz":    http.StatusOK,
+		"/prometheus": http.StatusOK,
+		"/health":     http.StatusNotFound,
+		"/metrics":    http.StatusNotFound,
+	} {
+		res, err := internal.Client().Get(internal.URL + path)
+		pt.Mustf(t, err, "failed to request %q", path)
+
+		_ = res.Body.Close()
+
+		if res.StatusCode != want {
+			t.Errorf("wanted status %d for %q, got %d", want, path, res.StatusCode)
+		}
+	}
+}
+
+func TestServers__Middleware(t *testing.T) {
+	var testServers panurge.TestServers
+
+	logger := panurge.Logger("warning", pt.NewTestLogWriter(t))
+
+	var calls []string
+
+	record := func(name string) panurge.Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				annotated := panurge.GetContextAnnotations(r.Context()) != nil
+
+				calls = append(calls, fmt.Sprintf("%s:%v", name, annotated))
+
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	ok := func(_ *twirp.ServerHooks) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+
+	_, err := panurge.NewStandardApp(logger, "testservice",
+		panurge.WithAppTestServers(&testServers),
+		panurge.WithTwirpMetricsOptions(
+			panurge.WithTwirpMetricsRegisterer(prometheus.NewPedanticRegistry()),
+		),
+		panurge.WithAppService("/test/", ok),
+		panurge.WithAppMiddleware(panurge.StageTracing, record("traced")),
+		panurge.WithAppMiddleware(panurge.StageInFlight, record("first")),
+		panurge.WithAppMiddleware(panurge.StageInFlight, record("second")),
+		panurge.WithAppMiddleware(panurge.StageRequestSummary, record("last")),
+	)
+	pt.Must(t, err, "failed to create test application")
+
+	t.Cleanup(testServers.Close)
+
+	public := testServers.GetPublic()
+
+	res, err := public.Client().Get(public.URL + "/test/Method")
+	pt.Must(t, err, "failed to make request")
+
+	_ = res.Body.Close()
+
+	want := []string{"first:false", "second:false", "traced:true", "last:true"}
+	if strings.Join(calls, ",") != strings.Join(want, ",") {
+		t.Errorf("wanted the middleware calls %v, got %v", want, calls)
+	}
+
+	_, err = panurge.NewStandardApp(logger, "testservice",
+		panurge.WithAppTestServers(&panurge.TestServers{}),
+		panurge.WithTwirpMetricsOptions(
+			panurge.WithTwirpMetricsRegisterer(prometheus.NewPedanticRegistry()),
+		),
+		panurge.WithAppMiddleware("compression", record("unknown")),
+	)
+	if err == nil {
+		t.Error("expected an unknown middleware stage to be rejected")
+	}
+}
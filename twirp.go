@@ -2,10 +2,18 @@ package panurge
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"mime"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/aws/aws-xray-sdk-go/xray"
@@ -25,18 +33,53 @@ type StandardApp struct {
 	authHook     *twirp.ServerHooks
 	authOrg      func(ctx context.Context) string
 	imasURL      string
+	validator    navigaid.Validator
+	legacyToken  bool
+	contentGuard bool
 	healthcheck  HealthcheckFunc
 	version      string
 	name         string
 	cors         CORSOptions
+	corsFilter   func(path string) bool
 	testServers  *TestServers
 	metricsOpts  []TwirpMetricOptionFunc
 	logger       *slog.Logger
+	inFlight     *InFlightTracker
+	drainTimeout time.Duration
+	drainDelay   time.Duration
+	summaryLog   bool
+	debugIdent   bool
+	actAsOrg     bool
+	authMetrics  bool
+	noPublic     bool
+	deadlines    bool
+	clientIP     bool
+	audience     string
+	proxyHops    int
+	clientIPOpts []ClientIPOption
+	bindAttempts int
+	bindBackoff  time.Duration
+	degradedOK   bool
+	hooks        ActiveTwirpHooks
+	concurrency  int
+	tokenHeaders []navigaid.TokenHeader
+	annotate     navigaid.ClaimsAnnotationFunc
+	internalOpts []InternalMuxOption
+	shutdownWait time.Duration
+	middleware   map[MiddlewareStage][]Middleware
+
+	internalHandlers []internalHandler
+	internalServer   *http.Server
+	limitConcurrency bool
+
+	Server      *http.Server
+	Mux         *http.ServeMux
+	InternalMux *http.ServeMux
+}
 
-	internalServer *http.Server
-
-	Server *http.Server
-	Mux    *http.ServeMux
+type internalHandler struct {
+	pattern string
+	handler http.Handler
 }
 
 type NewServiceFunc func(hooks *twirp.ServerHooks) http.Handler
@@ -62,6 +105,31 @@ func WithImasURL(imasURL string) StandardAppOption {
 	}
 }
 
+// WithAppValidator configures the application to verify incoming
+// bearer access tokens for twirp APIs using a custom validator. Takes
+// precedence over WithImasURL.
+func WithAppValidator(validator navigaid.Validator) StandardAppOption {
+	return func(app *StandardApp) {
+		app.validator = validator
+	}
+}
+
+// WithAppLegacyTokenHeader makes the application accept the raw token
+// in the x-imid-token header when there's no Authorization header.
+func WithAppLegacyTokenHeader() StandardAppOption {
+	return func(app *StandardApp) {
+		app.legacyToken = true
+	}
+}
+
+// WithAppContentTypeGuard makes the application reject requests to
+// the Twirp services that don't have a JSON or protobuf content type.
+func WithAppContentTypeGuard() StandardAppOption {
+	return func(app *StandardApp) {
+		app.contentGuard = true
+	}
+}
+
 // WithAppService exposes a Twirp service.
 func WithAppService(pathPrefix string, fn NewServiceFunc) StandardAppOption {
 	return func(app *StandardApp) {
@@ -69,6 +137,18 @@ func WithAppService(pathPrefix string, fn NewServiceFunc) StandardAppOption {
 	}
 }
 
+// WithAppInternalHandler mounts a handler on the internal server. The
+// standard internal endpoints are mounted first, NewStandardApp fails
+// if the pattern is already registered.
+func WithAppInternalHandler(pattern string, handler http.Handler) StandardAppOption {
+	return func(app *StandardApp) {
+		app.internalHandlers = append(app.internalHandlers, internalHandler{
+			pattern: pattern,
+			handler: handler,
+		})
+	}
+}
+
 // WithAppHealthCheck provides a custom function that evaluates the
 // health of the application.
 func WithAppHealthCheck(check HealthcheckFunc) StandardAppOption {
@@ -85,6 +165,20 @@ func WithAppPorts(public, internal int) StandardAppOption {
 	}
 }
 
+// WithAppPort sets the public listener port.
+func WithAppPort(port int) StandardAppOption {
+	return func(app *StandardApp) {
+		app.port = port
+	}
+}
+
+// WithAppInternalPort sets the internal listener port.
+func WithAppInternalPort(port int) StandardAppOption {
+	return func(app *StandardApp) {
+		app.internalPort = port
+	}
+}
+
 // WithAppVersion sets the application version for reporting purposes.
 func WithAppVersion(version string) StandardAppOption {
 	return func(app *StandardApp) {
@@ -92,6 +186,190 @@ func WithAppVersion(version string) StandardAppOption {
 	}
 }
 
+// WithAppDrainTimeout makes the readiness endpoint report "draining"
+// until there are no requests in flight, or the timeout has passed,
+// and "drained" after that. Readiness fails during the whole drain.
+func WithAppDrainTimeout(timeout time.Duration) StandardAppOption {
+	return func(app *StandardApp) {
+		app.drainTimeout = timeout
+	}
+}
+
+// WithAppActAsOrg lets callers with the "impersonate-org" permission
+// act on behalf of the organisation in the X-Act-As-Org header, see
+// navigaid.ActAsOrgMiddleware. The organisation is recorded in the
+// "act_as_org" annotation, while "imid_org" keeps the real
+// organisation.
+func WithAppActAsOrg() StandardAppOption {
+	return func(app *StandardApp) {
+		app.actAsOrg = true
+	}
+}
+
+// WithAppAuthMetrics records the outcome of authentication attempts
+// in the auth_attempts_total metric, see navigaid.NewAuthMetrics. The
+// metric is registered with the Twirp metrics registerer and namespace.
+func WithAppAuthMetrics() StandardAppOption {
+	return func(app *StandardApp) {
+		app.authMetrics = true
+	}
+}
+
+// WithAppDebugIdentityHeaders adds X-Debug-Org and X-Debug-Subject
+// response headers with the authenticated identity. This leaks
+// identity information to clients and is meant for integration
+// testing, never enable it in production.
+func WithAppDebugIdentityHeaders() StandardAppOption {
+	return func(app *StandardApp) {
+		app.debugIdent = true
+	}
+}
+
+// WithAppNoPublicServer makes the application only start the
+// internal server, for workers that don't serve a public API but
+// still want metrics and health checks.
+func WithAppNoPublicServer() StandardAppOption {
+	return func(app *StandardApp) {
+		app.noPublic = true
+	}
+}
+
+// WithAuthAnnotateFunc adds a function that annotates the request
+// context using the claims of authenticated requests. It's called
+// after the standard user and "imid_org" annotations have been added.
+func WithAuthAnnotateFunc(
+	fn func(ctx context.Context, claims navigaid.Claims),
+) StandardAppOption {
+	return func(app *StandardApp) {
+		app.annotate = fn
+	}
+}
+
+// WithAppDisableProfiling omits the pprof and expvar endpoints from
+// the internal server.
+func WithAppDisableProfiling() StandardAppOption {
+	return func(app *StandardApp) {
+		app.internalOpts = append(app.internalOpts, WithoutProfiling())
+	}
+}
+
+// WithAppHideHealthcheckDetails makes the health check on the
+// internal server respond without the error message on failure.
+func WithAppHideHealthcheckDetails() StandardAppOption {
+	return func(app *StandardApp) {
+		app.internalOpts = append(app.internalOpts, WithoutHealthcheckDetails())
+	}
+}
+
+// WithAppInternalPaths sets the paths of the Prometheus metrics and
+// health check endpoints on the internal server. Empty paths keep
+// the defaults "/metrics" and "/health".
+func WithAppInternalPaths(metricsPath, healthPath string) StandardAppOption {
+	return func(app *StandardApp) {
+		if metricsPath != "" {
+			app.internalOpts = append(app.internalOpts, WithMetricsPath(metricsPath))
+		}
+
+		if healthPath != "" {
+			app.internalOpts = append(app.internalOpts, WithHealthPath(healthPath))
+		}
+	}
+}
+
+// WithAppTokenHeaders makes authentication read the token from the
+// first of the headers that's present, see navigaid.WithTokenHeaders.
+func WithAppTokenHeaders(headers ...navigaid.TokenHeader) StandardAppOption {
+	return func(app *StandardApp) {
+		app.tokenHeaders = headers
+	}
+}
+
+// WithAppMaxConcurrency caps the number of requests that the public
+// server handles concurrently, see NewConcurrencyLimiter. The
+// rejection metric uses the Twirp metrics options. NewStandardApp
+// fails if n isn't positive.
+func WithAppMaxConcurrency(n int) StandardAppOption {
+	return func(app *StandardApp) {
+		app.concurrency = n
+		app.limitConcurrency = true
+	}
+}
+
+// WithAppInternalServerBackoff makes the internal server retry
+// binding its port up to attempts times, doubling the backoff
+// between every attempt. Useful when sidecars cause transient port
+// conflicts at startup.
+func WithAppInternalServerBackoff(attempts int, backoff time.Duration) StandardAppOption {
+	return func(app *StandardApp) {
+		app.bindAttempts = attempts
+		app.bindBackoff = backoff
+	}
+}
+
+// WithAppDegradedInternalServer makes the application keep serving
+// the public API without metrics and health checks if the internal
+// server fails to bind its port, instead of failing fast.
+func WithAppDegradedInternalServer() StandardAppOption {
+	return func(app *StandardApp) {
+		app.degradedOK = true
+	}
+}
+
+// WithAppDeadlinePropagation applies the timeout in the
+// navigaid.TimeoutHeader of incoming Twirp requests to the request
+// context. Use a navigaid.Transport with PropagateDeadline to send
+// the header on outgoing requests.
+func WithAppDeadlinePropagation() StandardAppOption {
+	return func(app *StandardApp) {
+		app.deadlines = true
+	}
+}
+
+// WithAppClientIP adds the client IP as a "client_ip" annotation to
+// all requests. The trustedProxies count is the number of proxies,
+// f.ex. load balancers, in front of the application that can be
+// trusted to set the X-Forwarded-For header. See ClientIP for the
+// options.
+func WithAppClientIP(trustedProxies int, opts ...ClientIPOption) StandardAppOption {
+	return func(app *StandardApp) {
+		app.clientIP = true
+		app.proxyHops = trustedProxies
+		app.clientIPOpts = opts
+	}
+}
+
+// WithAppAudience requires access tokens to have been issued for the
+// given audience. An empty audience uses the application name.
+func WithAppAudience(audience string) StandardAppOption {
+	return func(app *StandardApp) {
+		if audience == "" {
+			audience = app.name
+		}
+
+		app.audience = audience
+	}
+}
+
+// WithAppRequestSummary logs a summary entry with the final
+// annotations, status and duration at the end of every request.
+func WithAppRequestSummary() StandardAppOption {
+	return func(app *StandardApp) {
+		app.summaryLog = true
+	}
+}
+
+// WithAppShutdownTiming controls the graceful shutdown in
+// RunWithSignals. The drain delay is the time we wait after the
+// readiness endpoint has started reporting that we're draining, and
+// the timeout is the maximum time we wait for in-flight requests
+// during shutdown.
+func WithAppShutdownTiming(drainDelay, timeout time.Duration) StandardAppOption {
+	return func(app *StandardApp) {
+		app.drainDelay = drainDelay
+		app.shutdownWait = timeout
+	}
+}
+
 // WithTwirpCORSOptions customise the cors options for the Twirp
 // services.
 func WithTwirpCORSOptions(opts CORSOptions) StandardAppOption {
@@ -100,6 +378,15 @@ func WithTwirpCORSOptions(opts CORSOptions) StandardAppOption {
 	}
 }
 
+// WithCORSPathFilter limits CORS handling to requests with a path
+// that the filter returns true for, other requests are passed
+// straight to the service. By default CORS is applied to all services.
+func WithCORSPathFilter(filter func(path string) bool) StandardAppOption {
+	return func(app *StandardApp) {
+		app.corsFilter = filter
+	}
+}
+
 // WithTwirpMetricsOptions changes the metric collection behaviours.
 func WithTwirpMetricsOptions(opts ...TwirpMetricOptionFunc) StandardAppOption {
 	return func(app *StandardApp) {
@@ -110,7 +397,11 @@ func WithTwirpMetricsOptions(opts ...TwirpMetricOptionFunc) StandardAppOption {
 // NewStandardApp creates a standard panurge Twirp application.
 func NewStandardApp(
 	logger *slog.Logger, name string, opts ...StandardAppOption,
-) (*StandardApp, error) {
+) (_ *StandardApp, err error) {
+	if logger == nil {
+		return nil, errors.New("a logger is required for the standard application")
+	}
+
 	app := StandardApp{
 		healthcheck:  NoopHealthcheck,
 		port:         8081,
@@ -119,45 +410,125 @@ func NewStandardApp(
 		name:         name,
 		version:      "dev",
 		logger:       logger,
+		inFlight:     NewInFlightTracker(),
+		drainDelay:   5 * time.Second,
+		shutdownWait: 30 * time.Second,
 	}
 
 	for i := range opts {
 		opts[i](&app)
 	}
 
+	if app.testServers != nil && app.testServers.MockNavigaID != nil {
+		if app.validator != nil {
+			return nil, errors.New(
+				"a NavigaID mock server can't be combined with a custom validator")
+		}
+
+		validator, startErr := app.testServers.startNavigaID()
+		if startErr != nil {
+			return nil, startErr
+		}
+
+		// Don't leak the mock server if the rest of the setup fails.
+		defer func() {
+			if err != nil {
+				app.testServers.stopNavigaID()
+			}
+		}()
+
+		app.validator = validator
+	}
+
 	mux := http.NewServeMux()
 
 	if len(app.services) > 0 {
 		cors := NewCORSMiddleware(app.cors)
 
-		twirpHooks, err := StandardTwirpHooks(logger, TwirpHookOptions{
-			AuthHook:       app.authHook,
-			MetricsOptions: app.metricsOpts,
-			ImasURL:        app.imasURL,
-		})
+		hookOpts := TwirpHookOptions{
+			AuthHook:          app.authHook,
+			MetricsOptions:    app.metricsOpts,
+			ImasURL:           app.imasURL,
+			Validator:         app.validator,
+			LegacyToken:       app.legacyToken,
+			DebugIdentity:     app.debugIdent,
+			ActAsOrg:          app.actAsOrg,
+			AuthMetrics:       app.authMetrics,
+			PropagateDeadline: app.deadlines,
+			Audience:          app.audience,
+			TokenHeaders:      app.tokenHeaders,
+			AnnotateClaims:    app.annotate,
+		}
+
+		twirpHooks, err := StandardTwirpHooks(logger, hookOpts)
+		if err != nil {
+			return nil, err
+		}
+
+		err = app.inFlight.RegisterGauge(app.metricsOpts...)
 		if err != nil {
 			return nil, err
 		}
 
+		app.hooks = DescribeTwirpHooks(hookOpts)
+
+		headerNames := []string{
+			"Authorization", "x-imid-token", navigaid.TimeoutHeader,
+			navigaid.ActAsOrgHeader,
+		}
+
+		for _, th := range app.tokenHeaders {
+			headerNames = append(headerNames, th.Name)
+		}
+
 		for prefix, newFunc := range app.services {
 			handler := newFunc(twirpHooks)
 
+			if app.contentGuard {
+				handler = TwirpContentTypeMiddleware(handler)
+			}
+
 			mux.Handle(prefix, AddTwirpRequestHeaders(
-				cors.Handler(handler),
-				"Authorization", "x-imid-token",
+				corsHandler(cors, app.corsFilter, handler),
+				headerNames...,
 			))
 		}
 	}
 
-	ConfigureXRay(logger, app.version)
+	err = ConfigureXRay(logger, app.version)
+	if err != nil {
+		logger.Error(err.Error())
+	}
 
-	internalMux := StandardInternalMux(logger, app.healthcheck)
-	instrumentedHandler := xray.Handler(
-		xray.NewFixedSegmentNamer(app.name),
-		AnnotationMiddleware(mux),
-	)
+	internalMux := StandardInternalMux(
+		logger, app.healthcheck, app.internalOpts...)
+	internalMux.Handle("/ready", ReadinessHandler(
+		logger, app.healthcheck, ReadinessOptions{
+			Tracker:      app.inFlight,
+			DrainTimeout: app.drainTimeout,
+		},
+	))
+
+	for _, ih := range app.internalHandlers {
+		if ih.pattern == "" || ih.handler == nil {
+			return nil, errors.New("internal handlers need a pattern and a handler")
+		}
+
+		if internalPatternTaken(internalMux, ih.pattern) {
+			return nil, fmt.Errorf(
+				"the internal handler pattern %q is already registered", ih.pattern)
+		}
+
+		internalMux.Handle(ih.pattern, ih.handler)
+	}
+
+	instrumentedHandler, err := app.buildPublicHandler(mux)
+	if err != nil {
+		return nil, err
+	}
 
 	app.Mux = mux
+	app.InternalMux = internalMux
 
 	if app.testServers != nil {
 		app.testServers.public = httptest.NewServer(instrumentedHandler)
@@ -170,10 +541,26 @@ func NewStandardApp(
 	return &app, nil
 }
 
-// ListenAndServe starts both the internal and external servers. If
-// the application was configured with test servers this function will
+// internalPatternTaken checks if the pattern already has been
+// registered with the mux, as registering it again would panic.
+func internalPatternTaken(mux *http.ServeMux, pattern string) bool {
+	host, path, _ := strings.Cut(pattern, "/")
+
+	_, registered := mux.Handler(&http.Request{
+		Method: http.MethodGet,
+		Host:   host,
+		URL:    &url.URL{Path: "/" + path},
+	})
+
+	return registered == pattern
+}
+
+// ListenAndServe starts both the internal and external servers, or
+// only the internal server if WithAppNoPublicServer was used. If the
+// application was configured with test servers this function will
 // return once they have been set up, otherwise it will block as long
-// as the servers are listening.
+// as the servers are listening. Servers that are stopped with
+// Shutdown aren't reported as errors.
 func (app *StandardApp) ListenAndServe() error {
 	if app.testServers != nil {
 		return nil
@@ -181,8 +568,13 @@ func (app *StandardApp) ListenAndServe() error {
 
 	var grp errgroup.Group
 
-	grp.Go(app.Server.ListenAndServe)
-	grp.Go(app.internalServer.ListenAndServe)
+	if !app.noPublic {
+		grp.Go(func() error {
+			return ignoreServerClosed(app.Server.ListenAndServe())
+		})
+	}
+
+	grp.Go(app.serveInternal)
 
 	err := grp.Wait()
 	if err != nil {
@@ -192,6 +584,193 @@ func (app *StandardApp) ListenAndServe() error {
 	return nil
 }
 
+// ignoreServerClosed maps http.ErrServerClosed to nil, so that the
+// error of whatever made us close a server is the one that gets
+// reported.
+func ignoreServerClosed(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+func (app *StandardApp) serveInternal() error {
+	listener, err := app.listenInternal()
+	if err != nil {
+		if app.degradedOK && !app.noPublic {
+			app.logger.Error(fmt.Sprintf(
+				"running without metrics and health checks. %v", err))
+
+			return nil
+		}
+
+		// Stop the public server so that we fail fast instead
+		// of running without metrics and health checks. The
+		// public server reports being closed as a nil error, so
+		// the bind error is what ListenAndServe returns.
+		if !app.noPublic {
+			_ = app.Server.Close()
+		}
+
+		return err
+	}
+
+	err = ignoreServerClosed(app.internalServer.Serve(listener))
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+func (app *StandardApp) listenInternal() (net.Listener, error) {
+	backoff := app.bindBackoff
+
+	for attempt := 1; ; attempt++ {
+		listener, err := net.Listen("tcp", app.internalServer.Addr)
+		if err == nil {
+			return listener, nil
+		}
+
+		if attempt >= app.bindAttempts {
+			return nil, fmt.Errorf(
+				"failed to start internal server: %w", err)
+		}
+
+		time.Sleep(backoff)
+
+		backoff *= 2
+	}
+}
+
+// Shutdown gracefully shuts down both the internal and external
+// servers, waiting for in-flight requests until the context is
+// cancelled. The progress of the shutdown is logged at info level.
+func (app *StandardApp) Shutdown(ctx context.Context) error {
+	if app.testServers != nil {
+		return nil
+	}
+
+	inFlight := app.inFlight.InFlight()
+
+	// The drain has already been started if we're shutting down
+	// through RunWithSignals, measure from when it started.
+	app.inFlight.StartDrain()
+
+	start, _ := app.inFlight.Draining()
+
+	app.logger.LogAttrs(ctx, slog.LevelInfo, "draining started",
+		slog.Time("started_at", start),
+		slog.Int64("in_flight", inFlight))
+
+	var grp errgroup.Group
+
+	if !app.noPublic {
+		grp.Go(func() error {
+			return app.Server.Shutdown(ctx)
+		})
+	}
+
+	grp.Go(func() error {
+		return app.internalServer.Shutdown(ctx)
+	})
+
+	drainErr := app.inFlight.WaitForDrain(ctx)
+	if drainErr != nil {
+		app.logger.LogAttrs(ctx, slog.LevelWarn, "drain incomplete",
+			slog.Int64("requests", inFlight),
+			slog.Int64("remaining", app.inFlight.InFlight()),
+			slog.Int64("elapsed_ms", time.Since(start).Milliseconds()),
+			slog.String("err", drainErr.Error()))
+	} else {
+		app.logger.LogAttrs(ctx, slog.LevelInfo, "drain complete",
+			slog.Int64("requests", inFlight),
+			slog.Int64("elapsed_ms", time.Since(start).Milliseconds()))
+	}
+
+	err := grp.Wait()
+	if err != nil {
+		return fmt.Errorf("failed to shut down servers: %w", err)
+	}
+
+	app.logger.LogAttrs(ctx, slog.LevelInfo, "servers stopped",
+		slog.Int64("elapsed_ms", time.Since(start).Milliseconds()))
+
+	return nil
+}
+
+// RunWithSignals starts both the internal and external servers and
+// shuts them down gracefully when the process receives SIGTERM or
+// SIGINT, or the context is cancelled. The readiness endpoint starts
+// reporting that we're draining, and after the drain delay the
+// servers are shut down. A second signal during the drain delay
+// shuts the servers down immediately, and a third signal gets the
+// default signal behaviour.
+func (app *StandardApp) RunWithSignals(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- app.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	stop()
+
+	interrupt, stopInterrupt := signal.NotifyContext(
+		context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stopInterrupt()
+
+	app.inFlight.StartDrain()
+
+	timer := time.NewTimer(app.drainDelay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-interrupt.Done():
+		app.logger.Warn("received a second signal, skipping the drain delay")
+	}
+
+	stopInterrupt()
+
+	shutdownCtx, cancel := context.WithTimeout(
+		context.Background(), app.shutdownWait)
+	defer cancel()
+
+	err := app.Shutdown(shutdownCtx)
+	if err != nil {
+		return err
+	}
+
+	err = <-serveErr
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// ActiveTwirpHooks describes the Twirp hooks that were set up for
+// the services. Auth will be empty if no services were registered.
+func (app *StandardApp) ActiveTwirpHooks() ActiveTwirpHooks {
+	return app.hooks
+}
+
+// InFlight returns the tracker for the requests that are being
+// handled by the public server.
+func (app *StandardApp) InFlight() *InFlightTracker {
+	return app.inFlight
+}
+
 // LambdaHandler creates an HTTP event handler (Loadbalancer/APIGateway) that proxies requests to the
 // application ServeMux.
 func (app *StandardApp) LambdaHandler() lambda.HandlerFunc {
@@ -201,50 +780,325 @@ func (app *StandardApp) LambdaHandler() lambda.HandlerFunc {
 // TwirpHookOptions controls the configuration of the standard twirp
 // hooks.
 type TwirpHookOptions struct {
-	AuthHook       *twirp.ServerHooks
-	ImasURL        string
+	AuthHook *twirp.ServerHooks
+	ImasURL  string
+	// Validator is used to validate access tokens, takes
+	// precedence over ImasURL.
+	Validator navigaid.Validator
+	// LegacyToken makes the authentication fall back to the raw
+	// token in the x-imid-token header.
+	LegacyToken bool
+	// DebugIdentity adds X-Debug-Org and X-Debug-Subject response
+	// headers for authenticated requests. Never enable this in
+	// production.
+	DebugIdentity bool
+	// ActAsOrg lets callers with the "impersonate-org" permission
+	// act as the organisation in the X-Act-As-Org header.
+	ActAsOrg bool
+	// AuthMetrics records the outcome of authentication attempts
+	// in the auth_attempts_total metric.
+	AuthMetrics bool
+	// PropagateDeadline applies the timeout in the
+	// navigaid.TimeoutHeader to the request context.
+	PropagateDeadline bool
+	// Audience requires access tokens to have been issued for
+	// the audience.
+	Audience string
+	// TokenHeaders are the headers that the token is read from, see
+	// navigaid.WithTokenHeaders.
+	TokenHeaders []navigaid.TokenHeader
+	// AnnotateClaims is called with the claims of authenticated
+	// requests after the standard annotations have been added.
+	AnnotateClaims navigaid.ClaimsAnnotationFunc
 	MetricsOptions []TwirpMetricOptionFunc
 }
 
+// Authentication sources reported by DescribeTwirpHooks.
+const (
+	AuthSourceNone      = "none"
+	AuthSourceCustom    = "custom"
+	AuthSourceValidator = "validator"
+	AuthSourceImas      = "imas"
+)
+
+// ActiveTwirpHooks describes which hooks StandardTwirpHooks sets up
+// for a set of options.
+type ActiveTwirpHooks struct {
+	// Auth is the source of authentication: AuthSourceNone,
+	// AuthSourceCustom for a custom auth hook, AuthSourceValidator
+	// or AuthSourceImas.
+	Auth string
+	// Metrics is true unless the metrics have been disabled with a
+	// nil registerer.
+	Metrics           bool
+	AuthMetrics       bool
+	LegacyToken       bool
+	Audience          string
+	DebugIdentity     bool
+	ActAsOrg          bool
+	PropagateDeadline bool
+}
+
+// LogValue implements slog.LogValuer.
+func (a ActiveTwirpHooks) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("auth", a.Auth),
+		slog.Bool("metrics", a.Metrics),
+		slog.Bool("auth_metrics", a.AuthMetrics),
+		slog.Bool("legacy_token", a.LegacyToken),
+		slog.String("audience", a.Audience),
+		slog.Bool("debug_identity", a.DebugIdentity),
+		slog.Bool("act_as_org", a.ActAsOrg),
+		slog.Bool("propagate_deadline", a.PropagateDeadline),
+	)
+}
+
+// DescribeTwirpHooks returns a description of the hooks that
+// StandardTwirpHooks would set up for the options.
+func DescribeTwirpHooks(opts TwirpHookOptions) ActiveTwirpHooks {
+	desc := ActiveTwirpHooks{
+		Auth:              AuthSourceNone,
+		Metrics:           newTwirpMetricsOptions(opts.MetricsOptions).reg != nil,
+		PropagateDeadline: opts.PropagateDeadline,
+	}
+
+	switch {
+	case opts.AuthHook != nil:
+		desc.Auth = AuthSourceCustom
+	case opts.Validator != nil:
+		desc.Auth = AuthSourceValidator
+	case opts.ImasURL != "":
+		desc.Auth = AuthSourceImas
+	}
+
+	if desc.Auth == AuthSourceValidator || desc.Auth == AuthSourceImas {
+		desc.LegacyToken = opts.LegacyToken
+		desc.Audience = opts.Audience
+		desc.AuthMetrics = desc.Metrics && opts.AuthMetrics
+	}
+
+	desc.DebugIdentity = desc.Auth != AuthSourceNone && opts.DebugIdentity
+	desc.ActAsOrg = desc.Auth != AuthSourceNone && opts.ActAsOrg
+
+	return desc
+}
+
 // StandardTwirpHooks sets up the standard twirp server hooks for
-// metrics, authentication, and error logging.
+// metrics, authentication, and error logging. The hooks are selected
+// as described by DescribeTwirpHooks.
 func StandardTwirpHooks(
 	logger *slog.Logger, opts TwirpHookOptions,
 ) (*twirp.ServerHooks, error) {
-	var auth *twirp.ServerHooks
+	active := DescribeTwirpHooks(opts)
 
-	metrics, err := NewTwirpMetricsHooks(opts.MetricsOptions...)
-	if err != nil {
-		return nil, err
+	var metrics, auth *twirp.ServerHooks
+
+	if active.Metrics {
+		m, err := NewTwirpMetricsHooks(opts.MetricsOptions...)
+		if err != nil {
+			return nil, err
+		}
+
+		metrics = m
 	}
 
-	if opts.AuthHook != nil {
+	switch active.Auth {
+	case AuthSourceCustom:
 		auth = opts.AuthHook
-	} else if opts.ImasURL != "" {
-		svc := navigaid.NewJWKS(
-			navigaid.ImasJWKSEndpoint(opts.ImasURL),
-		)
+	case AuthSourceValidator, AuthSourceImas:
+		a, err := standardAuthHook(logger, opts, active)
+		if err != nil {
+			return nil, err
+		}
 
-		auth = navigaid.NewTwirpAuthHook(logger, svc, func(ctx context.Context, org string, user string) {
-			AddUserAnnotation(ctx, user)
-			AddAnnotation(ctx, "imid_org", org)
-		})
+		auth = a
 	}
 
-	hooks := metrics
+	var hooks *twirp.ServerHooks
 
-	if auth != nil {
+	switch {
+	case metrics != nil && auth != nil:
 		hooks = CombineMetricsAndAuthHooks(metrics, auth)
+	case metrics != nil:
+		hooks = metrics
+	case auth != nil:
+		hooks = auth
+	default:
+		hooks = &twirp.ServerHooks{}
+	}
+
+	if active.ActAsOrg {
+		hooks = twirp.ChainHooks(hooks, navigaid.NewTwirpActAsOrgHook(
+			func(ctx context.Context, _ string, actAsOrg string) {
+				AddAnnotation(ctx, "act_as_org", actAsOrg)
+			}))
 	}
 
-	hooks = twirp.ChainHooks(hooks, NewErrorLoggingHooks(logger))
+	if active.DebugIdentity {
+		hooks = twirp.ChainHooks(hooks, navigaid.NewTwirpDebugIdentityHook())
+	}
+
+	hooks = twirp.ChainHooks(hooks,
+		NewErrorLoggingHooks(logger), NewTraceIDHooks())
+
+	if active.PropagateDeadline {
+		hooks = twirp.ChainHooks(NewDeadlineHooks(), hooks)
+	}
+
+	logger.Debug("configured twirp hooks",
+		slog.Any("hooks", active))
 
 	return hooks, nil
 }
 
+// standardAuthHook creates the auth hook for the validator or IMAS
+// auth sources.
+func standardAuthHook(
+	logger *slog.Logger, opts TwirpHookOptions, active ActiveTwirpHooks,
+) (*twirp.ServerHooks, error) {
+	validator := opts.Validator
+	if active.Auth == AuthSourceImas {
+		jwks, err := navigaid.NewJWKSChecked(
+			navigaid.ImasJWKSEndpoint(opts.ImasURL),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IMAS URL: %w", err)
+		}
+
+		validator = jwks
+	}
+
+	var authOpts []navigaid.AuthOption
+
+	if active.LegacyToken {
+		authOpts = append(authOpts, navigaid.WithLegacyTokenHeader())
+	}
+
+	if active.Audience != "" {
+		authOpts = append(authOpts, navigaid.WithAudience(active.Audience))
+	}
+
+	if len(opts.TokenHeaders) > 0 {
+		authOpts = append(authOpts, navigaid.WithTokenHeaders(opts.TokenHeaders...))
+	}
+
+	if opts.AnnotateClaims != nil {
+		authOpts = append(authOpts, navigaid.WithClaimsAnnotation(opts.AnnotateClaims))
+	}
+
+	if active.AuthMetrics {
+		metricsOpts := newTwirpMetricsOptions(opts.MetricsOptions)
+
+		authMetrics, err := navigaid.NewAuthMetrics(metricsOpts.reg,
+			navigaid.WithAuthMetricsNamespace(
+				metricsOpts.namespace, metricsOpts.subsystem))
+		if err != nil {
+			return nil, err
+		}
+
+		authOpts = append(authOpts, navigaid.WithAuthMetrics(authMetrics))
+	}
+
+	return navigaid.NewTwirpAuthHook(logger, validator, func(ctx context.Context, org string, user string) {
+		AddUserAnnotation(ctx, user)
+		AddAnnotation(ctx, "imid_org", org)
+	}, authOpts...), nil
+}
+
+// TraceIDMetaKey is the Twirp error meta key used for the trace ID.
+const TraceIDMetaKey = "trace_id"
+
+// TraceIDHeader is the response header used for the trace ID of
+// Twirp error responses.
+const TraceIDHeader = "X-Trace-Id"
+
+// NewTraceIDHooks creates twirp server hooks that set the trace ID
+// of the request annotations as the TraceIDHeader of error responses,
+// so that clients get an ID that can be correlated with our logs.
+// They're a part of the StandardTwirpHooks.
+func NewTraceIDHooks() *twirp.ServerHooks {
+	return &twirp.ServerHooks{
+		Error: func(ctx context.Context, _ twirp.Error) context.Context {
+			ann := GetContextAnnotations(ctx)
+			if ann == nil {
+				return ctx
+			}
+
+			_ = twirp.SetHTTPResponseHeader(ctx, TraceIDHeader, ann.GetID())
+
+			return ctx
+		},
+	}
+}
+
+// NewTraceIDInterceptor creates a Twirp server interceptor that adds
+// the trace ID of the request annotations as "trace_id" meta to
+// errors returned by the service, so that clients get an ID that can
+// be correlated with our logs. Meta that has been set by the service
+// isn't overwritten. Hooks can't change the error, so unlike the
+// TraceIDHeader the meta is opt-in: add the interceptor to each
+// service using twirp.WithServerInterceptors().
+func NewTraceIDInterceptor() twirp.Interceptor {
+	return func(next twirp.Method) twirp.Method {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			res, err := next(ctx, req)
+			if err == nil {
+				return res, nil
+			}
+
+			ann := GetContextAnnotations(ctx)
+			if ann == nil {
+				return res, err
+			}
+
+			var twErr twirp.Error
+			if !errors.As(err, &twErr) {
+				twErr = twirp.InternalErrorWith(err)
+			}
+
+			if twErr.Meta(TraceIDMetaKey) != "" {
+				return res, twErr
+			}
+
+			return res, twErr.WithMeta(TraceIDMetaKey, ann.GetID())
+		}
+	}
+}
+
+type deadlineCancelKey struct{}
+
+// NewDeadlineHooks creates twirp server hooks that apply the timeout
+// in the navigaid.TimeoutHeader of the request to the request
+// context. The header must be added to the context using
+// AddTwirpRequestHeaders. Invalid timeout values are ignored.
+func NewDeadlineHooks() *twirp.ServerHooks {
+	return &twirp.ServerHooks{
+		RequestReceived: func(ctx context.Context) (context.Context, error) {
+			headers, ok := twirp.HTTPRequestHeaders(ctx)
+			if !ok {
+				return ctx, nil
+			}
+
+			dCtx, cancel, err := navigaid.ContextWithTimeoutHeader(
+				ctx, headers.Get(navigaid.TimeoutHeader))
+			if err != nil {
+				return ctx, nil
+			}
+
+			return context.WithValue(dCtx, deadlineCancelKey{}, cancel), nil
+		},
+		ResponseSent: func(ctx context.Context) {
+			if cancel, ok := ctx.Value(deadlineCancelKey{}).(context.CancelFunc); ok {
+				cancel()
+			}
+		},
+	}
+}
+
 // NewErrorLoggingHooks will log outgoing error responses. XRay
-// annotations should be logged together with the error, so we do not
-// add information about the method and service here.
+// annotations should be logged together with the error, so we only add
+// information about the method and service when there's no XRay
+// segment in the context.
 func NewErrorLoggingHooks(logger *slog.Logger) *twirp.ServerHooks {
 	return &twirp.ServerHooks{
 		Error: func(ctx context.Context, err twirp.Error) context.Context {
@@ -253,6 +1107,16 @@ func NewErrorLoggingHooks(logger *slog.Logger) *twirp.ServerHooks {
 			attr = append(attr, slog.Any("twirp_code", err.Code()))
 			attr = append(attr, slog.String("twirp_msg", err.Msg()))
 
+			if xray.GetSegment(ctx) == nil {
+				if service, ok := twirp.ServiceName(ctx); ok {
+					attr = append(attr, slog.String("twirp_service", service))
+				}
+
+				if method, ok := twirp.MethodName(ctx); ok {
+					attr = append(attr, slog.String("twirp_method", method))
+				}
+			}
+
 			if err.MetaMap() != nil {
 				attr = append(attr, slog.Any("twirp_meta", err.MetaMap()))
 			}
@@ -270,12 +1134,34 @@ func NewErrorLoggingHooks(logger *slog.Logger) *twirp.ServerHooks {
 }
 
 // CombineMetricsAndAuthHooks tweaks how the hooks are chained so that
-// the metrics.RequestRouted always is called regardless of auth
-// errors. An auth error will still fail the request, but any errors
-// returned by the metrics hook will be ignored.
+// the metrics.RequestReceived always runs before the auth hooks, and
+// metrics.RequestRouted always is called regardless of auth
+// errors. This ensures that the request start time is recorded, and
+// that the duration is observed, for requests that are rejected by
+// auth. An auth error will still fail the request, but any errors
+// returned by the metrics hooks will be ignored.
 func CombineMetricsAndAuthHooks(metrics, auth *twirp.ServerHooks) *twirp.ServerHooks {
 	chained := twirp.ChainHooks(metrics, auth)
 
+	chained.RequestReceived = func(ctx context.Context) (context.Context, error) {
+		if metrics.RequestReceived != nil {
+			if mCtx, mErr := metrics.RequestReceived(ctx); mErr == nil {
+				ctx = mCtx
+			}
+		}
+
+		if auth.RequestReceived == nil {
+			return ctx, nil
+		}
+
+		ctx, err := auth.RequestReceived(ctx)
+		if err != nil {
+			return ctx, fmt.Errorf("%w", err)
+		}
+
+		return ctx, nil
+	}
+
 	chained.RequestRouted = func(ctx context.Context) (context.Context, error) {
 		var err error
 		if auth.RequestRouted != nil {
@@ -283,7 +1169,7 @@ func CombineMetricsAndAuthHooks(metrics, auth *twirp.ServerHooks) *twirp.ServerH
 		}
 
 		if metrics.RequestRouted != nil {
-			if mCtx, mErr := metrics.RequestRouted(ctx); mErr != nil {
+			if mCtx, mErr := metrics.RequestRouted(ctx); mErr == nil {
 				ctx = mCtx
 			}
 		}
@@ -302,10 +1188,27 @@ type TwirpMetricsOptions struct {
 	reg         prometheus.Registerer
 	testLatency time.Duration
 	contextOrg  func(ctx context.Context) string
+	statusLabel func(status string) string
+	namespace   string
+	subsystem   string
 }
 
 type TwirpMetricOptionFunc func(opts *TwirpMetricsOptions)
 
+// newTwirpMetricsOptions applies the options on top of the defaults,
+// which use the default Prometheus registerer.
+func newTwirpMetricsOptions(opts []TwirpMetricOptionFunc) TwirpMetricsOptions {
+	opt := TwirpMetricsOptions{
+		reg: prometheus.DefaultRegisterer,
+	}
+
+	for i := range opts {
+		opts[i](&opt)
+	}
+
+	return opt
+}
+
 // WithTwirpMetricsOrgFunction uses a custom function for resolving
 // the current organisation from the context.
 func WithTwirpMetricsOrgFunction(fn func(ctx context.Context) string) TwirpMetricOptionFunc {
@@ -314,13 +1217,44 @@ func WithTwirpMetricsOrgFunction(fn func(ctx context.Context) string) TwirpMetri
 	}
 }
 
-// WithTwirpMetricsRegisterer uses a custom registerer for Twirp metrics.
+// WithTwirpMetricsStatusLabel sets a function that transforms the
+// HTTP status code before it's used as the "status" label of
+// rpc_responses_total, f.ex. StatusClassLabel. Use rpc_errors_total
+// to get the Twirp error codes.
+func WithTwirpMetricsStatusLabel(fn func(status string) string) TwirpMetricOptionFunc {
+	return func(opts *TwirpMetricsOptions) {
+		opts.statusLabel = fn
+	}
+}
+
+// StatusClassLabel buckets HTTP status codes into classes like "2xx"
+// and "5xx". Can be used with WithTwirpMetricsStatusLabel.
+func StatusClassLabel(status string) string {
+	if len(status) != 3 || status[0] < '1' || status[0] > '5' {
+		return status
+	}
+
+	return status[:1] + "xx"
+}
+
+// WithTwirpMetricsRegisterer uses a custom registerer for Twirp
+// metrics. A nil registerer disables the metrics.
 func WithTwirpMetricsRegisterer(reg prometheus.Registerer) TwirpMetricOptionFunc {
 	return func(opts *TwirpMetricsOptions) {
 		opts.reg = reg
 	}
 }
 
+// WithTwirpMetricsNamespace prefixes the metric names with a
+// namespace and subsystem, so that several applications can register
+// distinct series.
+func WithTwirpMetricsNamespace(namespace, subsystem string) TwirpMetricOptionFunc {
+	return func(opts *TwirpMetricsOptions) {
+		opts.namespace = namespace
+		opts.subsystem = subsystem
+	}
+}
+
 // WithTwirpMetricsStaticTestLatency configures the RPC metrics to report
 // a static duration.
 func WithTwirpMetricsStaticTestLatency(latency time.Duration) TwirpMetricOptionFunc {
@@ -329,7 +1263,33 @@ func WithTwirpMetricsStaticTestLatency(latency time.Duration) TwirpMetricOptionF
 	}
 }
 
-// NewTwirpMetricsHooks creates new twirp hooks enabling prometheus metrics.
+type reqStartTimestampKey struct{}
+
+// RequestElapsed returns the time that has passed since the Twirp
+// metrics hooks received the request. Returns false if the context
+// doesn't come from a request handled with the metrics hooks.
+func RequestElapsed(ctx context.Context) (time.Duration, bool) {
+	start, ok := ctx.Value(reqStartTimestampKey{}).(time.Time)
+	if !ok {
+		return 0, false
+	}
+
+	return time.Since(start), true
+}
+
+// NewTwirpMetricsHooks creates new twirp hooks enabling prometheus
+// metrics.
+//
+// The Twirp server hooks fire as follows: RequestReceived fires for
+// every request, and RequestRouted once the service and method has
+// been resolved. Error fires for every error response, including
+// errors returned by RequestRouted hooks (f.ex. authentication),
+// handler errors and panics, requests that were cancelled or timed
+// out while the body was read, and failures to write a successful
+// response. ResponseSent fires last for all responses, successful or
+// not, so the duration is observed for failed requests as well. Error
+// responses are also counted by the rpc_errors_total metric, labelled
+// with the Twirp error code.
 func NewTwirpMetricsHooks(opts ...TwirpMetricOptionFunc) (*twirp.ServerHooks, error) {
 	opt := TwirpMetricsOptions{
 		reg: prometheus.DefaultRegisterer,
@@ -347,10 +1307,16 @@ func NewTwirpMetricsHooks(opts ...TwirpMetricOptionFunc) (*twirp.ServerHooks, er
 		opts[i](&opt)
 	}
 
+	if opt.reg == nil {
+		return nil, errors.New("a registerer is required for the metrics hooks")
+	}
+
 	requestsReceived := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Name: "rpc_requests_total",
-			Help: "Number of RPC requests received.",
+			Namespace: opt.namespace,
+			Subsystem: opt.subsystem,
+			Name:      "rpc_requests_total",
+			Help:      "Number of RPC requests received.",
 		},
 		[]string{"service", "method", "organisation"},
 	)
@@ -359,9 +1325,11 @@ func NewTwirpMetricsHooks(opts ...TwirpMetricOptionFunc) (*twirp.ServerHooks, er
 	}
 
 	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
-		Name:    "rpc_duration",
-		Help:    "Duration for a rpc call.",
-		Buckets: prometheus.ExponentialBuckets(5, 2, 15),
+		Namespace: opt.namespace,
+		Subsystem: opt.subsystem,
+		Name:      "rpc_duration",
+		Help:      "Duration for a rpc call.",
+		Buckets:   prometheus.ExponentialBuckets(5, 2, 15),
 	}, []string{"service", "method", "organisation"})
 	if err := opt.reg.Register(duration); err != nil {
 		return nil, fmt.Errorf("failed to register metric: %w", err)
@@ -369,8 +1337,10 @@ func NewTwirpMetricsHooks(opts ...TwirpMetricOptionFunc) (*twirp.ServerHooks, er
 
 	responsesSent := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Name: "rpc_responses_total",
-			Help: "Number of RPC responses sent.",
+			Namespace: opt.namespace,
+			Subsystem: opt.subsystem,
+			Name:      "rpc_responses_total",
+			Help:      "Number of RPC responses sent.",
 		},
 		[]string{"service", "method", "status", "organisation"},
 	)
@@ -378,12 +1348,23 @@ func NewTwirpMetricsHooks(opts ...TwirpMetricOptionFunc) (*twirp.ServerHooks, er
 		return nil, fmt.Errorf("failed to register metric: %w", err)
 	}
 
-	var hooks twirp.ServerHooks
+	errorsSent := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: opt.namespace,
+			Subsystem: opt.subsystem,
+			Name:      "rpc_errors_total",
+			Help:      "Number of RPC error responses sent.",
+		},
+		[]string{"service", "method", "code", "organisation"},
+	)
+	if err := opt.reg.Register(errorsSent); err != nil {
+		return nil, fmt.Errorf("failed to register metric: %w", err)
+	}
 
-	var reqStartTimestampKey = new(int)
+	var hooks twirp.ServerHooks
 
 	hooks.RequestReceived = func(ctx context.Context) (context.Context, error) {
-		return context.WithValue(ctx, reqStartTimestampKey, time.Now()), nil
+		return context.WithValue(ctx, reqStartTimestampKey{}, time.Now()), nil
 	}
 
 	hooks.ResponseSent = func(ctx context.Context) {
@@ -397,11 +1378,15 @@ func NewTwirpMetricsHooks(opts ...TwirpMetricOptionFunc) (*twirp.ServerHooks, er
 		organisation := opt.contextOrg(ctx)
 		status, _ := twirp.StatusCode(ctx)
 
+		if opt.statusLabel != nil {
+			status = opt.statusLabel(status)
+		}
+
 		responsesSent.WithLabelValues(
 			serviceName, method, status, organisation,
 		).Inc()
 
-		if start, ok := ctx.Value(reqStartTimestampKey).(time.Time); ok {
+		if start, ok := ctx.Value(reqStartTimestampKey{}).(time.Time); ok {
 			dur := time.Since(start).Seconds() // 100ms = 0.1 sek
 
 			if opt.testLatency != 0 {
@@ -414,6 +1399,21 @@ func NewTwirpMetricsHooks(opts ...TwirpMetricOptionFunc) (*twirp.ServerHooks, er
 		}
 	}
 
+	hooks.Error = func(ctx context.Context, twErr twirp.Error) context.Context {
+		serviceName, sOk := twirp.ServiceName(ctx)
+		method, mOk := twirp.MethodName(ctx)
+
+		if !mOk || !sOk {
+			return ctx
+		}
+
+		errorsSent.WithLabelValues(
+			serviceName, method, string(twErr.Code()), opt.contextOrg(ctx),
+		).Inc()
+
+		return ctx
+	}
+
 	hooks.RequestRouted = func(ctx context.Context) (context.Context, error) {
 		serviceName, sOk := twirp.ServiceName(ctx)
 		method, mOk := twirp.MethodName(ctx)
@@ -426,8 +1426,8 @@ func NewTwirpMetricsHooks(opts ...TwirpMetricOptionFunc) (*twirp.ServerHooks, er
 
 		seg := xray.GetSegment(ctx)
 		if seg != nil {
-			_ = seg.AddAnnotation("twirp_service", serviceName)
-			_ = seg.AddAnnotation("twirp_method", method)
+			handleXRayError("add annotation", seg.AddAnnotation("twirp_service", serviceName))
+			handleXRayError("add annotation", seg.AddAnnotation("twirp_method", method))
 		}
 
 		requestsReceived.WithLabelValues(
@@ -440,6 +1440,22 @@ func NewTwirpMetricsHooks(opts ...TwirpMetricOptionFunc) (*twirp.ServerHooks, er
 	return &hooks, nil
 }
 
+// TwirpContentTypeMiddleware rejects requests that don't have a
+// content type that Twirp can handle with a 415 Unsupported Media
+// Type response.
+func TwirpContentTypeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || (mediaType != "application/json" && mediaType != "application/protobuf") {
+			http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // AddTwirpRequestHeaders is a middleware that adds HTTP request
 // headers to the context for twirp to consume.
 func AddTwirpRequestHeaders(next http.Handler, names ...string) http.Handler {
@@ -0,0 +1,439 @@
+package panurge_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	panurge "github.com/navigacontentlab/panurge/v2"
+	"github.com/navigacontentlab/panurge/v2/internal/rpc/testservice"
+	"github.com/navigacontentlab/panurge/v2/navigaid"
+	"github.com/navigacontentlab/panurge/v2/pt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/twitchtv/twirp"
+	"github.com/twitchtv/twirp/ctxsetters"
+)
+
+func TestNewTwirpMetricsHooks__Namespace(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+
+	_, err := panurge.NewTwirpMetricsHooks(
+		panurge.WithTwirpMetricsRegisterer(reg),
+	)
+	pt.Must(t, err, "failed to create the default metrics hooks")
+
+	_, err = panurge.NewTwirpMetricsHooks(
+		panurge.WithTwirpMetricsRegisterer(reg),
+		panurge.WithTwirpMetricsNamespace("app", "billing"),
+	)
+	pt.Must(t, err, "failed to create namespaced metrics hooks in the same registry")
+
+	_, err = panurge.NewTwirpMetricsHooks(
+		panurge.WithTwirpMetricsRegisterer(reg),
+	)
+	if err == nil {
+		t.Fatal("expected duplicate metric registration to fail")
+	}
+}
+
+func TestStandardTwirpHooks__AuthMetricsNamespace(t *testing.T) {
+	mock, err := navigaid.NewMockServer(navigaid.MockServerOptions{})
+	pt.Must(t, err, "failed to create mock server")
+
+	t.Cleanup(mock.Server.Close)
+
+	reg := prometheus.NewPedanticRegistry()
+
+	_, err = panurge.StandardTwirpHooks(
+		panurge.Logger("error", io.Discard),
+		panurge.TwirpHookOptions{
+			Validator:   navigaid.NewJWKS(navigaid.ImasJWKSEndpoint(mock.Server.URL)),
+			AuthMetrics: true,
+			MetricsOptions: []panurge.TwirpMetricOptionFunc{
+				panurge.WithTwirpMetricsRegisterer(reg),
+				panurge.WithTwirpMetricsNamespace("app", "billing"),
+			},
+		})
+	pt.Must(t, err, "failed to create the standard hooks")
+
+	metric, err := navigaid.NewAuthMetrics(reg,
+		navigaid.WithAuthMetricsNamespace("app", "billing"))
+	if err == nil || metric != nil {
+		t.Fatal("expected the namespaced auth metric to already be registered")
+	}
+
+	_, err = navigaid.NewAuthMetrics(reg)
+	pt.Must(t, err, "expected the auth metric without a namespace to be free")
+}
+
+func TestStandardTwirpHooks__MetricsDisabled(t *testing.T) {
+	hooks, err := panurge.StandardTwirpHooks(
+		panurge.Logger("error", io.Discard),
+		panurge.TwirpHookOptions{
+			AuthMetrics: true,
+			Validator:   navigaid.NewJWKS("https://imas.example.com/v1/jwks"),
+			MetricsOptions: []panurge.TwirpMetricOptionFunc{
+				panurge.WithTwirpMetricsRegisterer(nil),
+			},
+		})
+	pt.Must(t, err, "failed to create hooks without metrics")
+
+	if hooks.RequestRouted == nil {
+		t.Fatal("expected the auth hook to be set up without metrics")
+	}
+}
+
+func TestRequestElapsed(t *testing.T) {
+	if _, ok := panurge.RequestElapsed(context.Background()); ok {
+		t.Fatal("didn't expect an elapsed time without the metrics hooks")
+	}
+
+	hooks, err := panurge.NewTwirpMetricsHooks(
+		panurge.WithTwirpMetricsRegisterer(prometheus.NewPedanticRegistry()),
+	)
+	pt.Must(t, err, "failed to create the metrics hooks")
+
+	ctx, err := hooks.RequestReceived(context.Background())
+	pt.Must(t, err, "failed to run the request received hook")
+
+	time.Sleep(10 * time.Millisecond)
+
+	elapsed, ok := panurge.RequestElapsed(ctx)
+	if !ok {
+		t.Fatal("expected the metrics hooks to record the request start")
+	}
+
+	if elapsed < 10*time.Millisecond {
+		t.Errorf("expected at least 10ms to have elapsed, got %v", elapsed)
+	}
+}
+
+func TestNewErrorLoggingHooks__ServiceAndMethod(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := panurge.Logger("error", &buf)
+	hooks := panurge.NewErrorLoggingHooks(logger)
+
+	ctx := context.Background()
+	ctx = ctxsetters.WithServiceName(ctx, "Test")
+	ctx = ctxsetters.WithMethodName(ctx, "DoThing")
+
+	hooks.Error(ctx, twirp.NotFoundError("no such thing"))
+
+	var entry struct {
+		Service string `json:"twirp_service"` //nolint:tagliatelle
+		Method  string `json:"twirp_method"`  //nolint:tagliatelle
+	}
+
+	err := json.Unmarshal(buf.Bytes(), &entry)
+	pt.Must(t, err, "failed to decode log entry")
+
+	if entry.Service != "Test" || entry.Method != "DoThing" {
+		t.Fatalf("expected the service and method to be logged, got %q and %q",
+			entry.Service, entry.Method)
+	}
+}
+
+func TestTwirpContentTypeMiddleware(t *testing.T) {
+	handler := panurge.TwirpContentTypeMiddleware(http.HandlerFunc(
+		func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+	for contentType, want := range map[string]int{
+		"application/json":                http.StatusNoContent,
+		"application/json; charset=utf-8": http.StatusNoContent,
+		"application/protobuf":            http.StatusNoContent,
+		"text/html":                       http.StatusUnsupportedMediaType,
+		"":                                http.StatusUnsupportedMediaType,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/twirp/testservice.Test/DoThing", nil)
+		req.Header.Set("Content-Type", contentType)
+
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != want {
+			t.Errorf("wanted status %d for %q, got %d", want, contentType, rec.Code)
+		}
+	}
+}
+
+func TestCombineMetricsAndAuthHooks__AuthRejected(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+
+	metrics, err := panurge.NewTwirpMetricsHooks(
+		panurge.WithTwirpMetricsRegisterer(reg),
+	)
+	pt.Must(t, err, "failed to create metrics hooks")
+
+	auth := &twirp.ServerHooks{
+		RequestRouted: func(ctx context.Context) (context.Context, error) {
+			return ctx, twirp.Unauthenticated.Error("no token")
+		},
+	}
+
+	hooks := panurge.CombineMetricsAndAuthHooks(metrics, auth)
+
+	ctx := context.Background()
+	ctx = ctxsetters.WithServiceName(ctx, "Test")
+	ctx = ctxsetters.WithMethodName(ctx, "DoThing")
+
+	ctx, err = hooks.RequestReceived(ctx)
+	pt.Must(t, err, "expected RequestReceived to succeed")
+
+	ctx, err = hooks.RequestRouted(ctx)
+	if err == nil {
+		t.Fatal("expected the auth hook to reject the request")
+	}
+
+	ctx = hooks.Error(ctx, twirp.Unauthenticated.Error("no token"))
+	ctx = ctxsetters.WithStatusCode(ctx, http.StatusUnauthorized)
+
+	hooks.ResponseSent(ctx)
+
+	families, err := reg.Gather()
+	pt.Must(t, err, "failed to gather metrics")
+
+	var observations uint64
+
+	for _, f := range families {
+		if f.GetName() != "rpc_duration" {
+			continue
+		}
+
+		for _, m := range f.GetMetric() {
+			observations += m.GetHistogram().GetSampleCount()
+		}
+	}
+
+	if observations != 1 {
+		t.Fatalf("expected one duration observation for the rejected request, got %d",
+			observations)
+	}
+}
+
+func TestNewDeadlineHooks(t *testing.T) {
+	hooks := panurge.NewDeadlineHooks()
+
+	headers := make(http.Header)
+	headers.Set(navigaid.TimeoutHeader, "5S")
+
+	ctx, err := twirp.WithHTTPRequestHeaders(context.Background(), headers)
+	pt.Must(t, err, "failed to add request headers")
+
+	ctx, err = hooks.RequestReceived(ctx)
+	pt.Must(t, err, "expected RequestReceived to succeed")
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		t.Fatal("expected the timeout header to set a deadline")
+	}
+
+	if remaining := time.Until(deadline); remaining <= 0 || remaining > 5*time.Second {
+		t.Errorf("expected the deadline to be within five seconds, got %v", remaining)
+	}
+
+	hooks.ResponseSent(ctx)
+
+	if ctx.Err() == nil {
+		t.Error("expected the context to be cancelled when the response has been sent")
+	}
+}
+
+func TestNewTwirpMetricsHooks__StatusLabel(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+
+	hooks, err := panurge.NewTwirpMetricsHooks(
+		panurge.WithTwirpMetricsRegisterer(reg),
+		panurge.WithTwirpMetricsStatusLabel(panurge.StatusClassLabel),
+	)
+	pt.Must(t, err, "failed to create metrics hooks")
+
+	for _, status := range []int{http.StatusOK, http.StatusNotFound, http.StatusUnauthorized} {
+		ctx := context.Background()
+		ctx = ctxsetters.WithServiceName(ctx, "Test")
+		ctx = ctxsetters.WithMethodName(ctx, "DoThing")
+		ctx = ctxsetters.WithStatusCode(ctx, status)
+
+		hooks.ResponseSent(ctx)
+	}
+
+	want := strings.NewReader(`
+# HELP rpc_responses_total Number of RPC responses sent.
+# TYPE rpc_responses_total counter
+rpc_responses_total{method="DoThing",organisation="",service="Test",status="2xx"} 1
+rpc_responses_total{method="DoThing",organisation="",service="Test",status="4xx"} 2
+`)
+
+	err = testutil.GatherAndCompare(reg, want, "rpc_responses_total")
+	if err != nil {
+		t.Errorf("didn't gather the expected metrics: %v", err)
+	}
+}
+
+func TestNewTraceIDInterceptor(t *testing.T) {
+	panurge.SetIDGenerator(func() string {
+		return "request-123"
+	})
+
+	t.Cleanup(func() {
+		panurge.SetIDGenerator(nil)
+	})
+
+	ctx := panurge.ContextWithAnnotations(context.Background())
+
+	samples := map[string]struct {
+		Err  error
+		Want string
+	}{
+		"TwirpError": {Err: twirp.NotFoundError("no such thing"), Want: "request-123"},
+		"PlainError": {Err: errors.New("boom"), Want: "request-123"},
+		"ExistingMeta": {
+			Err:  twirp.NotFoundError("no such thing").WithMeta(panurge.TraceIDMetaKey, "custom"),
+			Want: "custom",
+		},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			method := panurge.NewTraceIDInterceptor()(
+				func(_ context.Context, _ interface{}) (interface{}, error) {
+					return nil, tc.Err
+				})
+
+			_, err := method(ctx, nil)
+
+			var twErr twirp.Error
+			if !errors.As(err, &twErr) {
+				t.Fatalf("expected a twirp error, got %v", err)
+			}
+
+			if got := twErr.Meta(panurge.TraceIDMetaKey); got != tc.Want {
+				t.Fatalf("expected the trace ID %q, got %q", tc.Want, got)
+			}
+		})
+	}
+}
+
+func TestStandardTwirpHooks__TraceIDHeader(t *testing.T) {
+	panurge.SetIDGenerator(func() string {
+		return "request-123"
+	})
+
+	t.Cleanup(func() {
+		panurge.SetIDGenerator(nil)
+	})
+
+	hooks, err := panurge.StandardTwirpHooks(
+		panurge.Logger("error", io.Discard),
+		panurge.TwirpHookOptions{
+			MetricsOptions: []panurge.TwirpMetricOptionFunc{
+				panurge.WithTwirpMetricsRegisterer(nil),
+			},
+		})
+	pt.Must(t, err, "failed to create the standard hooks")
+
+	// The greeter fails without authentication.
+	server := httptest.NewServer(panurge.AnnotationMiddleware(
+		testservice.NewTestServer(&Greeter{}, hooks)))
+
+	t.Cleanup(server.Close)
+
+	res, err := server.Client().Post(server.URL+testservice.TestPathPrefix+"DoThing",
+		"application/json", strings.NewReader("{}"))
+	pt.Must(t, err, "failed to make the request")
+
+	_ = res.Body.Close()
+
+	if res.StatusCode == http.StatusOK {
+		t.Fatal("expected the unauthenticated call to fail")
+	}
+
+	if got := res.Header.Get(panurge.TraceIDHeader); got != "request-123" {
+		t.Fatalf("expected the trace ID header %q, got %q", "request-123", got)
+	}
+}
+
+func TestDescribeTwirpHooks(t *testing.T) {
+	samples := map[string]struct {
+		Opts panurge.TwirpHookOptions
+		Want panurge.ActiveTwirpHooks
+	}{
+		"NoAuth": {
+			Opts: panurge.TwirpHookOptions{DebugIdentity: true},
+			Want: panurge.ActiveTwirpHooks{
+				Auth: panurge.AuthSourceNone, Metrics: true,
+			},
+		},
+		"Imas": {
+			Opts: panurge.TwirpHookOptions{
+				ImasURL: "https://imas.example.com", Audience: "svc",
+			},
+			Want: panurge.ActiveTwirpHooks{
+				Auth: panurge.AuthSourceImas, Metrics: true, Audience: "svc",
+			},
+		},
+		"Custom": {
+			Opts: panurge.TwirpHookOptions{
+				AuthHook: &twirp.ServerHooks{}, ImasURL: "https://imas.example.com",
+				AuthMetrics: true,
+			},
+			Want: panurge.ActiveTwirpHooks{
+				Auth: panurge.AuthSourceCustom, Metrics: true,
+			},
+		},
+		"AuthMetrics": {
+			Opts: panurge.TwirpHookOptions{
+				ImasURL: "https://imas.example.com", AuthMetrics: true,
+			},
+			Want: panurge.ActiveTwirpHooks{
+				Auth: panurge.AuthSourceImas, Metrics: true, AuthMetrics: true,
+			},
+		},
+		"MetricsDisabled": {
+			Opts: panurge.TwirpHookOptions{
+				ImasURL: "https://imas.example.com", AuthMetrics: true,
+				MetricsOptions: []panurge.TwirpMetricOptionFunc{
+					panurge.WithTwirpMetricsRegisterer(nil),
+				},
+			},
+			Want: panurge.ActiveTwirpHooks{
+				Auth: panurge.AuthSourceImas,
+			},
+		},
+		"Validator": {
+			Opts: panurge.TwirpHookOptions{
+				Validator:     navigaid.NewJWKS("https://imas.example.com/v1/jwks"),
+				LegacyToken:   true,
+				DebugIdentity: true,
+			},
+			Want: panurge.ActiveTwirpHooks{
+				Auth: panurge.AuthSourceValidator, Metrics: true,
+				LegacyToken: true, DebugIdentity: true,
+			},
+		},
+	}
+
+	for name := range samples {
+		tc := samples[name]
+
+		t.Run(name, func(t *testing.T) {
+			got := panurge.DescribeTwirpHooks(tc.Opts)
+			if got != tc.Want {
+				t.Fatalf("expected %+v, got %+v", tc.Want, got)
+			}
+		})
+	}
+}
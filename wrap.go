@@ -0,0 +1,92 @@
+package panurge
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/navigacontentlab/panurge/v2/navigaid"
+)
+
+// WrapOption controls the middleware that WrapHandler applies.
+type WrapOption func(o *wrapOptions)
+
+type wrapOptions struct {
+	name        string
+	cors        *CORSOptions
+	validator   navigaid.Validator
+	authOpts    []navigaid.AuthOption
+	summaryLogs bool
+}
+
+// WithWrapName sets the name used for XRay segments, defaults to
+// "service".
+func WithWrapName(name string) WrapOption {
+	return func(o *wrapOptions) {
+		o.name = name
+	}
+}
+
+// WithWrapCORS enables CORS handling, see NewCORSMiddleware.
+func WithWrapCORS(opts CORSOptions) WrapOption {
+	return func(o *wrapOptions) {
+		o.cors = &opts
+	}
+}
+
+// WithWrapAuth adds NavigaID authentication information to the
+// request context using navigaid.HTTPMiddleware. The user and
+// "imid_org" annotations are added for authenticated requests.
+func WithWrapAuth(validator navigaid.Validator, options ...navigaid.AuthOption) WrapOption {
+	return func(o *wrapOptions) {
+		o.validator = validator
+		o.authOpts = options
+	}
+}
+
+// WithWrapRequestSummary logs a summary entry at the end of every
+// request, see RequestSummaryMiddleware.
+func WithWrapRequestSummary() WrapOption {
+	return func(o *wrapOptions) {
+		o.summaryLogs = true
+	}
+}
+
+// WrapHandler wraps a handler with the standard panurge middleware
+// stack, so that it can be used for applications that don't use
+// StandardApp. The request is first instrumented with XRay and
+// annotations, then CORS and authentication are handled before the
+// handler is called.
+func WrapHandler(logger *slog.Logger, h http.Handler, opts ...WrapOption) http.Handler {
+	o := wrapOptions{
+		name: "service",
+	}
+
+	for i := range opts {
+		opts[i](&o)
+	}
+
+	handler := h
+
+	if o.validator != nil {
+		handler = navigaid.HTTPMiddleware(o.validator, handler,
+			func(ctx context.Context, org string, user string) {
+				AddUserAnnotation(ctx, user)
+				AddAnnotation(ctx, "imid_org", org)
+			}, o.authOpts...)
+	}
+
+	if o.cors != nil {
+		handler = NewCORSMiddleware(*o.cors).Handler(handler)
+	}
+
+	if o.summaryLogs {
+		handler = RequestSummaryMiddleware(logger, handler)
+	}
+
+	return xray.Handler(
+		xray.NewFixedSegmentNamer(o.name),
+		AnnotationMiddleware(handler),
+	)
+}
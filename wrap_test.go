@@ -0,0 +1,93 @@
+package panurge_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	panurge "github.com/navigacontentlab/panurge/v2"
+	"github.com/navigacontentlab/panurge/v2/navigaid"
+	"github.com/navigacontentlab/panurge/v2/pt"
+)
+
+type staticValidator struct {
+	claims navigaid.Claims
+}
+
+func (v staticValidator) Validate(accessToken string) (navigaid.Claims, error) {
+	return v.ValidateToken(accessToken, navigaid.TokenTypeAccessToken)
+}
+
+func (v staticValidator) ValidateToken(token string, _ string) (navigaid.Claims, error) {
+	if token != "valid" {
+		return navigaid.Claims{}, errors.New("invalid token")
+	}
+
+	return v.claims, nil
+}
+
+func TestWrapHandler(t *testing.T) {
+	pt.DisableXRay()
+
+	logger := panurge.Logger("warning", pt.NewTestLogWriter(t))
+
+	var org string
+
+	handler := panurge.WrapHandler(logger,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, err := navigaid.GetAuth(r.Context()); err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+
+				return
+			}
+
+			ann := panurge.GetContextAnnotations(r.Context())
+			if ann == nil {
+				t.Error("expected annotations in the request context")
+
+				return
+			}
+
+			org, _ = panurge.GetAnnotationValue[string](r.Context(), "imid_org")
+
+			w.WriteHeader(http.StatusNoContent)
+		}),
+		panurge.WithWrapName("router"),
+		panurge.WithWrapCORS(panurge.CORSOptions{
+			AllowedDomains: []string{"example.com"},
+		}),
+		panurge.WithWrapAuth(staticValidator{
+			claims: navigaid.Claims{Org: "sampleorg"},
+		}),
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/things", nil)
+	req.Header.Set("Authorization", "Bearer valid")
+	req.Header.Set("Origin", "https://www.example.com")
+
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected the request to be authenticated, got %d", rec.Code)
+	}
+
+	if org != "sampleorg" {
+		t.Errorf("expected the org to be annotated, got %q", org)
+	}
+
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://www.example.com" {
+		t.Errorf("expected CORS headers, got %v", rec.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/things", nil)
+	rec = httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected the request without a token to be rejected, got %d", rec.Code)
+	}
+}
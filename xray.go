@@ -3,24 +3,48 @@ package panurge
 import (
 	"fmt"
 	"log/slog"
+	"sync"
 
 	"github.com/aws/aws-xray-sdk-go/strategy/ctxmissing"
 	"github.com/aws/aws-xray-sdk-go/xray"
 	"github.com/aws/aws-xray-sdk-go/xraylog"
 )
 
+var (
+	xrayConfigMutex   sync.Mutex
+	xrayConfigured    bool
+	xrayConfigVersion string
+)
+
 // ConfigureXRay sets up XRay with a slog logger and makes sure that
-// XRay doesn't panic when a context is missing.
-func ConfigureXRay(logger *slog.Logger, version string) {
+// XRay doesn't panic when a context is missing. The XRay
+// configuration is global, so repeated calls with the same version
+// don't reconfigure XRay, this lets multiple applications be created
+// in the same process (f.ex. in tests) without resetting the
+// configuration. The logger is always replaced, so XRay logs through
+// the logger of the latest call.
+func ConfigureXRay(logger *slog.Logger, version string) error {
+	xrayConfigMutex.Lock()
+	defer xrayConfigMutex.Unlock()
+
+	xray.SetLogger(&xrayLogrusAdapter{logger: logger})
+
+	if xrayConfigured && xrayConfigVersion == version {
+		return nil
+	}
+
 	err := xray.Configure(xray.Config{
 		ServiceVersion:         version,
 		ContextMissingStrategy: ctxmissing.NewDefaultLogErrorStrategy(),
 	})
 	if err != nil {
-		logger.Error(fmt.Sprintf("failed to configure XRay: %v", err))
+		return fmt.Errorf("failed to configure XRay: %w", err)
 	}
 
-	xray.SetLogger(&xrayLogrusAdapter{logger: logger})
+	xrayConfigured = true
+	xrayConfigVersion = version
+
+	return nil
 }
 
 type xrayLogrusAdapter struct {